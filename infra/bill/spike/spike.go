@@ -30,6 +30,8 @@ type Spike struct {
 	regionFlag            *flags.StringArray
 	typeFlag              *flags.StringArray
 	tagFlag               *flags.StringArray
+	accountFlag           *flags.StringArray
+	expressionFlag        *string
 	granularityFlag       *string
 	startDateFlag         *string
 	endDateFlag           *string
@@ -43,6 +45,9 @@ type Spike struct {
 	resourceSeparatorFlag *string
 	listFieldsFlag        *bool
 	defaultFieldValueFlag *string
+	outputFlag            *string
+	templateFlag          *string
+	templateFileFlag      *string
 	outputFormatFlag      *string
 	subCommands           []cli.Command
 }
@@ -99,6 +104,12 @@ func (spike *Spike) Process() {
 	}
 	daysDiff := endDate.Sub(startDate).Hours() / -24
 
+	// Costs are not broken down per resource so they can't be matched against an expression
+	if len(*spike.expressionFlag) > 0 {
+		notification.SendMessage("-filter can't be used to filter costs. Use the other -filter-* flags instead")
+		cli.ExitCommandInterpretationError()
+	}
+
 	// Calculate current period's costs and usages
 	costAndUsageFilter := spike.GetFiltersFromFlags()
 	curProviderCosts, err := infra.GetCostsAndUsages(costAndUsageFilter)
@@ -179,7 +190,10 @@ func (spike *Spike) Process() {
 		spike.fieldSeparatorFlag,
 		spike.resourceSeparatorFlag,
 		spike.listFieldsFlag,
-		spike.defaultFieldValueFlag)
+		spike.defaultFieldValueFlag,
+		spike.outputFlag,
+		spike.templateFlag,
+		spike.templateFileFlag)
 	table, tableErr := rt.RenderCostSpikes(spikedCosts)
 	if tableErr != nil {
 		notification.SendMessage(tableErr.Error())
@@ -206,6 +220,9 @@ func (spike *Spike) GetFiltersFromFlags() *types.CostAndUsageFilter {
 	if len(*spike.providerFlag) > 0 {
 		filter.Providers = *spike.providerFlag
 	}
+	if len(*spike.accountFlag) > 0 {
+		filter.Accounts = *spike.accountFlag
+	}
 	if len(*spike.regionFlag) > 0 {
 		filter.Regions = *spike.regionFlag
 	}
@@ -244,7 +261,7 @@ func (spike *Spike) GetFiltersFromFlags() *types.CostAndUsageFilter {
 }
 
 func (spike *Spike) AddFilterFlags() {
-	spike.providerFlag, spike.regionFlag, spike.typeFlag, spike.tagFlag = infra.AddFilterFlags(spike.flagSet)
+	spike.providerFlag, spike.regionFlag, spike.typeFlag, spike.tagFlag, spike.accountFlag, spike.expressionFlag = infra.AddFilterFlags(spike.flagSet)
 
 	// add cost spike flags
 	spike.granularityFlag = spike.flagSet.String(
@@ -291,5 +308,8 @@ func (spike *Spike) AddFilterFlags() {
 		spike.fieldSeparatorFlag,
 		spike.resourceSeparatorFlag,
 		spike.listFieldsFlag,
-		spike.defaultFieldValueFlag = infra.AddResourceTableFlags(spike.flagSet)
+		spike.defaultFieldValueFlag,
+		spike.outputFlag,
+		spike.templateFlag,
+		spike.templateFileFlag = infra.AddResourceTableFlags(spike.flagSet)
 }
@@ -26,6 +26,8 @@ type Validate struct {
 	regionFlag            *flags.StringArray
 	typeFlag              *flags.StringArray
 	tagFlag               *flags.StringArray
+	accountFlag           *flags.StringArray
+	expressionFlag        *string
 	showFlag              *flags.StringArray
 	hideHeadersFlag       *bool
 	csvFlag               *bool
@@ -33,14 +35,18 @@ type Validate struct {
 	resourceSeparatorFlag *string
 	listFieldsFlag        *bool
 	defaultFieldValueFlag *string
+	outputFlag            *string
+	templateFlag          *string
+	templateFileFlag      *string
 	outputFormatFlag      *string
+	noCacheFlag           *bool
 	subCommands           []cli.Command
 }
 
 func (validate *Validate) Init(helpFlagName string, helpFlagDescription string) {
 	validate.flagSet = flag.NewFlagSet(validate.GetName(), flag.ExitOnError)
 	validate.helpFlag = validate.flagSet.Bool(helpFlagName, false, helpFlagDescription)
-	validate.providerFlag, validate.regionFlag, validate.typeFlag, validate.tagFlag = infra.AddFilterFlags(validate.flagSet)
+	validate.providerFlag, validate.regionFlag, validate.typeFlag, validate.tagFlag, validate.accountFlag, validate.expressionFlag = infra.AddFilterFlags(validate.flagSet)
 	validate.outputFormatFlag = validate.flagSet.String(
 		"output-format",
 		outputFormatPlain,
@@ -54,7 +60,11 @@ func (validate *Validate) Init(helpFlagName string, helpFlagDescription string)
 		validate.fieldSeparatorFlag,
 		validate.resourceSeparatorFlag,
 		validate.listFieldsFlag,
-		validate.defaultFieldValueFlag = infra.AddResourceTableFlags(validate.flagSet)
+		validate.defaultFieldValueFlag,
+		validate.outputFlag,
+		validate.templateFlag,
+		validate.templateFileFlag = infra.AddResourceTableFlags(validate.flagSet)
+	validate.noCacheFlag = infra.AddCacheFlag(validate.flagSet)
 	validate.subCommands = []cli.Command{}
 }
 
@@ -79,6 +89,10 @@ func (validate *Validate) GetHelpFlag() *bool {
 }
 
 func (validate *Validate) Process() {
+	if *validate.noCacheFlag {
+		infra.NoCache = true
+	}
+
 	requiredTagsString := os.Getenv(requiredTagsEnvVar)
 	if len(requiredTagsString) == 0 {
 		notification.SendMessage(fmt.Sprintf("%s not set", requiredTagsEnvVar))
@@ -86,8 +100,16 @@ func (validate *Validate) Process() {
 	}
 	requiredTags := strings.Split(requiredTagsString, ",")
 
-	allResources, resourcesErr := infra.GetResources(infra.GetFiltersFromCommandFlags(validate.providerFlag, validate.regionFlag, validate.typeFlag, validate.tagFlag))
-	if resourcesErr != nil {
+	filter, filterErr := infra.GetFiltersFromCommandFlags(validate.providerFlag, validate.regionFlag, validate.typeFlag, validate.tagFlag, validate.accountFlag, validate.expressionFlag)
+	if filterErr != nil {
+		notification.SendMessage(filterErr.Error())
+		cli.ExitCommandInterpretationError()
+	}
+
+	// Validate the resources that were fetched even if fetching the others failed
+	allResources, resourcesErr := infra.GetResources(filter)
+	failures, partial := resourcesErr.(types.InfraFailures)
+	if resourcesErr != nil && !partial {
 		notification.SendMessage(resourcesErr.Error())
 		cli.ExitCommandExecutionError()
 	}
@@ -107,6 +129,10 @@ func (validate *Validate) Process() {
 	}
 
 	if len(untaggedResources) == 0 {
+		if partial {
+			notification.SendMessage(infra.RenderFailures(failures))
+			cli.ExitCommandExecutionError()
+		}
 		return
 	}
 
@@ -118,7 +144,10 @@ func (validate *Validate) Process() {
 		validate.fieldSeparatorFlag,
 		validate.resourceSeparatorFlag,
 		validate.listFieldsFlag,
-		validate.defaultFieldValueFlag)
+		validate.defaultFieldValueFlag,
+		validate.outputFlag,
+		validate.templateFlag,
+		validate.templateFileFlag)
 	table, tableErr := rt.RenderResources(untaggedResources)
 	if tableErr != nil {
 		notification.SendMessage(tableErr.Error())
@@ -137,6 +166,9 @@ func (validate *Validate) Process() {
 	}
 
 	notification.SendMessage(formattedOutput)
+	if partial {
+		notification.SendMessage(infra.RenderFailures(failures))
+	}
 	cli.ExitCommandExecutionError()
 }
 
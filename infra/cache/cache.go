@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"flag"
+
+	"github.com/onaio/sre-tooling/infra/cache/clear"
+	"github.com/onaio/sre-tooling/libs/cli"
+)
+
+const name string = "cache"
+
+// Cache deals with commands related to the cache of the resources fetched from the providers
+type Cache struct {
+	helpFlag    *bool
+	flagSet     *flag.FlagSet
+	subCommands []cli.Command
+}
+
+// Init initializes the command object
+func (cache *Cache) Init(helpFlagName string, helpFlagDescription string) {
+	cache.flagSet = flag.NewFlagSet(cache.GetName(), flag.ExitOnError)
+	cache.helpFlag = cache.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	clear := new(clear.Clear)
+	clear.Init(helpFlagName, helpFlagDescription)
+
+	cache.subCommands = []cli.Command{clear}
+}
+
+// GetName returns the value of the name constant
+func (cache *Cache) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the cache command
+func (cache *Cache) GetDescription() string {
+	return "Manages the cache of the resources fetched from the providers"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (cache *Cache) GetFlagSet() *flag.FlagSet {
+	return cache.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the cache command
+// (expect empty slice if none)
+func (cache *Cache) GetSubCommands() []cli.Command {
+	return cache.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (cache *Cache) GetHelpFlag() *bool {
+	return cache.helpFlag
+}
+
+// Process does nothing, since this command has subcommands that actually do the processing
+func (cache *Cache) Process() {}
@@ -0,0 +1,64 @@
+package clear
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/notification"
+)
+
+const name string = "clear"
+
+// Clear removes the cached resources
+type Clear struct {
+	helpFlag    *bool
+	flagSet     *flag.FlagSet
+	subCommands []cli.Command
+}
+
+// Init initializes the command object
+func (clear *Clear) Init(helpFlagName string, helpFlagDescription string) {
+	clear.flagSet = flag.NewFlagSet(clear.GetName(), flag.ExitOnError)
+	clear.helpFlag = clear.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+
+	clear.subCommands = []cli.Command{}
+}
+
+// GetName returns the value of the name constant
+func (clear *Clear) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the clear command
+func (clear *Clear) GetDescription() string {
+	return "Removes all the cached resources"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (clear *Clear) GetFlagSet() *flag.FlagSet {
+	return clear.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the clear command
+// (expect empty slice if none)
+func (clear *Clear) GetSubCommands() []cli.Command {
+	return clear.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (clear *Clear) GetHelpFlag() *bool {
+	return clear.helpFlag
+}
+
+// Process removes the cached resources
+func (clear *Clear) Process() {
+	clearErr := infra.ClearResourcesCache()
+	if clearErr != nil {
+		notification.SendMessage(fmt.Sprintf("Could not clear the cached resources: %v", clearErr))
+		cli.ExitCommandExecutionError()
+	}
+
+	notification.SendMessage("Cleared the cached resources")
+}
@@ -3,6 +3,7 @@ package expiry
 import (
 	"flag"
 
+	"github.com/onaio/sre-tooling/infra/expiry/extend"
 	"github.com/onaio/sre-tooling/infra/expiry/prune"
 	"github.com/onaio/sre-tooling/infra/expiry/query"
 	"github.com/onaio/sre-tooling/libs/cli"
@@ -25,8 +26,10 @@ func (expiry *Expiry) Init(helpFlagName string, helpFlagDescription string) {
 	query.Init(helpFlagName, helpFlagDescription)
 	prune := new(prune.Prune)
 	prune.Init(helpFlagName, helpFlagDescription)
+	extend := new(extend.Extend)
+	extend.Init(helpFlagName, helpFlagDescription)
 
-	expiry.subCommands = []cli.Command{query, prune}
+	expiry.subCommands = []cli.Command{query, prune, extend}
 }
 
 // GetName returns the value of the name constant
@@ -0,0 +1,327 @@
+package extend
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/infra/expiry/query"
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/cli/flags"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const name string = "extend"
+const defaultMaxExtension string = "336h"
+const defaultExtensionTotalTag string = "ExpiryExtensionTotal"
+
+// Extend extends the expiry time stored in the expiry tag of resources
+type Extend struct {
+	helpFlag              *bool
+	flagSet               *flag.FlagSet
+	providerFlag          *flags.StringArray
+	regionFlag            *flags.StringArray
+	typeFlag              *flags.StringArray
+	tagFlag               *flags.StringArray
+	accountFlag           *flags.StringArray
+	expressionFlag        *string
+	idFlag                *string
+	durationFlag          *string
+	maxExtensionFlag      *string
+	expiryTagFlag         *string
+	expiryTagNAValueFlag  *string
+	expiryTagFormatFlag   *string
+	extensionCountTagFlag *string
+	extensionTotalTagFlag *string
+	subCommands           []cli.Command
+}
+
+// extension holds the new values of the expiry tags of a resource after its expiry time is extended
+type extension struct {
+	expiryTime time.Time
+	count      int
+	total      time.Duration
+}
+
+// Init initializes the command object
+func (extend *Extend) Init(helpFlagName string, helpFlagDescription string) {
+	extend.flagSet = flag.NewFlagSet(extend.GetName(), flag.ExitOnError)
+	extend.helpFlag = extend.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	extend.providerFlag,
+		extend.regionFlag,
+		extend.typeFlag,
+		extend.tagFlag,
+		extend.accountFlag,
+		extend.expressionFlag = infra.AddFilterFlags(extend.flagSet)
+	extend.idFlag = extend.flagSet.String("id", "", "The ID of the resource to extend. If not set, all the resources matching the filter flags will be extended")
+	extend.durationFlag = extend.flagSet.String("duration", "", "How long to extend the expiry time by e.g '72h'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', and 'h'.")
+	extend.maxExtensionFlag = extend.flagSet.String("max-extension", defaultMaxExtension, "The maximum total duration a resource's expiry time can be extended by, across all extensions")
+	extend.extensionCountTagFlag = extend.flagSet.String("extension-count-tag", query.DefaultExtensionCountTag, "Name of the tag storing the number of times the resource's expiry time has been extended")
+	extend.extensionTotalTagFlag = extend.flagSet.String("extension-total-tag", defaultExtensionTotalTag, "Name of the tag storing the total duration the resource's expiry time has been extended by")
+	extend.expiryTagFlag,
+		extend.expiryTagNAValueFlag,
+		extend.expiryTagFormatFlag = query.AddExpiryTagFlags(extend.flagSet)
+
+	extend.subCommands = []cli.Command{}
+}
+
+// GetName returns the value of the name constant
+func (extend *Extend) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the extend command
+func (extend *Extend) GetDescription() string {
+	return "Extends the expiry time of infrastructure by updating its expiry tag"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (extend *Extend) GetFlagSet() *flag.FlagSet {
+	return extend.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the extend command
+// (expect empty slice if none)
+func (extend *Extend) GetSubCommands() []cli.Command {
+	return extend.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (extend *Extend) GetHelpFlag() *bool {
+	return extend.helpFlag
+}
+
+// Process extends the expiry time of the resource with the provided ID, or of all the resources
+// matching the provided filters
+func (extend *Extend) Process() {
+	if len(*extend.expiryTagFlag) == 0 || len(*extend.expiryTagFormatFlag) == 0 {
+		notification.SendMessage("Both the expiry tag and the expiry tag format need to be provided")
+		cli.ExitCommandInterpretationError()
+	}
+
+	if len(*extend.idFlag) == 0 && len(*extend.regionFlag) == 0 && len(*extend.typeFlag) == 0 && len(*extend.tagFlag) == 0 && len(*extend.expressionFlag) == 0 {
+		notification.SendMessage("You need to provide the ID of the resource or filter resources using at least one region, type, tag, or filter expression")
+		cli.ExitCommandInterpretationError()
+	}
+
+	duration, durationErr := time.ParseDuration(*extend.durationFlag)
+	if durationErr != nil || duration <= 0 {
+		notification.SendMessage(fmt.Sprintf("Unable to process the value of -duration '%s'. A positive duration is expected", *extend.durationFlag))
+		cli.ExitCommandInterpretationError()
+	}
+
+	maxExtension, maxExtensionErr := time.ParseDuration(*extend.maxExtensionFlag)
+	if maxExtensionErr != nil {
+		notification.SendMessage(fmt.Sprintf("Unable to process the value of -max-extension '%s'", *extend.maxExtensionFlag))
+		cli.ExitCommandInterpretationError()
+	}
+
+	filter, filterErr := infra.GetFiltersFromCommandFlags(
+		extend.providerFlag,
+		extend.regionFlag,
+		extend.typeFlag,
+		extend.tagFlag,
+		extend.accountFlag,
+		extend.expressionFlag)
+	if filterErr != nil {
+		notification.SendMessage(filterErr.Error())
+		cli.ExitCommandInterpretationError()
+	}
+
+	allResources, resourcesErr := infra.GetResourcesUncached(filter)
+	if resourcesErr != nil {
+		notification.SendMessage(fmt.Errorf("Could not get the list of cloud resources: %v", resourcesErr).Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	resources := []*types.InfraResource{}
+	for _, curResource := range allResources {
+		if len(*extend.idFlag) == 0 || curResource.ID == *extend.idFlag {
+			resources = append(resources, curResource)
+		}
+	}
+
+	if len(resources) == 0 {
+		notification.SendMessage("No resource matching the provided ID and filters was found")
+		cli.ExitCommandExecutionError()
+	}
+
+	hasExtendErr := false
+	for _, curResource := range resources {
+		newExtension, extendErr := extend.extendResource(curResource, duration, maxExtension)
+		if extendErr != nil {
+			notification.SendMessage(fmt.Errorf("Could not extend the expiry time of resource '%s': %v", curResource.ID, extendErr).Error())
+			hasExtendErr = true
+			continue
+		}
+
+		notification.SendMessage(fmt.Sprintf(
+			"Extended the expiry time of resource '%s' to %s. Expiry time has been extended %d times, by %s in total",
+			curResource.ID,
+			newExtension.expiryTime.Format(time.RFC1123),
+			newExtension.count,
+			newExtension.total.String()))
+	}
+
+	if hasExtendErr {
+		cli.ExitCommandExecutionError()
+	}
+}
+
+// extendResource calculates the resource's new expiry time then updates the resource's tags
+func (extend *Extend) extendResource(resource *types.InfraResource, duration time.Duration, maxExtension time.Duration) (*extension, error) {
+	newExtension, extensionErr := getExtension(
+		resource,
+		duration,
+		maxExtension,
+		*extend.expiryTagFlag,
+		*extend.expiryTagNAValueFlag,
+		*extend.expiryTagFormatFlag,
+		*extend.extensionCountTagFlag,
+		*extend.extensionTotalTagFlag,
+		time.Now())
+	if extensionErr != nil {
+		return nil, extensionErr
+	}
+
+	// Update the expiry tag first so that the extension tags are only updated if the resource was extended
+	updateErr := updateTags(resource, []*tagUpdate{
+		{key: *extend.expiryTagFlag, value: newExtension.expiryTime.Format(*extend.expiryTagFormatFlag)},
+		{key: *extend.extensionCountTagFlag, value: strconv.Itoa(newExtension.count)},
+		{key: *extend.extensionTotalTagFlag, value: newExtension.total.String()},
+	}, infra.UpdateResourceTag)
+	if updateErr != nil {
+		return nil, updateErr
+	}
+
+	return newExtension, nil
+}
+
+// tagUpdate is the new value of one of a resource's tags
+type tagUpdate struct {
+	key   string
+	value string
+}
+
+// tagUpdater sets the value of the resource's tag, or removes the tag if tagValue is nil, like
+// infra.UpdateResourceTag
+type tagUpdater func(resource *types.InfraResource, tagKey *string, tagValue *string) error
+
+// updateTags applies the updates to the resource's tags in order. If an update fails, the tags that were
+// already updated are rolled back so that the maximum extension is still enforced. Some providers use the
+// resource's tags to remove a tag, e.g. Azure only removes a tag that has the same value, so the updates
+// and the rollback use a copy of the resource that has the tags written so far
+func updateTags(resource *types.InfraResource, updates []*tagUpdate, updateTag tagUpdater) error {
+	updated := *resource
+	updated.Tags = make(map[string]string)
+	for curKey, curValue := range resource.Tags {
+		updated.Tags[curKey] = curValue
+	}
+
+	for i, curUpdate := range updates {
+		value := curUpdate.value
+		updateErr := updateTag(&updated, &curUpdate.key, &value)
+		if updateErr != nil {
+			return rollbackTags(resource, &updated, updates[:i], updateErr, updateTag)
+		}
+		updated.Tags[curUpdate.key] = value
+	}
+
+	return nil
+}
+
+// rollbackTags restores the values the tags in the applied updates had in the original resource. The
+// tags that the original resource didn't have are removed
+func rollbackTags(original *types.InfraResource, updated *types.InfraResource, applied []*tagUpdate, updateErr error, updateTag tagUpdater) error {
+	for i := len(applied) - 1; i >= 0; i-- {
+		key := applied[i].key
+		var oldValue *string
+		if value, hasTag := original.Tags[key]; hasTag {
+			oldValue = &value
+		}
+
+		rollbackErr := updateTag(updated, &key, oldValue)
+		if rollbackErr != nil {
+			return fmt.Errorf("%v. Could not roll back the %s tag: %v", updateErr, key, rollbackErr)
+		}
+		if oldValue == nil {
+			delete(updated.Tags, key)
+		} else {
+			updated.Tags[key] = *oldValue
+		}
+	}
+
+	return updateErr
+}
+
+// getExtension calculates the new expiry time of the resource, and the new values of its extension count and total.
+// The expiry time is extended from the resource's current expiry time or from now if the resource doesn't have an
+// expiry time or has already expired. An error is returned if the resource is marked as not expiring or if the total
+// extension would surpass maxExtension
+func getExtension(
+	resource *types.InfraResource,
+	duration time.Duration,
+	maxExtension time.Duration,
+	expiryTag string,
+	expiryTagNAValue string,
+	expiryTagFormat string,
+	extensionCountTag string,
+	extensionTotalTag string,
+	now time.Time) (*extension, error) {
+	expiryTime := now
+	expiryTimeString, expiryTagDefined := resource.Tags[expiryTag]
+	expiryTimeString = strings.TrimSpace(expiryTimeString)
+	if expiryTagDefined && expiryTimeString == expiryTagNAValue {
+		return nil, fmt.Errorf("Resource '%s' is marked as not expiring", resource.ID)
+	}
+
+	if expiryTagDefined && len(expiryTimeString) > 0 {
+		curExpiryTime, expiryTimeErr := time.Parse(expiryTagFormat, expiryTimeString)
+		if expiryTimeErr != nil {
+			return nil, fmt.Errorf("Could not parse the expiry time '%s' of resource '%s': %v", expiryTimeString, resource.ID, expiryTimeErr)
+		}
+
+		if curExpiryTime.After(now) {
+			expiryTime = curExpiryTime
+		}
+	}
+
+	count := 0
+	countString := strings.TrimSpace(resource.Tags[extensionCountTag])
+	if len(countString) > 0 {
+		curCount, countErr := strconv.Atoi(countString)
+		if countErr != nil {
+			return nil, fmt.Errorf("Could not parse the extension count '%s' of resource '%s': %v", countString, resource.ID, countErr)
+		}
+		count = curCount
+	}
+
+	total := time.Duration(0)
+	totalString := strings.TrimSpace(resource.Tags[extensionTotalTag])
+	if len(totalString) > 0 {
+		curTotal, totalErr := time.ParseDuration(totalString)
+		if totalErr != nil {
+			return nil, fmt.Errorf("Could not parse the total extension '%s' of resource '%s': %v", totalString, resource.ID, totalErr)
+		}
+		total = curTotal
+	}
+
+	if total+duration > maxExtension {
+		return nil, fmt.Errorf(
+			"Extending resource '%s' by %s would surpass the maximum total extension of %s. Resource has already been extended by %s",
+			resource.ID,
+			duration.String(),
+			maxExtension.String(),
+			total.String())
+	}
+
+	return &extension{
+		expiryTime: expiryTime.Add(duration),
+		count:      count + 1,
+		total:      total + duration,
+	}, nil
+}
@@ -0,0 +1,150 @@
+package extend
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const testExpiryTag = "EndDate"
+const testExpiryTagNAValue = "-"
+const testExpiryTagFormat = time.RFC3339
+const testCountTag = "ExpiryExtensionCount"
+const testTotalTag = "ExpiryExtensionTotal"
+
+func getTestExtension(tags map[string]string, duration time.Duration, maxExtension time.Duration, now time.Time) (*extension, error) {
+	resource := types.InfraResource{
+		Provider:   "testProvider",
+		ID:         "blah-id",
+		Location:   "eu-central-1a",
+		LaunchTime: now,
+		Tags:       tags,
+		Properties: map[string]string{}}
+
+	return getExtension(
+		&resource,
+		duration,
+		maxExtension,
+		testExpiryTag,
+		testExpiryTagNAValue,
+		testExpiryTagFormat,
+		testCountTag,
+		testTotalTag,
+		now)
+}
+
+// Test whether the expiry time is extended from the current expiry time if it is in the future
+func TestGetExtensionFutureExpiry(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	curExpiryTime := now.Add(24 * time.Hour)
+	tags := map[string]string{
+		testExpiryTag: curExpiryTime.Format(testExpiryTagFormat),
+		testCountTag:  "1",
+		testTotalTag:  "24h0m0s",
+	}
+
+	newExtension, extensionErr := getTestExtension(tags, 48*time.Hour, 336*time.Hour, now)
+	if extensionErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", extensionErr)
+	}
+	if !newExtension.expiryTime.Equal(curExpiryTime.Add(48 * time.Hour)) {
+		t.Errorf("New expiry time = %s; want %s", newExtension.expiryTime, curExpiryTime.Add(48*time.Hour))
+	}
+	if newExtension.count != 2 {
+		t.Errorf("Extension count = %d; want 2", newExtension.count)
+	}
+	if newExtension.total != 72*time.Hour {
+		t.Errorf("Extension total = %s; want 72h", newExtension.total)
+	}
+}
+
+// Test whether the expiry time is extended from now if the resource has already expired or doesn't have an expiry time
+func TestGetExtensionFromNow(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	for _, tags := range []map[string]string{
+		{testExpiryTag: now.Add(-time.Hour).Format(testExpiryTagFormat)},
+		{},
+	} {
+		newExtension, extensionErr := getTestExtension(tags, 24*time.Hour, 336*time.Hour, now)
+		if extensionErr != nil {
+			t.Fatalf("Expecting error to be nil; got %v", extensionErr)
+		}
+		if !newExtension.expiryTime.Equal(now.Add(24 * time.Hour)) {
+			t.Errorf("New expiry time = %s; want %s", newExtension.expiryTime, now.Add(24*time.Hour))
+		}
+		if newExtension.count != 1 {
+			t.Errorf("Extension count = %d; want 1", newExtension.count)
+		}
+	}
+}
+
+// Test whether extensions that surpass the maximum total extension are refused
+func TestGetExtensionMaxExtension(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		testExpiryTag: now.Add(time.Hour).Format(testExpiryTagFormat),
+		testCountTag:  "3",
+		testTotalTag:  "72h",
+	}
+
+	_, extensionErr := getTestExtension(tags, 48*time.Hour, 96*time.Hour, now)
+	if extensionErr == nil {
+		t.Errorf("Expecting an error if the maximum total extension would be surpassed")
+	}
+}
+
+// Test whether resources marked as not expiring are not extended
+func TestGetExtensionNotExpiring(t *testing.T) {
+	tags := map[string]string{testExpiryTag: testExpiryTagNAValue}
+
+	_, extensionErr := getTestExtension(tags, time.Hour, 96*time.Hour, time.Now())
+	if extensionErr == nil {
+		t.Errorf("Expecting an error if the resource is marked as not expiring")
+	}
+}
+
+// Test whether the tags that were updated are rolled back when updating a tag fails, using a provider
+// that, like Azure, only removes a tag that the resource has with the same value
+func TestUpdateTagsRollback(t *testing.T) {
+	liveTags := map[string]string{testCountTag: "1"}
+	failingKey := testTotalTag
+	updateTag := func(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+		if tagValue == nil {
+			if curValue, hasTag := resource.Tags[*tagKey]; hasTag && liveTags[*tagKey] == curValue {
+				delete(liveTags, *tagKey)
+			}
+			return nil
+		}
+		if *tagKey == failingKey {
+			return fmt.Errorf("Could not update the tag")
+		}
+
+		liveTags[*tagKey] = *tagValue
+		return nil
+	}
+
+	resource := &types.InfraResource{ID: "vm-1", Tags: map[string]string{testCountTag: "1"}}
+	updates := []*tagUpdate{
+		{key: testExpiryTag, value: "2020-06-01T00:00:00Z"},
+		{key: testCountTag, value: "2"},
+		{key: testTotalTag, value: "48h0m0s"},
+	}
+	updateErr := updateTags(resource, updates, updateTag)
+	if updateErr == nil {
+		t.Fatalf("Expecting the error of the failed update")
+	}
+	if len(liveTags) != 1 || liveTags[testCountTag] != "1" {
+		t.Errorf("Expecting the new expiry tag to be removed and the count tag to be restored; got %v", liveTags)
+	}
+	if len(resource.Tags) != 1 {
+		t.Errorf("Expecting the resource's tags not to change; got %v", resource.Tags)
+	}
+
+	failingKey = ""
+	updateErr = updateTags(resource, updates, updateTag)
+	if updateErr != nil || len(liveTags) != 3 || liveTags[testCountTag] != "2" {
+		t.Errorf("Expecting all the tags to be updated; got %v and error %v", liveTags, updateErr)
+	}
+}
@@ -0,0 +1,176 @@
+package prune
+
+// This file contains the logic for creating, saving and applying prune plans
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const propertyState = "state"
+
+// propertyAccountID is the property the providers that have accounts set to the resource's account
+const propertyAccountID = "account-id"
+
+// Plan lists the expired resources that will be pruned and the action that will be taken on each
+type Plan struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Resources []*PlanResource `json:"resources"`
+}
+
+// PlanResource is an expired resource in a Plan. The resource's state and tags are recorded so as
+// to be able to detect whether the resource has changed since the plan was created
+type PlanResource struct {
+	Provider     string               `json:"provider"`
+	Account      string               `json:"account,omitempty"`
+	ID           string               `json:"id"`
+	Location     string               `json:"location"`
+	ResourceType string               `json:"resource_type"`
+	ExpiryReason string               `json:"expiry_reason"`
+	ExpiryTime   time.Time            `json:"expiry_time"`
+	Action       string               `json:"action"`
+	State        string               `json:"state"`
+	Tags         map[string]string    `json:"tags"`
+	resource     *types.InfraResource // the resource the action will be taken on
+}
+
+// newPlanResource creates a PlanResource from the provided expired resource
+func newPlanResource(resource *types.InfraResource, expiryTime time.Time, expiryReason string, action string) *PlanResource {
+	return &PlanResource{
+		Provider:     resource.Provider,
+		Account:      resource.Properties[propertyAccountID],
+		ID:           resource.ID,
+		Location:     resource.Location,
+		ResourceType: resource.ResourceType,
+		ExpiryReason: expiryReason,
+		ExpiryTime:   expiryTime,
+		Action:       action,
+		State:        resource.Properties[propertyState],
+		Tags:         resource.Tags,
+		resource:     resource,
+	}
+}
+
+// describe returns a human readable description of the plan resource
+func (planResource *PlanResource) describe() string {
+	return fmt.Sprintf(
+		"%s %s resource '%s' in '%s' that expired on %s",
+		planResource.Provider,
+		planResource.ResourceType,
+		planResource.ID,
+		planResource.Location,
+		planResource.ExpiryTime.Format(time.RFC1123))
+}
+
+// savePlan writes the provided plan, as JSON, to the file in the provided path
+func savePlan(plan *Plan, path string) error {
+	planJSON, planErr := json.MarshalIndent(plan, "", "  ")
+	if planErr != nil {
+		return planErr
+	}
+
+	return ioutil.WriteFile(path, planJSON, 0644)
+}
+
+// loadPlan reads a plan previously saved using savePlan from the file in the provided path
+func loadPlan(path string) (*Plan, error) {
+	planJSON, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	plan := new(Plan)
+	unmarshalErr := json.Unmarshal(planJSON, plan)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("Could not parse the plan in '%s': %v", path, unmarshalErr)
+	}
+
+	return plan, nil
+}
+
+// getPlanFilter returns the filter to use to fetch the current version of the resources in the plan. The
+// accounts are only filtered if all the resources have one since the providers without accounts would
+// otherwise be filtered out
+func getPlanFilter(plan *Plan) *types.InfraFilter {
+	filter := &types.InfraFilter{}
+	accounts := []string{}
+	for _, curResource := range plan.Resources {
+		filter.Providers = appendIfMissing(filter.Providers, curResource.Provider)
+		filter.Regions = appendIfMissing(filter.Regions, curResource.Location)
+		filter.ResourceTypes = appendIfMissing(filter.ResourceTypes, curResource.ResourceType)
+		if accounts != nil && len(curResource.Account) > 0 {
+			accounts = appendIfMissing(accounts, curResource.Account)
+		} else {
+			accounts = nil
+		}
+	}
+	if len(accounts) > 0 {
+		filter.Accounts = accounts
+	}
+
+	return filter
+}
+
+func appendIfMissing(slice []string, value string) []string {
+	for _, curValue := range slice {
+		if curValue == value {
+			return slice
+		}
+	}
+
+	return append(slice, value)
+}
+
+// checkPlanDrift links the resources in the plan to their current versions in the provided list of
+// resources. A message is returned for each resource in the plan that no longer exists, or whose state
+// or tags have changed since the plan was created
+func checkPlanDrift(plan *Plan, resources []*types.InfraResource) []string {
+	resourceMap := make(map[string]*types.InfraResource)
+	for _, curResource := range resources {
+		resourceMap[getResourceKey(curResource.Provider, curResource.Properties[propertyAccountID], curResource.ResourceType, curResource.ID)] = curResource
+	}
+
+	driftMessages := []string{}
+	for _, curPlanResource := range plan.Resources {
+		curResource, exists := resourceMap[getResourceKey(curPlanResource.Provider, curPlanResource.Account, curPlanResource.ResourceType, curPlanResource.ID)]
+		if !exists {
+			driftMessages = append(driftMessages, fmt.Sprintf("The %s no longer exists", curPlanResource.describe()))
+			continue
+		}
+
+		curState := curResource.Properties[propertyState]
+		if curState != curPlanResource.State {
+			driftMessages = append(driftMessages, fmt.Sprintf("The state of the %s changed from '%s' to '%s'", curPlanResource.describe(), curPlanResource.State, curState))
+		}
+
+		if !tagsEqual(curPlanResource.Tags, curResource.Tags) {
+			driftMessages = append(driftMessages, fmt.Sprintf("The tags of the %s changed", curPlanResource.describe()))
+		}
+
+		curPlanResource.resource = curResource
+	}
+
+	return driftMessages
+}
+
+// getResourceKey returns the key identifying a resource. The account is part of the key since resources in
+// different accounts can have the same ID, e.g. Kubernetes namespaces in different contexts
+func getResourceKey(provider string, account string, resourceType string, id string) string {
+	return strings.ToLower(provider) + "/" + account + "/" + strings.ToLower(resourceType) + "/" + id
+}
+
+// tagsEqual checks whether the two sets of tags are the same. A nil set of tags is considered to be
+// equal to an empty set
+func tagsEqual(a map[string]string, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
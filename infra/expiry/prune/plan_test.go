@@ -0,0 +1,139 @@
+package prune
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+func getTestResource(state string, tags map[string]string) *types.InfraResource {
+	return &types.InfraResource{
+		Provider:     "testProvider",
+		ID:           "blah-id",
+		Location:     "eu-central-1",
+		ResourceType: "EC2",
+		LaunchTime:   time.Now(),
+		Tags:         tags,
+		Properties:   map[string]string{propertyState: state}}
+}
+
+// Test whether checkPlanDrift detects resources that have changed since the plan was created
+func TestCheckPlanDrift(t *testing.T) {
+	planResource := getTestResource("stopped", map[string]string{"Name": "test"})
+	plan := &Plan{
+		CreatedAt: time.Now(),
+		Resources: []*PlanResource{newPlanResource(planResource, time.Now(), "max-age", actionTerminate)},
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		curResource := getTestResource("stopped", map[string]string{"Name": "test"})
+		driftMessages := checkPlanDrift(plan, []*types.InfraResource{curResource})
+		if len(driftMessages) != 0 {
+			t.Errorf("Not expecting drift messages; got %v", driftMessages)
+		}
+		if plan.Resources[0].resource != curResource {
+			t.Errorf("Expecting the plan resource to be linked to the current version of the resource")
+		}
+	})
+
+	t.Run("state-changed", func(t *testing.T) {
+		curResource := getTestResource("running", map[string]string{"Name": "test"})
+		driftMessages := checkPlanDrift(plan, []*types.InfraResource{curResource})
+		if len(driftMessages) != 1 || !strings.Contains(driftMessages[0], "state") {
+			t.Errorf("Expecting one drift message about the state; got %v", driftMessages)
+		}
+	})
+
+	t.Run("tags-changed", func(t *testing.T) {
+		curResource := getTestResource("stopped", map[string]string{"Name": "test", "Owner": "someone"})
+		driftMessages := checkPlanDrift(plan, []*types.InfraResource{curResource})
+		if len(driftMessages) != 1 || !strings.Contains(driftMessages[0], "tags") {
+			t.Errorf("Expecting one drift message about the tags; got %v", driftMessages)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		driftMessages := checkPlanDrift(plan, []*types.InfraResource{})
+		if len(driftMessages) != 1 || !strings.Contains(driftMessages[0], "no longer exists") {
+			t.Errorf("Expecting one drift message about the missing resource; got %v", driftMessages)
+		}
+	})
+}
+
+// Test whether resources with the same ID in different accounts are told apart
+func TestCheckPlanDriftWithAccounts(t *testing.T) {
+	getAccountResource := func(account string, state string) *types.InfraResource {
+		resource := getTestResource(state, nil)
+		resource.Properties[propertyAccountID] = account
+		return resource
+	}
+
+	plan := &Plan{
+		CreatedAt: time.Now(),
+		Resources: []*PlanResource{newPlanResource(getAccountResource("111111111111", "stopped"), time.Now(), "max-age", actionTerminate)},
+	}
+	filter := getPlanFilter(plan)
+	if len(filter.Accounts) != 1 || filter.Accounts[0] != "111111111111" {
+		t.Errorf("Expecting the plan's account to be filtered; got %v", filter.Accounts)
+	}
+
+	planResource := getAccountResource("111111111111", "stopped")
+	otherResource := getAccountResource("222222222222", "running")
+	driftMessages := checkPlanDrift(plan, []*types.InfraResource{planResource, otherResource})
+	if len(driftMessages) != 0 || plan.Resources[0].resource != planResource {
+		t.Errorf("Expecting the plan resource to be linked to the resource in its account; got %v", driftMessages)
+	}
+
+	driftMessages = checkPlanDrift(plan, []*types.InfraResource{otherResource})
+	if len(driftMessages) != 1 || !strings.Contains(driftMessages[0], "no longer exists") {
+		t.Errorf("Expecting the resource in the other account not to be used; got %v", driftMessages)
+	}
+
+	plan.Resources = append(plan.Resources, newPlanResource(getTestResource("stopped", nil), time.Now(), "max-age", actionTerminate))
+	if filter := getPlanFilter(plan); len(filter.Accounts) != 0 {
+		t.Errorf("Expecting accounts not to be filtered when a resource doesn't have one; got %v", filter.Accounts)
+	}
+}
+
+// Test whether a saved plan can be loaded back
+func TestSaveLoadPlan(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "prune-plan")
+	if dirErr != nil {
+		t.Fatalf("Could not create temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "plan.json")
+	plan := &Plan{
+		CreatedAt: time.Now(),
+		Resources: []*PlanResource{
+			newPlanResource(getTestResource("stopped", map[string]string{"Name": "test"}), time.Now(), "expiry-tag", actionStop),
+		},
+	}
+
+	saveErr := savePlan(plan, path)
+	if saveErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", saveErr)
+	}
+
+	loadedPlan, loadErr := loadPlan(path)
+	if loadErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", loadErr)
+	}
+	if len(loadedPlan.Resources) != 1 {
+		t.Fatalf("Expecting the loaded plan to have 1 resource; got %d", len(loadedPlan.Resources))
+	}
+
+	loadedResource := loadedPlan.Resources[0]
+	if loadedResource.ID != "blah-id" || loadedResource.Action != actionStop || loadedResource.ExpiryReason != "expiry-tag" {
+		t.Errorf("Loaded plan resource doesn't match the saved one: %+v", loadedResource)
+	}
+	if loadedResource.Tags["Name"] != "test" || loadedResource.State != "stopped" {
+		t.Errorf("Expecting the resource's tags and state to be saved: %+v", loadedResource)
+	}
+}
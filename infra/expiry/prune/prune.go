@@ -1,29 +1,32 @@
 package prune
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/onaio/sre-tooling/infra/expiry/query"
 	"github.com/onaio/sre-tooling/libs/cli"
 	"github.com/onaio/sre-tooling/libs/cli/flags"
+	"github.com/onaio/sre-tooling/libs/infra"
 	"github.com/onaio/sre-tooling/libs/notification"
 	"github.com/onaio/sre-tooling/libs/types"
 )
 
-// Make sure there's a -safe flag (that's defaulted to true) where, e.g you are not allowed to terminate VMs that aren't already shut down
-// Make sure there's an -action flag (with possible values being 'terminate' & 'shutdown' and default value is 'shutdown') for controlling what action
-//  should be taken on expired resources
-// Make sure at least one filter flag (region, resource, tag) is provided to avoid catastrophic situations where all resources in an entire
-// cloud account are shut-down
-// Make sure there's a -yes flag (defaulted to false) that if not set to true will require a confirmation before resources are pruned
-
 const name string = "prune"
 const actionStop string = "stop"
 const actionTerminate string = "terminate"
 
-// Prune queries then notifies (using configured notification channels) infrastructure that has expired
+// actionStates maps the actions that can be taken on expired resources to
+// the resource states passed to infra.UpdateResourceState
+var actionStates = map[string]string{
+	actionStop:      types.ResourceStateStopped,
+	actionTerminate: types.ResourceStateTerminated,
+}
+
+// Prune stops or terminates infrastructure that has expired
 type Prune struct {
 	helpFlag             *bool
 	flagSet              *flag.FlagSet
@@ -31,13 +34,19 @@ type Prune struct {
 	regionFlag           *flags.StringArray
 	typeFlag             *flags.StringArray
 	tagFlag              *flags.StringArray
+	accountFlag          *flags.StringArray
+	expressionFlag       *string
 	maxAgeFlag           *string
 	expiryTagFlag        *string
 	expiryTagNAValueFlag *string
 	expiryTagFormatFlag  *string
-	safeFlag             *bool
+	protectTagFlag       *flags.StringArray
+	unsafeFlag           *bool
 	actionFlag           *string
 	yesFlag              *bool
+	dryRunFlag           *bool
+	savePlanFlag         *string
+	applyPlanFlag        *string
 	subCommands          []cli.Command
 }
 
@@ -45,18 +54,24 @@ type Prune struct {
 func (prune *Prune) Init(helpFlagName string, helpFlagDescription string) {
 	prune.flagSet = flag.NewFlagSet(prune.GetName(), flag.ExitOnError)
 	prune.helpFlag = prune.flagSet.Bool(helpFlagName, false, helpFlagDescription)
-	prune.safeFlag = prune.flagSet.Bool("unsafe", false, "If set to true, command will not error if you try to terminate a resource that is stoppable but not stopped")
+	prune.unsafeFlag = prune.flagSet.Bool("unsafe", false, "If set to true, command will not error if you try to terminate a resource that is stoppable but not stopped")
 	prune.actionFlag = prune.flagSet.String("action", actionStop, fmt.Sprintf("What action to take on a resource that has expired. Possible values are '%s' and '%s'", actionStop, actionTerminate))
 	prune.yesFlag = prune.flagSet.Bool("yes", false, "Whether to skip requiring a confirmation before pruning a resource")
+	prune.dryRunFlag = prune.flagSet.Bool("dry-run", false, "Whether to only print the plan (the expired resources and the action that would be taken on each) without pruning any resource")
+	prune.savePlanFlag = prune.flagSet.String("save-plan", "", "Path to the file to save the plan to, as JSON. Implies -dry-run")
+	prune.applyPlanFlag = prune.flagSet.String("apply-plan", "", "Path to a plan saved using -save-plan to apply. Only the resources in the plan will be pruned, using the actions in the plan. Filter, expiry and -action flags are ignored")
 
 	prune.providerFlag,
 		prune.regionFlag,
 		prune.typeFlag,
 		prune.tagFlag,
+		prune.accountFlag,
+		prune.expressionFlag,
 		prune.maxAgeFlag,
 		prune.expiryTagFlag,
 		prune.expiryTagNAValueFlag,
-		prune.expiryTagFormatFlag = query.AddQueryFlags(prune.flagSet)
+		prune.expiryTagFormatFlag,
+		prune.protectTagFlag = query.AddQueryFlags(prune.flagSet)
 }
 
 // GetName returns the value of the name constant
@@ -86,19 +101,46 @@ func (prune *Prune) GetHelpFlag() *bool {
 }
 
 // Process fetches the list of infrastructure that matches the criteria provided by the user
-// and that has expired and sends notifications to the configured notification channels
+// and that has expired, then stops or terminates each of the expired resources
 func (prune *Prune) Process() {
+	if len(*prune.applyPlanFlag) > 0 {
+		prune.applyPlan()
+		return
+	}
+
+	_, actionOk := actionStates[*prune.actionFlag]
+	if !actionOk {
+		notification.SendMessage(fmt.Sprintf("Unrecognized action '%s'", *prune.actionFlag))
+		cli.ExitCommandInterpretationError()
+	}
+
+	// Avoid catastrophic situations where all the resources in an entire cloud account are pruned
+	if len(*prune.regionFlag) == 0 &&
+		len(*prune.typeFlag) == 0 &&
+		len(*prune.tagFlag) == 0 &&
+		len(*prune.accountFlag) == 0 &&
+		len(*prune.expressionFlag) == 0 {
+		notification.SendMessage("You need to filter resources using at least one region, type, tag, account, or filter expression")
+		cli.ExitCommandInterpretationError()
+	}
+
 	hasResourceErr := false
-	resourceErr := query.GetExpiredResources(
+	plan := &Plan{CreatedAt: time.Now(), Resources: []*PlanResource{}}
+	// Never prune resources based on cached expiry tags that might have been extended since
+	protectedResources, resourceErr := query.GetExpiredResources(
+		infra.WithoutCache(context.Background()),
 		prune.providerFlag,
 		prune.regionFlag,
 		prune.typeFlag,
 		prune.tagFlag,
+		prune.accountFlag,
+		prune.expressionFlag,
 		prune.maxAgeFlag,
 		prune.expiryTagFlag,
 		prune.expiryTagNAValueFlag,
 		prune.expiryTagFormatFlag,
-		func(resource *types.InfraResource, hasExpired bool, expiryTime time.Time, err error) {
+		prune.protectTagFlag,
+		func(resource *types.InfraResource, hasExpired bool, expiryTime time.Time, expiryReason string, err error) {
 			if err != nil {
 				notification.SendMessage(fmt.Errorf("Could not figure out which resources have expired: %w", err).Error())
 				hasResourceErr = true
@@ -109,7 +151,7 @@ func (prune *Prune) Process() {
 				return
 			}
 
-			// prune the resource
+			plan.Resources = append(plan.Resources, newPlanResource(resource, expiryTime, expiryReason, *prune.actionFlag))
 		},
 	)
 
@@ -121,4 +163,168 @@ func (prune *Prune) Process() {
 	if hasResourceErr {
 		cli.ExitCommandExecutionError()
 	}
+
+	prune.printProtectedResources(protectedResources)
+
+	if *prune.dryRunFlag || len(*prune.savePlanFlag) > 0 {
+		prune.printPlan(plan)
+
+		if len(*prune.savePlanFlag) > 0 {
+			saveErr := savePlan(plan, *prune.savePlanFlag)
+			if saveErr != nil {
+				notification.SendMessage(fmt.Errorf("Could not save the plan: %v", saveErr).Error())
+				cli.ExitCommandExecutionError()
+			}
+		}
+
+		return
+	}
+
+	prune.prune(plan)
+}
+
+// applyPlan prunes the resources in the plan file provided by the user, after making sure none of the
+// resources has changed since the plan was created
+func (prune *Prune) applyPlan() {
+	plan, planErr := loadPlan(*prune.applyPlanFlag)
+	if planErr != nil {
+		notification.SendMessage(fmt.Errorf("Could not load the plan: %v", planErr).Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	if len(plan.Resources) == 0 {
+		return
+	}
+
+	for _, curPlanResource := range plan.Resources {
+		if _, actionOk := actionStates[curPlanResource.Action]; !actionOk {
+			notification.SendMessage(fmt.Sprintf("Unrecognized action '%s' for the %s", curPlanResource.Action, curPlanResource.describe()))
+			cli.ExitCommandInterpretationError()
+		}
+	}
+
+	allResources, resourcesErr := infra.GetResourcesUncached(getPlanFilter(plan))
+	if resourcesErr != nil {
+		notification.SendMessage(fmt.Errorf("Could not get the list of cloud resources: %v", resourcesErr).Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	driftMessages := checkPlanDrift(plan, allResources)
+	if len(driftMessages) > 0 {
+		notification.SendMessage(fmt.Sprintf("Not applying the plan since some resources changed after it was created:\n%s", strings.Join(driftMessages, "\n")))
+		cli.ExitCommandExecutionError()
+	}
+
+	prune.prune(plan)
+}
+
+// printPlan sends the plan to the configured notification channels
+func (prune *Prune) printPlan(plan *Plan) {
+	if len(plan.Resources) == 0 {
+		notification.SendMessage("No expired resources to prune")
+		return
+	}
+
+	rows := []map[string]string{}
+	for _, curPlanResource := range plan.Resources {
+		rows = append(rows, map[string]string{
+			"Provider":      curPlanResource.Provider,
+			"Type":          curPlanResource.ResourceType,
+			"ID":            curPlanResource.ID,
+			"Location":      curPlanResource.Location,
+			"Expiry Reason": curPlanResource.ExpiryReason,
+			"Expiry Time":   curPlanResource.ExpiryTime.Format(time.RFC1123),
+			"State":         curPlanResource.State,
+			"Action":        curPlanResource.Action,
+		})
+	}
+
+	table := renderTable([]string{"Provider", "Type", "ID", "Location", "Expiry Reason", "Expiry Time", "State", "Action"}, rows)
+	notification.SendMessage(fmt.Sprintf("Prune plan:\n%s", table))
+}
+
+// printProtectedResources sends the list of expired resources that won't be pruned because they
+// are protected to the configured notification channels
+func (prune *Prune) printProtectedResources(protectedResources []*types.InfraResource) {
+	if len(protectedResources) == 0 {
+		return
+	}
+
+	rows := []map[string]string{}
+	for _, curResource := range protectedResources {
+		rows = append(rows, map[string]string{
+			"Provider": curResource.Provider,
+			"Type":     curResource.ResourceType,
+			"ID":       curResource.ID,
+			"Location": curResource.Location,
+		})
+	}
+
+	table := renderTable([]string{"Provider", "Type", "ID", "Location"}, rows)
+	notification.SendMessage(fmt.Sprintf("Skipping the following expired resources since they are protected:\n%s", table))
+}
+
+// renderTable renders the provided rows in a table with the provided columns, in the order provided
+func renderTable(columns []string, rows []map[string]string) string {
+	showFlag := new(flags.StringArray)
+	headers := make(map[string]bool)
+	for _, curColumn := range columns {
+		showFlag.Set(curColumn)
+		headers[curColumn] = true
+	}
+
+	hideHeaders := false
+	csv := false
+	fieldSeparator := "\t"
+	resourceSeparator := "\n"
+	listFields := false
+	defaultFieldValue := ""
+	output := infra.OutputTable
+	template := ""
+	templateFile := ""
+	rt := new(infra.ResourceTable)
+	rt.Init(showFlag, &hideHeaders, &csv, &fieldSeparator, &resourceSeparator, &listFields, &defaultFieldValue, &output, &template, &templateFile)
+	table, tableErr := rt.Render(headers, rows)
+	if tableErr != nil {
+		notification.SendMessage(tableErr.Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	return table
+}
+
+// prune takes the action in the plan on each of the plan's resources, requesting for a confirmation
+// first if the user didn't provide the -yes flag
+func (prune *Prune) prune(plan *Plan) {
+	hasPruneErr := false
+	for _, curPlanResource := range plan.Resources {
+		description := curPlanResource.describe()
+
+		if !*prune.yesFlag {
+			confirmed, confirmErr := cli.Confirm(fmt.Sprintf("Do you want to %s the %s?", curPlanResource.Action, description))
+			if confirmErr != nil {
+				notification.SendMessage(fmt.Errorf("Could not read the confirmation: %v", confirmErr).Error())
+				cli.ExitCommandExecutionError()
+			}
+
+			if !confirmed {
+				notification.SendMessage(fmt.Sprintf("Skipped the %s", description))
+				continue
+			}
+		}
+
+		state := actionStates[curPlanResource.Action]
+		pruneErr := infra.UpdateResourceState(curPlanResource.resource, !*prune.unsafeFlag, state)
+		if pruneErr != nil {
+			notification.SendMessage(fmt.Errorf("Could not %s the %s: %v", curPlanResource.Action, description, pruneErr).Error())
+			hasPruneErr = true
+			continue
+		}
+
+		notification.SendMessage(fmt.Sprintf("Requested the %s to be %s", description, state))
+	}
+
+	if hasPruneErr {
+		cli.ExitCommandExecutionError()
+	}
 }
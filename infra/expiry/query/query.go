@@ -1,8 +1,11 @@
 package query
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,10 +21,25 @@ const name string = "query"
 const defaultTimeFormat string = "2006-01-02"
 const defaultExpiryTagNAValue string = "-"
 const dataFieldExpiryTime = "expiry-time"
+const dataFieldExpiryReason = "expiry-reason"
+const dataFieldTimeRemaining = "time-remaining"
+const dataFieldExtensionCount = "extension-count"
+const defaultOwnerTag = "OwnerList"
+const protectTagSeparator = ":"
+
+// DefaultExtensionCountTag is the default name of the tag storing the number of times a
+// resource's expiry time has been extended
+const DefaultExtensionCountTag = "ExpiryExtensionCount"
 const outputFormatPlain = "plain"
 const outputFormatMarkdown = "markdown"
 
-type ExpiredResourceHandler func(resource *types.InfraResource, hasExpired bool, expiryTime time.Time, err error)
+// ExpiryReasonMaxAge is the expiry reason for resources that have surpassed the maximum age
+const ExpiryReasonMaxAge = "max-age"
+
+// ExpiryReasonExpiryTag is the expiry reason for resources whose expiry tag time has matured
+const ExpiryReasonExpiryTag = "expiry-tag"
+
+type ExpiredResourceHandler func(resource *types.InfraResource, hasExpired bool, expiryTime time.Time, expiryReason string, err error)
 
 // Query queries then notifies (using configured notification channels) infrastructure that has expired
 type Query struct {
@@ -31,10 +49,13 @@ type Query struct {
 	regionFlag            *flags.StringArray
 	typeFlag              *flags.StringArray
 	tagFlag               *flags.StringArray
+	accountFlag           *flags.StringArray
+	expressionFlag        *string
 	maxAgeFlag            *string
 	expiryTagFlag         *string
 	expiryTagNAValueFlag  *string
 	expiryTagFormatFlag   *string
+	protectTagFlag        *flags.StringArray
 	showFlag              *flags.StringArray
 	hideHeadersFlag       *bool
 	csvFlag               *bool
@@ -42,7 +63,15 @@ type Query struct {
 	resourceSeparatorFlag *string
 	listFieldsFlag        *bool
 	defaultFieldValueFlag *string
+	outputFlag            *string
+	templateFlag          *string
+	templateFileFlag      *string
 	outputFormatFlag      *string
+	warnBeforeFlag        *string
+	ownerTagFlag          *string
+	extensionCountTagFlag *string
+	maxExtensionsFlag     *int
+	noCacheFlag           *bool
 	subCommands           []cli.Command
 }
 
@@ -57,15 +86,22 @@ func (query *Query) Init(helpFlagName string, helpFlagDescription string) {
 			"How to format the full output text. Possible values are '%s' and '%s'.",
 			outputFormatPlain,
 			outputFormatMarkdown))
+	query.warnBeforeFlag = query.flagSet.String("warn-before", "", "If set, instead of reporting resources that have expired, report resources that will expire within this duration e.g '72h'. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', and 'h'.")
+	query.ownerTagFlag = query.flagSet.String("owner-tag", defaultOwnerTag, "Name of the tag storing the owner of the resource. Used to group the resources reported when -warn-before is set")
+	query.extensionCountTagFlag = query.flagSet.String("extension-count-tag", DefaultExtensionCountTag, "Name of the tag storing the number of times the resource's expiry time has been extended")
+	query.maxExtensionsFlag = query.flagSet.Int("max-extensions", 0, "If greater than 0, also report resources whose expiry time has been extended at least this number of times")
 
 	query.providerFlag,
 		query.regionFlag,
 		query.typeFlag,
 		query.tagFlag,
+		query.accountFlag,
+		query.expressionFlag,
 		query.maxAgeFlag,
 		query.expiryTagFlag,
 		query.expiryTagNAValueFlag,
-		query.expiryTagFormatFlag = AddQueryFlags(query.flagSet)
+		query.expiryTagFormatFlag,
+		query.protectTagFlag = AddQueryFlags(query.flagSet)
 
 	query.showFlag,
 		query.hideHeadersFlag,
@@ -73,7 +109,11 @@ func (query *Query) Init(helpFlagName string, helpFlagDescription string) {
 		query.fieldSeparatorFlag,
 		query.resourceSeparatorFlag,
 		query.listFieldsFlag,
-		query.defaultFieldValueFlag = infra.AddResourceTableFlags(query.flagSet)
+		query.defaultFieldValueFlag,
+		query.outputFlag,
+		query.templateFlag,
+		query.templateFileFlag = infra.AddResourceTableFlags(query.flagSet)
+	query.noCacheFlag = infra.AddCacheFlag(query.flagSet)
 
 }
 
@@ -83,25 +123,45 @@ func (query *Query) Init(helpFlagName string, helpFlagDescription string) {
 //	- Expiry tag flag
 //	- Expiry tag not applicable value
 //	- Expiry tag format flag
-func AddQueryFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *string, *string, *string, *string) {
+//	- Protect tag flag
+func AddQueryFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *string, *string, *string, *string, *string, *flags.StringArray) {
 	providerFlag,
 		regionFlag,
 		typeFlag,
-		tagFlag := infra.AddFilterFlags(flagSet)
+		tagFlag,
+		accountFlag,
+		expressionFlag := infra.AddFilterFlags(flagSet)
 
 	maxAgeFlag := flagSet.String("max-age", "", "Maximum age of a resource e.g '1h' to mean one hour. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', and 'h'.")
-	expiryTagFlag := flagSet.String("expiry-tag", "", "Name of the tag storing the time when the resource will expire")
-	expiryTagNAValue := flagSet.String("expiry-tag-na-value", defaultExpiryTagNAValue, "Value for the expiry tag that symbolizes that resource doesn't expire")
-	expiryTagFormatFlag := flagSet.String("expiry-tag-format", defaultTimeFormat, "The format of the time in the tag specified in -expiry-tag. Check the Golang time documentation on example formats here -> https://golang.org/pkg/time/#pkg-constants.")
+	expiryTagFlag,
+		expiryTagNAValue,
+		expiryTagFormatFlag := AddExpiryTagFlags(flagSet)
+	protectTagFlag := new(flags.StringArray)
+	flagSet.Var(protectTagFlag, "protect-tag", "Tag marking a resource as protected. Protected resources are never considered to be expired. Use the format \"tagKey"+protectTagSeparator+"tagValue\". Multiple values can be provided by specifying multiple -protect-tag")
 
 	return providerFlag,
 		regionFlag,
 		typeFlag,
 		tagFlag,
+		accountFlag,
+		expressionFlag,
 		maxAgeFlag,
 		expiryTagFlag,
 		expiryTagNAValue,
-		expiryTagFormatFlag
+		expiryTagFormatFlag,
+		protectTagFlag
+}
+
+// AddExpiryTagFlags returns the flags describing the tag storing a resource's expiry time in the order:
+//	- Expiry tag flag
+//	- Expiry tag not applicable value
+//	- Expiry tag format flag
+func AddExpiryTagFlags(flagSet *flag.FlagSet) (*string, *string, *string) {
+	expiryTagFlag := flagSet.String("expiry-tag", "", "Name of the tag storing the time when the resource will expire")
+	expiryTagNAValue := flagSet.String("expiry-tag-na-value", defaultExpiryTagNAValue, "Value for the expiry tag that symbolizes that resource doesn't expire")
+	expiryTagFormatFlag := flagSet.String("expiry-tag-format", defaultTimeFormat, "The format of the time in the tag specified in -expiry-tag. Check the Golang time documentation on example formats here -> https://golang.org/pkg/time/#pkg-constants.")
+
+	return expiryTagFlag, expiryTagNAValue, expiryTagFormatFlag
 }
 
 // GetName returns the value of the name constant
@@ -111,7 +171,7 @@ func (query *Query) GetName() string {
 
 // GetDescription returns the description for the query command
 func (query *Query) GetDescription() string {
-	return "Notifies, using configured notification channels, infrastructure that has expired"
+	return "Notifies, using configured notification channels, infrastructure that has expired or is about to expire"
 }
 
 // GetFlagSet returns a pointer to the flag.FlagSet associated to the command
@@ -131,36 +191,66 @@ func (query *Query) GetHelpFlag() *bool {
 }
 
 // Process fetches the list of infrastructure that matches the criteria provided by the user
-// and that has expired and sends notifications to the configured notification channels
+// and that has expired (or will expire within the duration in -warn-before) and sends notifications
+// to the configured notification channels
 func (query *Query) Process() {
+	if *query.noCacheFlag {
+		infra.NoCache = true
+	}
+
+	warnBefore := time.Duration(0)
+	if len(*query.warnBeforeFlag) > 0 {
+		warnBeforeDuration, warnBeforeErr := time.ParseDuration(*query.warnBeforeFlag)
+		if warnBeforeErr != nil || warnBeforeDuration <= 0 {
+			notification.SendMessage(fmt.Sprintf("Unable to process the value of -warn-before '%s'. A positive duration is expected", *query.warnBeforeFlag))
+			cli.ExitCommandInterpretationError()
+		}
+		warnBefore = warnBeforeDuration
+	}
+
 	hasResourceErr := false
-	var expiredResources []*types.InfraResource
-	resourceErr := GetExpiredResources(
+	var reportedResources []*types.InfraResource
+	var extendedResources []*types.InfraResource
+	protectedResources, resourceErr := GetExpiredResources(
+		context.Background(),
 		query.providerFlag,
 		query.regionFlag,
 		query.typeFlag,
 		query.tagFlag,
+		query.accountFlag,
+		query.expressionFlag,
 		query.maxAgeFlag,
 		query.expiryTagFlag,
 		query.expiryTagNAValueFlag,
 		query.expiryTagFormatFlag,
-		func(resource *types.InfraResource, hasExpired bool, expiryTime time.Time, err error) {
+		query.protectTagFlag,
+		func(resource *types.InfraResource, hasExpired bool, expiryTime time.Time, expiryReason string, err error) {
 			if err != nil {
 				notification.SendMessage(fmt.Errorf("Could not figure out which resources have expired: %w", err).Error())
 				hasResourceErr = true
 				return
 			}
 
-			if !hasExpired {
-				return
+			if *query.maxExtensionsFlag > 0 {
+				extensionCount, _ := strconv.Atoi(strings.TrimSpace(resource.Tags[*query.extensionCountTagFlag]))
+				if extensionCount >= *query.maxExtensionsFlag {
+					setResourceData(resource, dataFieldExtensionCount, strconv.Itoa(extensionCount))
+					extendedResources = append(extendedResources, resource)
+				}
 			}
 
-			if resource.Data == nil {
-				resource.Data = make(map[string]string)
+			if warnBefore > 0 {
+				if hasExpired || !isExpiringWithin(expiryTime, warnBefore) {
+					return
+				}
+			} else if !hasExpired {
+				return
 			}
 
-			resource.Data[dataFieldExpiryTime] = expiryTime.Format(time.RFC1123)
-			expiredResources = append(expiredResources, resource)
+			setResourceData(resource, dataFieldExpiryTime, expiryTime.Format(time.RFC1123))
+			setResourceData(resource, dataFieldExpiryReason, expiryReason)
+			setResourceData(resource, dataFieldTimeRemaining, time.Until(expiryTime).Round(time.Minute).String())
+			reportedResources = append(reportedResources, resource)
 		},
 	)
 
@@ -173,10 +263,58 @@ func (query *Query) Process() {
 		cli.ExitCommandExecutionError()
 	}
 
-	if len(expiredResources) == 0 {
+	if len(protectedResources) > 0 {
+		query.sendResourceTable("Some cloud resources have expired but were skipped since they are protected:", protectedResources)
+	}
+
+	if len(extendedResources) > 0 {
+		query.sendResourceTable(
+			fmt.Sprintf("Some cloud resources have had their expiry time extended %d or more times:", *query.maxExtensionsFlag),
+			extendedResources)
+	}
+
+	if len(reportedResources) == 0 {
+		if len(extendedResources) > 0 {
+			cli.ExitCommandExecutionError()
+		}
+
 		return
 	}
 
+	if warnBefore == 0 {
+		query.sendResourceTable("Some cloud resources have expired:", reportedResources)
+		cli.ExitCommandExecutionError()
+	}
+
+	ownerResources := groupResourcesByTag(reportedResources, *query.ownerTagFlag)
+	owners := []string{}
+	for curOwner := range ownerResources {
+		owners = append(owners, curOwner)
+	}
+	sort.Strings(owners)
+
+	for _, curOwner := range owners {
+		message := fmt.Sprintf("Cloud resources owned by '%s' will expire within %s:", curOwner, warnBefore.String())
+		if len(curOwner) == 0 {
+			message = fmt.Sprintf("Cloud resources without the '%s' tag will expire within %s:", *query.ownerTagFlag, warnBefore.String())
+		}
+		query.sendResourceTable(message, ownerResources[curOwner])
+	}
+	cli.ExitCommandExecutionError()
+}
+
+// setResourceData sets the value of the provided data field in the resource
+func setResourceData(resource *types.InfraResource, field string, value string) {
+	if resource.Data == nil {
+		resource.Data = make(map[string]string)
+	}
+
+	resource.Data[field] = value
+}
+
+// sendResourceTable renders the provided resources in a table and sends the table, prefixed with
+// the provided message, to the configured notification channels
+func (query *Query) sendResourceTable(message string, resources []*types.InfraResource) {
 	rt := new(infra.ResourceTable)
 	rt.Init(
 		query.showFlag,
@@ -185,87 +323,181 @@ func (query *Query) Process() {
 		query.fieldSeparatorFlag,
 		query.resourceSeparatorFlag,
 		query.listFieldsFlag,
-		query.defaultFieldValueFlag)
-	table, tableErr := rt.RenderResources(expiredResources)
+		query.defaultFieldValueFlag,
+		query.outputFlag,
+		query.templateFlag,
+		query.templateFileFlag)
+	table, tableErr := rt.RenderResources(resources)
 	if tableErr != nil {
 		notification.SendMessage(tableErr.Error())
 	}
 
 	formattedOutput := ""
-	errorMessage := "Some cloud resources have expired:"
 	switch *query.outputFormatFlag {
 	case outputFormatMarkdown:
-		formattedOutput = fmt.Sprintf("%s\n```\n%s```", errorMessage, table)
+		formattedOutput = fmt.Sprintf("%s\n```\n%s```", message, table)
 	case outputFormatPlain:
-		formattedOutput = fmt.Sprintf("%s\n%s", errorMessage, table)
+		formattedOutput = fmt.Sprintf("%s\n%s", message, table)
 	default:
 		notification.SendMessage(fmt.Sprintf("Unrecognized output format '%s'", *query.outputFormatFlag))
 		cli.ExitCommandInterpretationError()
 	}
 
 	notification.SendMessage(formattedOutput)
-	cli.ExitCommandExecutionError()
 }
 
-// GetExpiredResources returns a list of expired resources
+// GetExpiredResources calls the provided handler for each of the resources matching the filters that
+// isn't protected. The resources are fetched using ctx, e.g. one made with infra.WithoutCache. Resources
+// that have expired but are protected by one of the tags in protectTagFlag are returned
 func GetExpiredResources(
+	ctx context.Context,
 	providerFlag *flags.StringArray,
 	regionFlag *flags.StringArray,
 	typeFlag *flags.StringArray,
 	tagFlag *flags.StringArray,
+	accountFlag *flags.StringArray,
+	expressionFlag *string,
 	maxAgeFlag *string,
 	expiryTagFlag *string,
 	expiryTagNAValueFlag *string,
 	expiryTagFormatFlag *string,
-	expiredResourceHandler ExpiredResourceHandler) error {
+	protectTagFlag *flags.StringArray,
+	expiredResourceHandler ExpiredResourceHandler) ([]*types.InfraResource, error) {
 
 	if len(*expiryTagFlag) == 0 && len(*maxAgeFlag) == 0 {
-		return fmt.Errorf("Either maximum age or expiry tag need to be provided")
+		return nil, fmt.Errorf("Either maximum age or expiry tag need to be provided")
 	}
 
 	if len(*expiryTagFlag) > 0 && len(*expiryTagFormatFlag) == 0 {
-		return fmt.Errorf("If the expiry tag is provided, then the expiry tag format also needs to be provided")
+		return nil, fmt.Errorf("If the expiry tag is provided, then the expiry tag format also needs to be provided")
+	}
+
+	for _, curProtectTag := range *protectTagFlag {
+		if !strings.Contains(curProtectTag, protectTagSeparator) {
+			return nil, fmt.Errorf("Protect tag '%s' should be in the format \"tagKey%stagValue\"", curProtectTag, protectTagSeparator)
+		}
+	}
+
+	filter, filterErr := infra.GetFiltersFromCommandFlags(
+		providerFlag,
+		regionFlag,
+		typeFlag,
+		tagFlag,
+		accountFlag,
+		expressionFlag)
+	if filterErr != nil {
+		return nil, filterErr
 	}
 
-	allResources, resourcesErr := infra.GetResources(
-		infra.GetFiltersFromCommandFlags(
-			providerFlag,
-			regionFlag,
-			typeFlag,
-			tagFlag))
+	allResources, resourcesErr := infra.GetResourcesWithContext(ctx, filter)
 
 	if resourcesErr != nil {
-		return fmt.Errorf("Could not get the list of cloud resources: %w", resourcesErr)
+		return nil, fmt.Errorf("Could not get the list of cloud resources: %v", resourcesErr)
 	}
 
+	protectedResources := []*types.InfraResource{}
 	for _, curResource := range allResources {
-		hasExpired, expiryTime, hasExpiredErr := hasResourceExpired(curResource, maxAgeFlag, expiryTagFlag, expiryTagNAValueFlag, expiryTagFormatFlag)
-		expiredResourceHandler(curResource, hasExpired, expiryTime, hasExpiredErr)
+		hasExpired, expiryTime, expiryReason, hasExpiredErr := hasResourceExpired(curResource, maxAgeFlag, expiryTagFlag, expiryTagNAValueFlag, expiryTagFormatFlag)
+		if isResourceProtected(curResource, *protectTagFlag) {
+			if hasExpired {
+				setResourceData(curResource, dataFieldExpiryTime, expiryTime.Format(time.RFC1123))
+				setResourceData(curResource, dataFieldExpiryReason, expiryReason)
+				protectedResources = append(protectedResources, curResource)
+			}
+			continue
+		}
+
+		expiredResourceHandler(curResource, hasExpired, expiryTime, expiryReason, hasExpiredErr)
 	}
 
-	return nil
+	return protectedResources, nil
 }
 
-// hasResourceExpired checks whether a resource has expired using the provided maximum age and expiry time flag
-func hasResourceExpired(resource *types.InfraResource, maxAge *string, expiryTag *string, expiryTagNAValue, expiryTagFormat *string) (bool, time.Time, error) {
+// isResourceProtected checks whether the resource has any of the provided protect tags, in the
+// format "tagKey:tagValue". Tag values are compared case insensitively
+func isResourceProtected(resource *types.InfraResource, protectTags []string) bool {
+	for _, curProtectTag := range protectTags {
+		keyValue := strings.SplitN(curProtectTag, protectTagSeparator, 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+
+		if tagValue, tagDefined := resource.Tags[keyValue[0]]; tagDefined && strings.EqualFold(tagValue, keyValue[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasResourceExpired checks whether a resource has expired using the provided maximum age and expiry time flag.
+// The time when the resource expired (or will expire) is returned together with the reason (ExpiryReasonMaxAge or
+// ExpiryReasonExpiryTag). If the resource will never expire, the zero time is returned
+func hasResourceExpired(resource *types.InfraResource, maxAge *string, expiryTag *string, expiryTagNAValue, expiryTagFormat *string) (bool, time.Time, string, error) {
 	maxAgeReached, maxAgeExpiryTime, maxAgeReachedErr := hasMaxAgeReached(resource, maxAge)
 	if maxAgeReached {
-		return true, maxAgeExpiryTime, maxAgeReachedErr
+		return true, maxAgeExpiryTime, ExpiryReasonMaxAge, maxAgeReachedErr
 	}
 
 	expiryTimeMature, matureExpiryTime, expiryTimeMatureErr := hasExpiryTimeMatured(resource, expiryTag, expiryTagNAValue, expiryTagFormat)
 	if expiryTimeMature {
-		return true, matureExpiryTime, expiryTimeMatureErr
+		return true, matureExpiryTime, ExpiryReasonExpiryTag, expiryTimeMatureErr
 	}
 
 	// Only return the errors if all methods of evaluating expiry have failed
 	if maxAgeReachedErr != nil {
-		return false, maxAgeExpiryTime, maxAgeReachedErr
+		return false, maxAgeExpiryTime, "", maxAgeReachedErr
 	} else if expiryTimeMatureErr != nil {
-		return false, matureExpiryTime, expiryTimeMatureErr
+		return false, matureExpiryTime, "", expiryTimeMatureErr
+	}
+
+	// Resource hasn't expired. Return the earliest time when it will expire (zero time if it doesn't expire)
+	upcomingExpiryTime := time.Time{}
+	upcomingExpiryReason := ""
+	if len(*maxAge) > 0 {
+		upcomingExpiryTime = maxAgeExpiryTime
+		upcomingExpiryReason = ExpiryReasonMaxAge
+	}
+	if hasExpiryTime(resource, expiryTag, expiryTagNAValue) &&
+		(upcomingExpiryTime.IsZero() || matureExpiryTime.Before(upcomingExpiryTime)) {
+		upcomingExpiryTime = matureExpiryTime
+		upcomingExpiryReason = ExpiryReasonExpiryTag
+	}
+
+	return false, upcomingExpiryTime, upcomingExpiryReason, nil
+}
+
+// hasExpiryTime checks whether the provided resource has an expiry time set in the provided expiry tag
+func hasExpiryTime(resource *types.InfraResource, expiryTag *string, expiryTagNAValue *string) bool {
+	if len(*expiryTag) == 0 {
+		return false
+	}
+
+	expiryTimeString, expiryTagDefined := resource.Tags[*expiryTag]
+	return expiryTagDefined && strings.TrimSpace(expiryTimeString) != *expiryTagNAValue
+}
+
+// isExpiringWithin checks whether the provided expiry time is in the future, but within the provided duration.
+// The zero time is used to represent resources that never expire
+func isExpiringWithin(expiryTime time.Time, duration time.Duration) bool {
+	if expiryTime.IsZero() {
+		return false
+	}
+
+	timeRemaining := time.Until(expiryTime)
+	return timeRemaining >= 0 && timeRemaining <= duration
+}
+
+// groupResourcesByTag groups the provided resources using the value of the provided tag. Resources
+// without the tag are grouped under an empty string
+func groupResourcesByTag(resources []*types.InfraResource, tag string) map[string][]*types.InfraResource {
+	groups := make(map[string][]*types.InfraResource)
+	for _, curResource := range resources {
+		tagValue := strings.TrimSpace(curResource.Tags[tag])
+		groups[tagValue] = append(groups[tagValue], curResource)
 	}
 
-	return false, time.Now(), nil
+	return groups
 }
 
 // hasExpiryTimeMatured checks whether the expiry time in the provided resource tag has matured. Returns true if time
@@ -267,3 +267,127 @@ func TestHasExpiryTimeMaturedNotExpiredResource(t *testing.T) {
 		t.Errorf("Error should not be returned")
 	}
 }
+
+// Test whether hasResourceExpired returns the earliest upcoming expiry time for resources that haven't expired
+func TestHasResourceExpiredUpcomingExpiry(t *testing.T) {
+	expiryTagNAValue := defaultExpiryTagNAValue
+	timeFormat := time.RFC1123
+	tagName := "someRandomTag"
+	maxAge := "2h"
+
+	t.Run("max-age-earliest", func(t *testing.T) {
+		resource := types.InfraResource{
+			Provider:   "testProvider",
+			ID:         "blah-id",
+			Location:   "eu-central-1a",
+			LaunchTime: time.Now(),
+			Tags:       map[string]string{tagName: time.Now().Add(time.Hour * 24).Format(timeFormat)},
+			Properties: map[string]string{}}
+
+		hasExpired, expiryTime, expiryReason, expiredErr := hasResourceExpired(&resource, &maxAge, &tagName, &expiryTagNAValue, &timeFormat)
+		if hasExpired || expiredErr != nil {
+			t.Errorf("Resource should not be expired; got expired = %t, error = %v", hasExpired, expiredErr)
+		}
+		if expiryReason != ExpiryReasonMaxAge {
+			t.Errorf("Expiry reason = '%s'; want '%s'", expiryReason, ExpiryReasonMaxAge)
+		}
+		if !isExpiringWithin(expiryTime, 3*time.Hour) || isExpiringWithin(expiryTime, time.Hour) {
+			t.Errorf("Expecting expiry time to be around 2hrs from now; got '%s'", expiryTime.Format(time.RFC1123))
+		}
+	})
+
+	t.Run("expiry-tag-earliest", func(t *testing.T) {
+		resource := types.InfraResource{
+			Provider:   "testProvider",
+			ID:         "blah-id",
+			Location:   "eu-central-1a",
+			LaunchTime: time.Now(),
+			Tags:       map[string]string{tagName: time.Now().Add(time.Hour).Format(timeFormat)},
+			Properties: map[string]string{}}
+
+		hasExpired, expiryTime, expiryReason, expiredErr := hasResourceExpired(&resource, &maxAge, &tagName, &expiryTagNAValue, &timeFormat)
+		if hasExpired || expiredErr != nil {
+			t.Errorf("Resource should not be expired; got expired = %t, error = %v", hasExpired, expiredErr)
+		}
+		if expiryReason != ExpiryReasonExpiryTag {
+			t.Errorf("Expiry reason = '%s'; want '%s'", expiryReason, ExpiryReasonExpiryTag)
+		}
+		if !isExpiringWithin(expiryTime, 2*time.Hour) {
+			t.Errorf("Expecting expiry time to be around 1hr from now; got '%s'", expiryTime.Format(time.RFC1123))
+		}
+	})
+
+	t.Run("never-expires", func(t *testing.T) {
+		noMaxAge := ""
+		resource := types.InfraResource{
+			Provider:   "testProvider",
+			ID:         "blah-id",
+			Location:   "eu-central-1a",
+			LaunchTime: time.Now(),
+			Tags:       map[string]string{tagName: expiryTagNAValue},
+			Properties: map[string]string{}}
+
+		hasExpired, expiryTime, _, expiredErr := hasResourceExpired(&resource, &noMaxAge, &tagName, &expiryTagNAValue, &timeFormat)
+		if hasExpired || expiredErr != nil {
+			t.Errorf("Resource should not be expired; got expired = %t, error = %v", hasExpired, expiredErr)
+		}
+		if !expiryTime.IsZero() {
+			t.Errorf("Expecting the zero time for a resource that doesn't expire; got '%s'", expiryTime.Format(time.RFC1123))
+		}
+		if isExpiringWithin(expiryTime, time.Hour*24*365) {
+			t.Errorf("Resource that doesn't expire should never be considered to be expiring")
+		}
+	})
+}
+
+// Test whether groupResourcesByTag groups resources using the tag's value
+func TestGroupResourcesByTag(t *testing.T) {
+	ownerTag := "Owner"
+	resources := []*types.InfraResource{
+		{ID: "resource1", Tags: map[string]string{ownerTag: "alice"}},
+		{ID: "resource2", Tags: map[string]string{ownerTag: "bob"}},
+		{ID: "resource3", Tags: map[string]string{ownerTag: "alice"}},
+		{ID: "resource4", Tags: map[string]string{}},
+	}
+
+	groups := groupResourcesByTag(resources, ownerTag)
+	if len(groups["alice"]) != 2 {
+		t.Errorf("Expecting 2 resources owned by alice; got %d", len(groups["alice"]))
+	}
+	if len(groups["bob"]) != 1 {
+		t.Errorf("Expecting 1 resource owned by bob; got %d", len(groups["bob"]))
+	}
+	if len(groups[""]) != 1 {
+		t.Errorf("Expecting 1 resource without an owner; got %d", len(groups[""]))
+	}
+}
+
+// Test whether isResourceProtected matches the resource's tags against the protect tags
+func TestIsResourceProtected(t *testing.T) {
+	resource := &types.InfraResource{
+		ID:   "blah-id",
+		Tags: map[string]string{"Protected": "True", "Environment": "production"},
+	}
+
+	testCases := []struct {
+		name        string
+		protectTags []string
+		protected   bool
+	}{
+		{"no-protect-tags", []string{}, false},
+		{"matching-tag", []string{"Protected:true"}, true},
+		{"different-value", []string{"Protected:false"}, false},
+		{"missing-tag", []string{"DoNotDelete:yes"}, false},
+		{"any-matching-tag", []string{"DoNotDelete:yes", "Environment:production"}, true},
+		{"value-with-separator", []string{"Environment:production:eu"}, false},
+	}
+
+	for _, curTestCase := range testCases {
+		t.Run(curTestCase.name, func(t *testing.T) {
+			protected := isResourceProtected(resource, curTestCase.protectTags)
+			if protected != curTestCase.protected {
+				t.Errorf("Expecting protected to be %t; got %t", curTestCase.protected, protected)
+			}
+		})
+	}
+}
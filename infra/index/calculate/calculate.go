@@ -28,6 +28,8 @@ type Calculate struct {
 	regionFlag      *flags.StringArray
 	typeFlag        *flags.StringArray
 	tagFlag         *flags.StringArray
+	accountFlag     *flags.StringArray
+	expressionFlag  *string
 	idFlag          *string
 	indexTagFlag    *string
 	randomSleepFlag *int
@@ -43,6 +45,8 @@ func (calculate *Calculate) Init(helpFlagName string, helpFlagDescription string
 		calculate.regionFlag,
 		calculate.typeFlag,
 		calculate.tagFlag,
+		calculate.accountFlag,
+		calculate.expressionFlag,
 		calculate.idFlag,
 		calculate.indexTagFlag,
 		calculate.randomSleepFlag = AddCalculateFlags(calculate.flagSet)
@@ -54,11 +58,13 @@ func (calculate *Calculate) Init(helpFlagName string, helpFlagDescription string
 //    id: The ID of the resource
 //    index tag: The index tag to filter the resource group using
 //    random sleep: The maximum random number of seconds to sleep before calculating the index
-func AddCalculateFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *string, *string, *int) {
+func AddCalculateFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *string, *string, *string, *int) {
 	providerFlag,
 		regionFlag,
 		typeFlag,
-		tagFlag := infra.AddFilterFlags(flagSet)
+		tagFlag,
+		accountFlag,
+		expressionFlag := infra.AddFilterFlags(flagSet)
 
 	idFlag := flagSet.String("id", "", "The ID of the resource to check the index")
 	indexTagFlag := flagSet.String("index-tag", "", "The name of the tag containing the indexes of the resources")
@@ -68,6 +74,8 @@ func AddCalculateFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.String
 		regionFlag,
 		typeFlag,
 		tagFlag,
+		accountFlag,
+		expressionFlag,
 		idFlag,
 		indexTagFlag,
 		randomSleepFlag
@@ -109,6 +117,8 @@ func (calculate *Calculate) Process() {
 		calculate.regionFlag,
 		calculate.typeFlag,
 		calculate.tagFlag,
+		calculate.accountFlag,
+		calculate.expressionFlag,
 		calculate.idFlag,
 		calculate.indexTagFlag,
 	)
@@ -127,6 +137,8 @@ func FetchAndCalculateResourceIndex(
 	regionFlag *flags.StringArray,
 	typeFlag *flags.StringArray,
 	tagFlag *flags.StringArray,
+	accountFlag *flags.StringArray,
+	expressionFlag *string,
 	idFlag *string,
 	indexTagFlag *string) (int, error) {
 	if len(*idFlag) == 0 {
@@ -138,8 +150,9 @@ func FetchAndCalculateResourceIndex(
 
 	if len(*regionFlag) == 0 &&
 		len(*typeFlag) == 0 &&
-		len(*tagFlag) == 0 {
-		return -1, fmt.Errorf("You need to filter resources using at least one region, type, or tag")
+		len(*tagFlag) == 0 &&
+		len(*expressionFlag) == 0 {
+		return -1, fmt.Errorf("You need to filter resources using at least one region, type, tag, or filter expression")
 	}
 
 	// Sleep for some random amount of time
@@ -148,12 +161,18 @@ func FetchAndCalculateResourceIndex(
 		time.Sleep(time.Duration(sleepTime) * time.Second)
 	}
 
-	allResources, resourcesErr := infra.GetResources(
-		infra.GetFiltersFromCommandFlags(
-			providerFlag,
-			regionFlag,
-			typeFlag,
-			tagFlag))
+	filter, filterErr := infra.GetFiltersFromCommandFlags(
+		providerFlag,
+		regionFlag,
+		typeFlag,
+		tagFlag,
+		accountFlag,
+		expressionFlag)
+	if filterErr != nil {
+		return -1, filterErr
+	}
+
+	allResources, resourcesErr := infra.GetResourcesUncached(filter)
 	if resourcesErr != nil {
 		return -1, resourcesErr
 	}
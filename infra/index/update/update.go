@@ -25,6 +25,8 @@ type Update struct {
 	regionFlag      *flags.StringArray
 	typeFlag        *flags.StringArray
 	tagFlag         *flags.StringArray
+	accountFlag     *flags.StringArray
+	expressionFlag  *string
 	idFlag          *string
 	indexTagFlag    *string
 	randomSleepFlag *int
@@ -42,6 +44,8 @@ func (update *Update) Init(helpFlagName string, helpFlagDescription string) {
 		update.regionFlag,
 		update.typeFlag,
 		update.tagFlag,
+		update.accountFlag,
+		update.expressionFlag,
 		update.idFlag,
 		update.indexTagFlag,
 		update.randomSleepFlag = calculate.AddCalculateFlags(update.flagSet)
@@ -91,6 +95,8 @@ func (update *Update) Process() {
 		update.regionFlag,
 		update.typeFlag,
 		update.tagFlag,
+		update.accountFlag,
+		update.expressionFlag,
 		update.idFlag,
 		update.indexTagFlag,
 	)
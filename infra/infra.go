@@ -4,9 +4,12 @@ import (
 	"flag"
 
 	"github.com/onaio/sre-tooling/infra/bill"
+	"github.com/onaio/sre-tooling/infra/cache"
 	"github.com/onaio/sre-tooling/infra/expiry"
 	"github.com/onaio/sre-tooling/infra/index"
 	"github.com/onaio/sre-tooling/infra/query"
+	"github.com/onaio/sre-tooling/infra/snapshot"
+	"github.com/onaio/sre-tooling/infra/tag"
 	"github.com/onaio/sre-tooling/libs/cli"
 )
 
@@ -29,7 +32,13 @@ func (infra *Infra) Init(helpFlagName string, helpFlagDescription string) {
 	index.Init(helpFlagName, helpFlagDescription)
 	expiry := new(expiry.Expiry)
 	expiry.Init(helpFlagName, helpFlagDescription)
-	infra.subCommands = []cli.Command{bill, query, index, expiry}
+	snapshot := new(snapshot.Snapshot)
+	snapshot.Init(helpFlagName, helpFlagDescription)
+	cache := new(cache.Cache)
+	cache.Init(helpFlagName, helpFlagDescription)
+	tag := new(tag.Tag)
+	tag.Init(helpFlagName, helpFlagDescription)
+	infra.subCommands = []cli.Command{bill, query, index, expiry, snapshot, cache, tag}
 }
 
 func (infra *Infra) GetName() string {
@@ -7,6 +7,7 @@ import (
 	"github.com/onaio/sre-tooling/libs/cli/flags"
 	"github.com/onaio/sre-tooling/libs/infra"
 	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
 )
 
 const name string = "query"
@@ -18,6 +19,8 @@ type Query struct {
 	regionFlag            *flags.StringArray
 	typeFlag              *flags.StringArray
 	tagFlag               *flags.StringArray
+	accountFlag           *flags.StringArray
+	expressionFlag        *string
 	showFlag              *flags.StringArray
 	hideHeadersFlag       *bool
 	csvFlag               *bool
@@ -25,20 +28,28 @@ type Query struct {
 	resourceSeparatorFlag *string
 	listFieldsFlag        *bool
 	defaultFieldValueFlag *string
+	outputFlag            *string
+	templateFlag          *string
+	templateFileFlag      *string
+	noCacheFlag           *bool
 	subCommands           []cli.Command
 }
 
 func (query *Query) Init(helpFlagName string, helpFlagDescription string) {
 	query.flagSet = flag.NewFlagSet(query.GetName(), flag.ExitOnError)
 	query.helpFlag = query.flagSet.Bool(helpFlagName, false, helpFlagDescription)
-	query.providerFlag, query.regionFlag, query.typeFlag, query.tagFlag = infra.AddFilterFlags(query.flagSet)
+	query.providerFlag, query.regionFlag, query.typeFlag, query.tagFlag, query.accountFlag, query.expressionFlag = infra.AddFilterFlags(query.flagSet)
 	query.showFlag,
 		query.hideHeadersFlag,
 		query.csvFlag,
 		query.fieldSeparatorFlag,
 		query.resourceSeparatorFlag,
 		query.listFieldsFlag,
-		query.defaultFieldValueFlag = infra.AddResourceTableFlags(query.flagSet)
+		query.defaultFieldValueFlag,
+		query.outputFlag,
+		query.templateFlag,
+		query.templateFileFlag = infra.AddResourceTableFlags(query.flagSet)
+	query.noCacheFlag = infra.AddCacheFlag(query.flagSet)
 
 	query.subCommands = []cli.Command{}
 }
@@ -64,20 +75,32 @@ func (query *Query) GetHelpFlag() *bool {
 }
 
 func (query *Query) Process() {
-	if len(*query.regionFlag) == 0 && len(*query.typeFlag) == 0 && len(*query.tagFlag) == 0 {
-		notification.SendMessage("You need to filter resources using at least one region, type, or tag")
+	if *query.noCacheFlag {
+		infra.NoCache = true
+	}
+
+	if len(*query.regionFlag) == 0 && len(*query.typeFlag) == 0 && len(*query.tagFlag) == 0 && len(*query.expressionFlag) == 0 {
+		notification.SendMessage("You need to filter resources using at least one region, type, tag, or filter expression")
 		cli.ExitCommandInterpretationError()
 	}
 
-	allResources, resourcesErr := infra.GetResources(
-		infra.GetFiltersFromCommandFlags(
-			query.providerFlag,
-			query.regionFlag,
-			query.typeFlag,
-			query.tagFlag,
-		),
+	filter, filterErr := infra.GetFiltersFromCommandFlags(
+		query.providerFlag,
+		query.regionFlag,
+		query.typeFlag,
+		query.tagFlag,
+		query.accountFlag,
+		query.expressionFlag,
 	)
-	if resourcesErr != nil {
+	if filterErr != nil {
+		notification.SendMessage(filterErr.Error())
+		cli.ExitCommandInterpretationError()
+	}
+
+	// Render the resources that were fetched even if fetching the others failed
+	allResources, resourcesErr := infra.GetResources(filter)
+	failures, partial := resourcesErr.(types.InfraFailures)
+	if resourcesErr != nil && !partial {
 		notification.SendMessage(resourcesErr.Error())
 		cli.ExitCommandExecutionError()
 	}
@@ -90,7 +113,10 @@ func (query *Query) Process() {
 		query.fieldSeparatorFlag,
 		query.resourceSeparatorFlag,
 		query.listFieldsFlag,
-		query.defaultFieldValueFlag)
+		query.defaultFieldValueFlag,
+		query.outputFlag,
+		query.templateFlag,
+		query.templateFileFlag)
 	output, outputErr := rt.RenderResources(allResources)
 	if outputErr != nil {
 		notification.SendMessage(outputErr.Error())
@@ -98,4 +124,8 @@ func (query *Query) Process() {
 	}
 
 	notification.SendMessage(output)
+	if partial {
+		notification.SendMessage(infra.RenderFailures(failures))
+		cli.ExitCommandExecutionError()
+	}
 }
@@ -0,0 +1,192 @@
+package diff
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/onaio/sre-tooling/infra/snapshot/save"
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/cli/flags"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/infra/snapshot"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const name string = "diff"
+
+// Fields set in the data of the resources rendered for each change
+const (
+	dataFieldChange       = "change"
+	dataFieldProvider     = "provider"
+	dataFieldResourceType = "resource-type"
+	dataFieldID           = "id"
+	dataFieldField        = "field"
+	dataFieldOldValue     = "old-value"
+	dataFieldNewValue     = "new-value"
+)
+
+// defaultShownFields are the fields shown if -show isn't set
+var defaultShownFields = []string{
+	"data:" + dataFieldChange,
+	"data:" + dataFieldProvider,
+	"data:" + dataFieldResourceType,
+	"data:" + dataFieldID,
+	"data:" + dataFieldField,
+	"data:" + dataFieldOldValue,
+	"data:" + dataFieldNewValue,
+}
+
+// Diff reports the differences between two snapshots
+type Diff struct {
+	helpFlag              *bool
+	flagSet               *flag.FlagSet
+	dirFlag               *string
+	ignoreFieldFlag       *flags.StringArray
+	showFlag              *flags.StringArray
+	hideHeadersFlag       *bool
+	csvFlag               *bool
+	fieldSeparatorFlag    *string
+	resourceSeparatorFlag *string
+	listFieldsFlag        *bool
+	defaultFieldValueFlag *string
+	outputFlag            *string
+	templateFlag          *string
+	templateFileFlag      *string
+	subCommands           []cli.Command
+}
+
+// Init initializes the command object
+func (diff *Diff) Init(helpFlagName string, helpFlagDescription string) {
+	diff.flagSet = flag.NewFlagSet(diff.GetName(), flag.ExitOnError)
+	diff.helpFlag = diff.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	diff.dirFlag = save.AddDirFlag(diff.flagSet)
+	diff.ignoreFieldFlag = new(flags.StringArray)
+	diff.flagSet.Var(diff.ignoreFieldFlag, "ignore-field", "Tag or property not to compare e.g. \"property:public-ip\". Multiple values can be provided by specifying multiple -ignore-field")
+	diff.showFlag,
+		diff.hideHeadersFlag,
+		diff.csvFlag,
+		diff.fieldSeparatorFlag,
+		diff.resourceSeparatorFlag,
+		diff.listFieldsFlag,
+		diff.defaultFieldValueFlag,
+		diff.outputFlag,
+		diff.templateFlag,
+		diff.templateFileFlag = infra.AddResourceTableFlags(diff.flagSet)
+
+	diff.subCommands = []cli.Command{}
+}
+
+// GetName returns the value of the name constant
+func (diff *Diff) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the diff command
+func (diff *Diff) GetDescription() string {
+	return "Reports the changes between two snapshots, or the previous and latest snapshots if none are provided"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (diff *Diff) GetFlagSet() *flag.FlagSet {
+	return diff.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the diff command
+// (expect empty slice if none)
+func (diff *Diff) GetSubCommands() []cli.Command {
+	return diff.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (diff *Diff) GetHelpFlag() *bool {
+	return diff.helpFlag
+}
+
+// Process loads the two snapshots and renders the changes between them
+func (diff *Diff) Process() {
+	names := diff.flagSet.Args()
+	if len(names) == 0 {
+		names = []string{snapshot.NamePrevious, snapshot.NameLatest}
+	}
+	if len(names) != 2 {
+		notification.SendMessage("You need to provide the old and the new snapshots, or none to compare the previous and latest snapshots")
+		cli.ExitCommandInterpretationError()
+	}
+
+	dir, dirErr := save.GetDir(diff.dirFlag)
+	if dirErr != nil {
+		notification.SendMessage(dirErr.Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	snapshots := make([]*snapshot.Snapshot, len(names))
+	for i, curName := range names {
+		path, pathErr := snapshot.Resolve(dir, curName)
+		if pathErr != nil {
+			notification.SendMessage(pathErr.Error())
+			cli.ExitCommandExecutionError()
+		}
+
+		curSnapshot, loadErr := snapshot.Load(path)
+		if loadErr != nil {
+			notification.SendMessage(loadErr.Error())
+			cli.ExitCommandExecutionError()
+		}
+		snapshots[i] = curSnapshot
+	}
+
+	changes := snapshot.Diff(snapshots[0], snapshots[1], *diff.ignoreFieldFlag)
+	if len(changes) == 0 && *diff.outputFlag == infra.OutputTable && len(*diff.templateFlag) == 0 && len(*diff.templateFileFlag) == 0 {
+		notification.SendMessage(fmt.Sprintf("No changes between %s and %s", names[0], names[1]))
+		return
+	}
+
+	if len(*diff.showFlag) == 0 {
+		for _, curField := range defaultShownFields {
+			diff.showFlag.Set(curField)
+		}
+	}
+
+	rt := new(infra.ResourceTable)
+	rt.Init(
+		diff.showFlag,
+		diff.hideHeadersFlag,
+		diff.csvFlag,
+		diff.fieldSeparatorFlag,
+		diff.resourceSeparatorFlag,
+		diff.listFieldsFlag,
+		diff.defaultFieldValueFlag,
+		diff.outputFlag,
+		diff.templateFlag,
+		diff.templateFileFlag)
+	output, outputErr := rt.RenderResources(getChangedResources(changes))
+	if outputErr != nil {
+		notification.SendMessage(outputErr.Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	notification.SendMessage(output)
+}
+
+// getChangedResources returns a copy of the resource of each change with the change set in the data
+func getChangedResources(changes []*snapshot.Change) []*types.InfraResource {
+	resources := make([]*types.InfraResource, len(changes))
+	for i, curChange := range changes {
+		resource := *curChange.Resource
+		resource.Data = map[string]string{
+			dataFieldChange:       curChange.Kind,
+			dataFieldProvider:     resource.Provider,
+			dataFieldResourceType: resource.ResourceType,
+			dataFieldID:           resource.ID,
+		}
+		if len(curChange.Field) > 0 {
+			resource.Data[dataFieldField] = curChange.Field
+			resource.Data[dataFieldOldValue] = curChange.OldValue
+			resource.Data[dataFieldNewValue] = curChange.NewValue
+		}
+		resources[i] = &resource
+	}
+
+	return resources
+}
@@ -0,0 +1,127 @@
+package save
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/cli/flags"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/infra/snapshot"
+	"github.com/onaio/sre-tooling/libs/notification"
+)
+
+const name string = "save"
+
+// Save saves the resources matching the filter flags to a snapshot file
+type Save struct {
+	helpFlag       *bool
+	flagSet        *flag.FlagSet
+	providerFlag   *flags.StringArray
+	regionFlag     *flags.StringArray
+	typeFlag       *flags.StringArray
+	tagFlag        *flags.StringArray
+	accountFlag    *flags.StringArray
+	expressionFlag *string
+	dirFlag        *string
+	fileFlag       *string
+	subCommands    []cli.Command
+}
+
+// Init initializes the command object
+func (save *Save) Init(helpFlagName string, helpFlagDescription string) {
+	save.flagSet = flag.NewFlagSet(save.GetName(), flag.ExitOnError)
+	save.helpFlag = save.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	save.providerFlag,
+		save.regionFlag,
+		save.typeFlag,
+		save.tagFlag,
+		save.accountFlag,
+		save.expressionFlag = infra.AddFilterFlags(save.flagSet)
+	save.dirFlag = AddDirFlag(save.flagSet)
+	save.fileFlag = save.flagSet.String("file", "", "Path to the file to save the snapshot to instead of a timestamped file in -dir")
+
+	save.subCommands = []cli.Command{}
+}
+
+// AddDirFlag returns the flag with the directory the snapshots are saved in
+func AddDirFlag(flagSet *flag.FlagSet) *string {
+	return flagSet.String("dir", "", "Directory the snapshots are saved in. Defaults to ~/.local/share/sre-tooling/snapshots")
+}
+
+// GetDir returns the directory in the provided -dir flag or, if not set, the default snapshots directory
+func GetDir(dirFlag *string) (string, error) {
+	if len(*dirFlag) > 0 {
+		return *dirFlag, nil
+	}
+
+	return snapshot.DefaultDir()
+}
+
+// GetName returns the value of the name constant
+func (save *Save) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the save command
+func (save *Save) GetDescription() string {
+	return "Saves the infrastructure resources matching the filters to a timestamped snapshot file"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (save *Save) GetFlagSet() *flag.FlagSet {
+	return save.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the save command
+// (expect empty slice if none)
+func (save *Save) GetSubCommands() []cli.Command {
+	return save.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (save *Save) GetHelpFlag() *bool {
+	return save.helpFlag
+}
+
+// Process fetches the resources matching the filters and saves them in a snapshot
+func (save *Save) Process() {
+	filter, filterErr := infra.GetFiltersFromCommandFlags(
+		save.providerFlag,
+		save.regionFlag,
+		save.typeFlag,
+		save.tagFlag,
+		save.accountFlag,
+		save.expressionFlag)
+	if filterErr != nil {
+		notification.SendMessage(filterErr.Error())
+		cli.ExitCommandInterpretationError()
+	}
+
+	allResources, resourcesErr := infra.GetResourcesUncached(filter)
+	if resourcesErr != nil {
+		notification.SendMessage(resourcesErr.Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	newSnapshot := &snapshot.Snapshot{Time: time.Now().UTC(), Resources: allResources}
+	path := *save.fileFlag
+	var saveErr error
+	if len(path) > 0 {
+		saveErr = snapshot.Write(path, newSnapshot)
+	} else {
+		dir, dirErr := GetDir(save.dirFlag)
+		if dirErr != nil {
+			notification.SendMessage(dirErr.Error())
+			cli.ExitCommandExecutionError()
+		}
+		path, saveErr = snapshot.Save(dir, newSnapshot)
+	}
+	if saveErr != nil {
+		notification.SendMessage(fmt.Sprintf("Could not save the snapshot: %v", saveErr))
+		cli.ExitCommandExecutionError()
+	}
+
+	notification.SendMessage(fmt.Sprintf("Saved %d resources to %s", len(allResources), path))
+}
@@ -0,0 +1,59 @@
+package snapshot
+
+import (
+	"flag"
+
+	"github.com/onaio/sre-tooling/infra/snapshot/diff"
+	"github.com/onaio/sre-tooling/infra/snapshot/save"
+	"github.com/onaio/sre-tooling/libs/cli"
+)
+
+const name string = "snapshot"
+
+// Snapshot deals with commands related to snapshots of the infrastructure inventory
+type Snapshot struct {
+	helpFlag    *bool
+	flagSet     *flag.FlagSet
+	subCommands []cli.Command
+}
+
+// Init initializes the command object
+func (snapshot *Snapshot) Init(helpFlagName string, helpFlagDescription string) {
+	snapshot.flagSet = flag.NewFlagSet(snapshot.GetName(), flag.ExitOnError)
+	snapshot.helpFlag = snapshot.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	save := new(save.Save)
+	save.Init(helpFlagName, helpFlagDescription)
+	diff := new(diff.Diff)
+	diff.Init(helpFlagName, helpFlagDescription)
+
+	snapshot.subCommands = []cli.Command{save, diff}
+}
+
+// GetName returns the value of the name constant
+func (snapshot *Snapshot) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the snapshot command
+func (snapshot *Snapshot) GetDescription() string {
+	return "Saves and compares snapshots of the infrastructure inventory"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (snapshot *Snapshot) GetFlagSet() *flag.FlagSet {
+	return snapshot.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the snapshot command
+// (expect empty slice if none)
+func (snapshot *Snapshot) GetSubCommands() []cli.Command {
+	return snapshot.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (snapshot *Snapshot) GetHelpFlag() *bool {
+	return snapshot.helpFlag
+}
+
+// Process does nothing, since this command has subcommands that actually do the processing
+func (snapshot *Snapshot) Process() {}
@@ -0,0 +1,80 @@
+package copy
+
+import (
+	"flag"
+
+	"github.com/onaio/sre-tooling/infra/tag/update"
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const name string = "copy"
+
+// Copy copies a tag to a tag with another key on all the resources matching the filters
+type Copy struct {
+	helpFlag      *bool
+	flagSet       *flag.FlagSet
+	keyFlag       *string
+	newKeyFlag    *string
+	overwriteFlag *bool
+	updater       *update.Updater
+	subCommands   []cli.Command
+}
+
+// Init initializes the command object
+func (cp *Copy) Init(helpFlagName string, helpFlagDescription string) {
+	cp.flagSet = flag.NewFlagSet(cp.GetName(), flag.ExitOnError)
+	cp.helpFlag = cp.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	cp.keyFlag = cp.flagSet.String("key", "", "The key of the tag to copy")
+	cp.newKeyFlag = cp.flagSet.String("new-key", "", "The key of the tag to copy the value to")
+	cp.overwriteFlag = cp.flagSet.Bool("overwrite", false, "Whether to overwrite the tag with the new key on resources that already have it. If not set, these resources are skipped")
+	cp.updater = new(update.Updater)
+	cp.updater.Init(cp.flagSet)
+
+	cp.subCommands = []cli.Command{}
+}
+
+// GetName returns the value of the name constant
+func (cp *Copy) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the copy command
+func (cp *Copy) GetDescription() string {
+	return "Copies the value of a tag to a tag with another key on the resources that match the filters"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (cp *Copy) GetFlagSet() *flag.FlagSet {
+	return cp.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the copy command
+// (expect empty slice if none)
+func (cp *Copy) GetSubCommands() []cli.Command {
+	return cp.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (cp *Copy) GetHelpFlag() *bool {
+	return cp.helpFlag
+}
+
+// Process sets the tag with the new key to the value of the tag on the resources that match the
+// filters and have the tag
+func (cp *Copy) Process() {
+	if len(*cp.keyFlag) == 0 || len(*cp.newKeyFlag) == 0 {
+		notification.SendMessage("You need to provide the key of the tag to copy using -key and the key to copy it to using -new-key")
+		cli.ExitCommandInterpretationError()
+	}
+	if *cp.keyFlag == *cp.newKeyFlag {
+		notification.SendMessage("The new key of the tag needs to be different from its key")
+		cli.ExitCommandInterpretationError()
+	}
+
+	cp.updater.Process(func(resources []*types.InfraResource) ([]*infra.TagUpdate, error) {
+		return infra.PlanCopyTag(resources, *cp.keyFlag, *cp.newKeyFlag, *cp.overwriteFlag), nil
+	})
+}
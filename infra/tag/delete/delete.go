@@ -0,0 +1,71 @@
+package delete
+
+import (
+	"flag"
+
+	"github.com/onaio/sre-tooling/infra/tag/update"
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const name string = "delete"
+
+// Delete removes a tag from all the resources matching the filters
+type Delete struct {
+	helpFlag    *bool
+	flagSet     *flag.FlagSet
+	keyFlag     *string
+	updater     *update.Updater
+	subCommands []cli.Command
+}
+
+// Init initializes the command object
+func (del *Delete) Init(helpFlagName string, helpFlagDescription string) {
+	del.flagSet = flag.NewFlagSet(del.GetName(), flag.ExitOnError)
+	del.helpFlag = del.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	del.keyFlag = del.flagSet.String("key", "", "The key of the tag to delete")
+	del.updater = new(update.Updater)
+	del.updater.Init(del.flagSet)
+
+	del.subCommands = []cli.Command{}
+}
+
+// GetName returns the value of the name constant
+func (del *Delete) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the delete command
+func (del *Delete) GetDescription() string {
+	return "Deletes a tag from the resources that match the filters"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (del *Delete) GetFlagSet() *flag.FlagSet {
+	return del.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the delete command
+// (expect empty slice if none)
+func (del *Delete) GetSubCommands() []cli.Command {
+	return del.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (del *Delete) GetHelpFlag() *bool {
+	return del.helpFlag
+}
+
+// Process removes the tag from the resources that match the filters and have the tag
+func (del *Delete) Process() {
+	if len(*del.keyFlag) == 0 {
+		notification.SendMessage("You need to provide the key of the tag to delete using -key")
+		cli.ExitCommandInterpretationError()
+	}
+
+	del.updater.Process(func(resources []*types.InfraResource) ([]*infra.TagUpdate, error) {
+		return infra.PlanDeleteTag(resources, *del.keyFlag), nil
+	})
+}
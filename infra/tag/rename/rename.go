@@ -0,0 +1,80 @@
+package rename
+
+import (
+	"flag"
+
+	"github.com/onaio/sre-tooling/infra/tag/update"
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const name string = "rename"
+
+// Rename renames a tag on all the resources matching the filters
+type Rename struct {
+	helpFlag      *bool
+	flagSet       *flag.FlagSet
+	keyFlag       *string
+	newKeyFlag    *string
+	overwriteFlag *bool
+	updater       *update.Updater
+	subCommands   []cli.Command
+}
+
+// Init initializes the command object
+func (rename *Rename) Init(helpFlagName string, helpFlagDescription string) {
+	rename.flagSet = flag.NewFlagSet(rename.GetName(), flag.ExitOnError)
+	rename.helpFlag = rename.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	rename.keyFlag = rename.flagSet.String("key", "", "The key of the tag to rename")
+	rename.newKeyFlag = rename.flagSet.String("new-key", "", "The new key of the tag")
+	rename.overwriteFlag = rename.flagSet.Bool("overwrite", false, "Whether to overwrite the tag with the new key on resources that already have it. If not set, these resources are skipped")
+	rename.updater = new(update.Updater)
+	rename.updater.Init(rename.flagSet)
+
+	rename.subCommands = []cli.Command{}
+}
+
+// GetName returns the value of the name constant
+func (rename *Rename) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the rename command
+func (rename *Rename) GetDescription() string {
+	return "Renames a tag on the resources that match the filters"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (rename *Rename) GetFlagSet() *flag.FlagSet {
+	return rename.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the rename command
+// (expect empty slice if none)
+func (rename *Rename) GetSubCommands() []cli.Command {
+	return rename.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (rename *Rename) GetHelpFlag() *bool {
+	return rename.helpFlag
+}
+
+// Process sets the tag with the new key to the value of the tag on the resources that match the
+// filters and have the tag, then deletes the tag
+func (rename *Rename) Process() {
+	if len(*rename.keyFlag) == 0 || len(*rename.newKeyFlag) == 0 {
+		notification.SendMessage("You need to provide the key of the tag to rename using -key and its new key using -new-key")
+		cli.ExitCommandInterpretationError()
+	}
+	if *rename.keyFlag == *rename.newKeyFlag {
+		notification.SendMessage("The new key of the tag needs to be different from its key")
+		cli.ExitCommandInterpretationError()
+	}
+
+	rename.updater.Process(func(resources []*types.InfraResource) ([]*infra.TagUpdate, error) {
+		return infra.PlanRenameTag(resources, *rename.keyFlag, *rename.newKeyFlag, *rename.overwriteFlag), nil
+	})
+}
@@ -0,0 +1,90 @@
+package set
+
+import (
+	"flag"
+
+	"github.com/onaio/sre-tooling/infra/tag/update"
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const name string = "set"
+
+// Set sets a tag on all the resources matching the filters
+type Set struct {
+	helpFlag    *bool
+	flagSet     *flag.FlagSet
+	keyFlag     *string
+	valueFlag   *string
+	fromTagFlag *string
+	updater     *update.Updater
+	subCommands []cli.Command
+}
+
+// Init initializes the command object
+func (set *Set) Init(helpFlagName string, helpFlagDescription string) {
+	set.flagSet = flag.NewFlagSet(set.GetName(), flag.ExitOnError)
+	set.helpFlag = set.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	set.keyFlag = set.flagSet.String("key", "", "The key of the tag to set")
+	set.valueFlag = set.flagSet.String("value", "", "The value to set the tag to")
+	set.fromTagFlag = set.flagSet.String("from-tag", "", "Go template rendered with each resource to get the value to set the resource's tag to, instead of -value e.g. '{{.Tags.Owner}}'. Has the same functions as -template. Resources the template renders to an empty value for are skipped")
+	set.updater = new(update.Updater)
+	set.updater.Init(set.flagSet)
+
+	set.subCommands = []cli.Command{}
+}
+
+// GetName returns the value of the name constant
+func (set *Set) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the set command
+func (set *Set) GetDescription() string {
+	return "Sets a tag on the resources that match the filters"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (set *Set) GetFlagSet() *flag.FlagSet {
+	return set.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the set command
+// (expect empty slice if none)
+func (set *Set) GetSubCommands() []cli.Command {
+	return set.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (set *Set) GetHelpFlag() *bool {
+	return set.helpFlag
+}
+
+// Process sets the tag on the resources that match the filters to -value, or to the value derived
+// from each resource using -from-tag
+func (set *Set) Process() {
+	if len(*set.keyFlag) == 0 {
+		notification.SendMessage("You need to provide the key of the tag to set using -key")
+		cli.ExitCommandInterpretationError()
+	}
+	if len(*set.valueFlag) > 0 && len(*set.fromTagFlag) > 0 {
+		notification.SendMessage("Only one of -value and -from-tag can be set")
+		cli.ExitCommandInterpretationError()
+	}
+
+	value := infra.StaticTagValue(*set.valueFlag)
+	if len(*set.fromTagFlag) > 0 {
+		var templateErr error
+		value, templateErr = infra.TemplateTagValue(*set.fromTagFlag)
+		if templateErr != nil {
+			notification.SendMessage(templateErr.Error())
+			cli.ExitCommandInterpretationError()
+		}
+	}
+
+	set.updater.Process(func(resources []*types.InfraResource) ([]*infra.TagUpdate, error) {
+		return infra.PlanSetTag(resources, *set.keyFlag, value)
+	})
+}
@@ -0,0 +1,65 @@
+package tag
+
+import (
+	"flag"
+
+	"github.com/onaio/sre-tooling/infra/tag/copy"
+	"github.com/onaio/sre-tooling/infra/tag/delete"
+	"github.com/onaio/sre-tooling/infra/tag/rename"
+	"github.com/onaio/sre-tooling/infra/tag/set"
+	"github.com/onaio/sre-tooling/libs/cli"
+)
+
+const name string = "tag"
+
+// Tag deals with commands that update the tags of many resources at once
+type Tag struct {
+	helpFlag    *bool
+	flagSet     *flag.FlagSet
+	subCommands []cli.Command
+}
+
+// Init initializes the command object
+func (tag *Tag) Init(helpFlagName string, helpFlagDescription string) {
+	tag.flagSet = flag.NewFlagSet(tag.GetName(), flag.ExitOnError)
+	tag.helpFlag = tag.flagSet.Bool(helpFlagName, false, helpFlagDescription)
+	setCommand := new(set.Set)
+	setCommand.Init(helpFlagName, helpFlagDescription)
+	deleteCommand := new(delete.Delete)
+	deleteCommand.Init(helpFlagName, helpFlagDescription)
+	renameCommand := new(rename.Rename)
+	renameCommand.Init(helpFlagName, helpFlagDescription)
+	copyCommand := new(copy.Copy)
+	copyCommand.Init(helpFlagName, helpFlagDescription)
+
+	tag.subCommands = []cli.Command{setCommand, deleteCommand, renameCommand, copyCommand}
+}
+
+// GetName returns the value of the name constant
+func (tag *Tag) GetName() string {
+	return name
+}
+
+// GetDescription returns the description for the tag command
+func (tag *Tag) GetDescription() string {
+	return "Sets, deletes, renames or copies the tags of the resources that match the filters"
+}
+
+// GetFlagSet returns a pointer to the flag.FlagSet associated to the command
+func (tag *Tag) GetFlagSet() *flag.FlagSet {
+	return tag.flagSet
+}
+
+// GetSubCommands returns a slice of subcommands under the tag command
+// (expect empty slice if none)
+func (tag *Tag) GetSubCommands() []cli.Command {
+	return tag.subCommands
+}
+
+// GetHelpFlag returns a pointer to the initialized help flag for the command
+func (tag *Tag) GetHelpFlag() *bool {
+	return tag.helpFlag
+}
+
+// Process does nothing, since this command has subcommands that actually do the processing
+func (tag *Tag) Process() {}
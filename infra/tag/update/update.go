@@ -0,0 +1,246 @@
+package update
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/onaio/sre-tooling/libs/cli"
+	"github.com/onaio/sre-tooling/libs/cli/flags"
+	"github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// Fields set in the data of the resources rendered for each tag update
+const (
+	dataFieldProvider     = "provider"
+	dataFieldResourceType = "resource-type"
+	dataFieldID           = "id"
+	dataFieldLocation     = "location"
+	dataFieldAction       = "action"
+	dataFieldTag          = "tag"
+	dataFieldOldValue     = "old-value"
+	dataFieldNewValue     = "new-value"
+	dataFieldResult       = "result"
+	dataFieldError        = "error"
+)
+
+// Values of the action and result fields
+const (
+	actionSet     = "set"
+	actionDelete  = "delete"
+	resultSuccess = "success"
+	resultFailed  = "failed"
+)
+
+// defaultShownFields are the fields shown if -show isn't set
+var defaultShownFields = []string{
+	"data:" + dataFieldProvider,
+	"data:" + dataFieldResourceType,
+	"data:" + dataFieldID,
+	"data:" + dataFieldLocation,
+	"data:" + dataFieldAction,
+	"data:" + dataFieldTag,
+	"data:" + dataFieldOldValue,
+	"data:" + dataFieldNewValue,
+}
+
+// resultShownFields are the fields added to the shown fields when rendering the result of the updates
+var resultShownFields = []string{
+	"data:" + dataFieldResult,
+	"data:" + dataFieldError,
+}
+
+// PlanFunc returns the tag updates to apply to the resources
+type PlanFunc func(resources []*types.InfraResource) ([]*infra.TagUpdate, error)
+
+// Updater has the flags and the processing shared by the commands that update the tags of the resources
+// matching the filter flags, which only differ in how they plan the updates
+type Updater struct {
+	providerFlag          *flags.StringArray
+	regionFlag            *flags.StringArray
+	typeFlag              *flags.StringArray
+	tagFlag               *flags.StringArray
+	accountFlag           *flags.StringArray
+	expressionFlag        *string
+	showFlag              *flags.StringArray
+	hideHeadersFlag       *bool
+	csvFlag               *bool
+	fieldSeparatorFlag    *string
+	resourceSeparatorFlag *string
+	listFieldsFlag        *bool
+	defaultFieldValueFlag *string
+	outputFlag            *string
+	templateFlag          *string
+	templateFileFlag      *string
+	yesFlag               *bool
+	dryRunFlag            *bool
+}
+
+// Init adds the filter, resource table, -yes and -dry-run flags to the flag set
+func (updater *Updater) Init(flagSet *flag.FlagSet) {
+	updater.providerFlag,
+		updater.regionFlag,
+		updater.typeFlag,
+		updater.tagFlag,
+		updater.accountFlag,
+		updater.expressionFlag = infra.AddFilterFlags(flagSet)
+	updater.showFlag,
+		updater.hideHeadersFlag,
+		updater.csvFlag,
+		updater.fieldSeparatorFlag,
+		updater.resourceSeparatorFlag,
+		updater.listFieldsFlag,
+		updater.defaultFieldValueFlag,
+		updater.outputFlag,
+		updater.templateFlag,
+		updater.templateFileFlag = infra.AddResourceTableFlags(flagSet)
+	updater.yesFlag = flagSet.Bool("yes", false, "Whether to skip requiring a confirmation before updating the tags")
+	updater.dryRunFlag = flagSet.Bool("dry-run", false, "Whether to only print the tag updates without updating any tag")
+}
+
+// Process fetches the resources matching the filter flags, prints the tag updates returned by plan and,
+// once the user confirms, applies the updates and prints the result of each
+func (updater *Updater) Process(plan PlanFunc) {
+	// Avoid updating the tags of all the resources in all the cloud accounts by mistake
+	if len(*updater.regionFlag) == 0 && len(*updater.typeFlag) == 0 && len(*updater.tagFlag) == 0 && len(*updater.expressionFlag) == 0 {
+		notification.SendMessage("You need to filter resources using at least one region, type, tag, or filter expression")
+		cli.ExitCommandInterpretationError()
+	}
+
+	filter, filterErr := infra.GetFiltersFromCommandFlags(
+		updater.providerFlag,
+		updater.regionFlag,
+		updater.typeFlag,
+		updater.tagFlag,
+		updater.accountFlag,
+		updater.expressionFlag,
+	)
+	if filterErr != nil {
+		notification.SendMessage(filterErr.Error())
+		cli.ExitCommandInterpretationError()
+	}
+
+	allResources, resourcesErr := infra.GetResourcesUncached(filter)
+	if resourcesErr != nil {
+		notification.SendMessage(resourcesErr.Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	updates, planErr := plan(allResources)
+	if planErr != nil {
+		notification.SendMessage(planErr.Error())
+		cli.ExitCommandExecutionError()
+	}
+	if len(updates) == 0 {
+		notification.SendMessage("No tags to update")
+		return
+	}
+
+	if len(*updater.showFlag) == 0 {
+		for _, curField := range defaultShownFields {
+			updater.showFlag.Set(curField)
+		}
+	}
+	notification.SendMessage(fmt.Sprintf("Tag updates:\n%s", updater.render(updates, nil)))
+
+	if *updater.dryRunFlag {
+		return
+	}
+
+	if !*updater.yesFlag {
+		confirmed, confirmErr := cli.Confirm(fmt.Sprintf("Do you want to apply the %d tag updates?", len(updates)))
+		if confirmErr != nil {
+			notification.SendMessage(fmt.Errorf("Could not read the confirmation: %v", confirmErr).Error())
+			cli.ExitCommandExecutionError()
+		}
+
+		if !confirmed {
+			notification.SendMessage("Skipped updating the tags")
+			return
+		}
+	}
+
+	updateErrs, applyErr := infra.ApplyTagUpdates(updates)
+	if applyErr != nil {
+		notification.SendMessage(fmt.Errorf("Could not update the tags: %v", applyErr).Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	shownFields := make(map[string]bool)
+	for _, curField := range *updater.showFlag {
+		shownFields[curField] = true
+	}
+	for _, curField := range resultShownFields {
+		if !shownFields[curField] {
+			updater.showFlag.Set(curField)
+		}
+	}
+	notification.SendMessage(fmt.Sprintf("Tag update results:\n%s", updater.render(updates, updateErrs)))
+
+	for _, curErr := range updateErrs {
+		if curErr != nil {
+			cli.ExitCommandExecutionError()
+		}
+	}
+}
+
+// render renders the updates using the resource table flags. The result of each update is included if
+// updateErrs is not nil
+func (updater *Updater) render(updates []*infra.TagUpdate, updateErrs []error) string {
+	rt := new(infra.ResourceTable)
+	rt.Init(
+		updater.showFlag,
+		updater.hideHeadersFlag,
+		updater.csvFlag,
+		updater.fieldSeparatorFlag,
+		updater.resourceSeparatorFlag,
+		updater.listFieldsFlag,
+		updater.defaultFieldValueFlag,
+		updater.outputFlag,
+		updater.templateFlag,
+		updater.templateFileFlag)
+	output, outputErr := rt.RenderResources(getUpdatedResources(updates, updateErrs))
+	if outputErr != nil {
+		notification.SendMessage(outputErr.Error())
+		cli.ExitCommandExecutionError()
+	}
+
+	return output
+}
+
+// getUpdatedResources returns a copy of the resource of each update with the update, and its result if
+// updateErrs is not nil, set in the data
+func getUpdatedResources(updates []*infra.TagUpdate, updateErrs []error) []*types.InfraResource {
+	resources := make([]*types.InfraResource, len(updates))
+	for i, curUpdate := range updates {
+		resource := *curUpdate.Resource
+		resource.Data = map[string]string{
+			dataFieldProvider:     resource.Provider,
+			dataFieldResourceType: resource.ResourceType,
+			dataFieldID:           resource.ID,
+			dataFieldLocation:     resource.Location,
+			dataFieldAction:       actionSet,
+			dataFieldTag:          curUpdate.Key,
+		}
+		if curUpdate.OldValue != nil {
+			resource.Data[dataFieldOldValue] = *curUpdate.OldValue
+		}
+		if curUpdate.NewValue != nil {
+			resource.Data[dataFieldNewValue] = *curUpdate.NewValue
+		} else {
+			resource.Data[dataFieldAction] = actionDelete
+		}
+
+		if updateErrs != nil {
+			resource.Data[dataFieldResult] = resultSuccess
+			if updateErrs[i] != nil {
+				resource.Data[dataFieldResult] = resultFailed
+				resource.Data[dataFieldError] = updateErrs[i].Error()
+			}
+		}
+		resources[i] = &resource
+	}
+
+	return resources
+}
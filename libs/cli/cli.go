@@ -1,12 +1,17 @@
 package cli
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
+// stdinReader is shared between calls to Confirm so that buffered input isn't lost
+var stdinReader = bufio.NewReader(os.Stdin)
+
 // Command is the interface to implement if you want to define a (sub)command
 type Command interface {
 	// Init initializes the command. Place all initialization code here. Function should be
@@ -115,6 +120,20 @@ func getSubCommandsHelpText(command Command) string {
 	return text
 }
 
+// Confirm prints the provided prompt and waits for the user to answer. Returns true
+// only if the user answered with 'y' or 'yes'
+func Confirm(prompt string) (bool, error) {
+	fmt.Printf("%s [y/N]: ", prompt)
+
+	answer, answerErr := stdinReader.ReadString('\n')
+	if answerErr != nil && answerErr != io.EOF {
+		return false, answerErr
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
 // ExitCommandInterpretationError exits with the exit code to return when there was
 // an error interpreting the command typed by the user. Should technically be returned
 // if the help message will be printed and the user didn't explicitly request for the
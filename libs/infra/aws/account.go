@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/onaio/sre-tooling/libs/infra/config"
+)
+
+const accountsEnvVar string = "SRE_INFRA_AWS_ACCOUNTS"
+const roleARNPrefix string = "arn:"
+
+// propertyAccountID is the name of the property holding the ID of the AWS account a resource is in
+const propertyAccountID string = "account-id"
+
+// account is an AWS account resources are fetched from
+type account struct {
+	id            string
+	name          string
+	session       *session.Session
+	resourceTypes []resourceType
+}
+
+// getAccounts returns the AWS accounts in the provider's configuration or, if not configured, listed in
+// SRE_INFRA_AWS_ACCOUNTS. Each account is either a role ARN to assume or a profile in the shared config.
+// If no accounts are set, the account the default credentials belong to is returned
+func getAccounts(providerConfig *config.Provider) ([]*account, error) {
+	baseSession, sessionErr := newBaseSession(providerConfig)
+	if sessionErr != nil {
+		return nil, sessionErr
+	}
+
+	accountNames := providerConfig.GetAccounts(accountsEnvVar)
+	if len(accountNames) == 0 {
+		defaultAccount, accountErr := newAccount("", baseSession)
+		if accountErr != nil {
+			return nil, accountErr
+		}
+
+		return []*account{defaultAccount}, nil
+	}
+
+	accounts := []*account{}
+	for _, curName := range accountNames {
+		var accountSession *session.Session
+		if strings.HasPrefix(curName, roleARNPrefix) {
+			accountSession = baseSession.Copy(&aws.Config{
+				Credentials: stscreds.NewCredentials(baseSession, curName),
+			})
+		} else {
+			var profileErr error
+			accountSession, profileErr = session.NewSessionWithOptions(session.Options{
+				Profile:           curName,
+				SharedConfigState: session.SharedConfigEnable,
+			})
+			if profileErr != nil {
+				return nil, fmt.Errorf("Could not load the AWS profile '%s': %v", curName, profileErr)
+			}
+		}
+
+		curAccount, accountErr := newAccount(curName, accountSession)
+		if accountErr != nil {
+			return nil, fmt.Errorf("Could not initialize the AWS account '%s': %v", curName, accountErr)
+		}
+		accounts = append(accounts, curAccount)
+	}
+
+	return accounts, nil
+}
+
+// newBaseSession returns the session accounts are accessed from. The profile, region and static
+// credentials in the provider's configuration are used if set. Otherwise, the SDK's defaults are used
+func newBaseSession(providerConfig *config.Provider) (*session.Session, error) {
+	options := session.Options{
+		Profile:           providerConfig.GetSetting("profile", ""),
+		SharedConfigState: session.SharedConfigEnable,
+	}
+	if region := providerConfig.GetSetting("region", ""); len(region) > 0 {
+		options.Config.Region = aws.String(region)
+	}
+	if accessKeyID := providerConfig.GetSetting("access_key_id", ""); len(accessKeyID) > 0 {
+		options.Config.Credentials = credentials.NewStaticCredentials(
+			accessKeyID,
+			providerConfig.GetSetting("secret_access_key", ""),
+			providerConfig.GetSetting("session_token", ""))
+	}
+
+	return session.NewSessionWithOptions(options)
+}
+
+// newAccount fetches the ID of the account the session's credentials belong to then initializes the
+// resource types in the account
+func newAccount(name string, session *session.Session) (*account, error) {
+	identity, identityErr := sts.New(session).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if identityErr != nil {
+		return nil, identityErr
+	}
+
+	newAccount := &account{
+		id:      aws.StringValue(identity.Account),
+		name:    name,
+		session: session,
+		resourceTypes: []resourceType{
+			new(EC2),
+			new(EBS),
+			new(RDS),
+			new(Aurora),
+			new(S3),
+		},
+	}
+
+	for _, curType := range newAccount.resourceTypes {
+		initErr := curType.init(session)
+		if initErr != nil {
+			return nil, initErr
+		}
+	}
+
+	return newAccount, nil
+}
+
+// considerAccount checks whether the account is in the list of accounts to filter using. Accounts can
+// be filtered using either their IDs or their names (role ARN or profile)
+func considerAccount(account *account, filterAccounts []string) bool {
+	if len(filterAccounts) == 0 {
+		return true
+	}
+
+	for _, curAccount := range filterAccounts {
+		if curAccount == account.id || (len(account.name) > 0 && curAccount == account.name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,233 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// Aurora handles Aurora DB clusters. The clusters' DB instances are handled by RDS
+type Aurora struct {
+	session *session.Session
+}
+
+const resourceTypeAurora string = "Aurora"
+
+func (a *Aurora) init(session *session.Session) error {
+	a.session = session
+
+	return nil
+}
+
+func (a *Aurora) getResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	return getResourcesInRegions(ctx, a.session, filter, a.getAuroraClustersInRegion)
+}
+
+func (a *Aurora) getName() string {
+	return resourceTypeAurora
+}
+
+func (a *Aurora) getAuroraClustersInRegion(ctx context.Context, session *session.Session, region string, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	clusters := []*types.InfraResource{}
+
+	pageCount := 0
+	rdsService := rds.New(session)
+	input := &rds.DescribeDBClustersInput{}
+	var clusterInstances map[string][]*rds.DBInstance
+	var clustersErr error
+	for {
+		var page *rds.DescribeDBClustersOutput
+		clustersErr = callAPI(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = rdsService.DescribeDBClustersWithContext(ctx, input, withoutSDKRetries)
+			return err
+		})
+		if clustersErr != nil {
+			break
+		}
+
+		pageCount++
+		for _, curCluster := range page.DBClusters {
+			// The RDS API doesn't support filtering DB clusters using tags
+			tags, tagsErr := getRDSTags(ctx, rdsService, curCluster.DBClusterArn)
+			if tagsErr != nil {
+				clustersErr = tagsErr
+				break
+			}
+
+			if considerTags(tags, filter) {
+				// Fetch the DB instances of all the clusters in the region once, and only if a cluster is considered
+				if clusterInstances == nil {
+					clusterInstances, clustersErr = getClusterInstances(ctx, rdsService)
+					if clustersErr != nil {
+						break
+					}
+				}
+
+				clusters = append(clusters, a.getAuroraClusterResource(curCluster, clusterInstances[*curCluster.DBClusterIdentifier], tags, region))
+			}
+		}
+		if clustersErr != nil {
+			break
+		}
+
+		if page.Marker == nil || len(*page.Marker) == 0 {
+			break
+		}
+		input.Marker = page.Marker
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d Aurora clusters from %d pages in %s", len(clusters), pageCount, region))
+
+	return clusters, clustersErr
+}
+
+// getAuroraClusterResource converts the provided DB cluster into an InfraResource. The cluster doesn't
+// have an instance class or a storage type, so these are derived from its DB instances
+func (a *Aurora) getAuroraClusterResource(cluster *rds.DBCluster, instances []*rds.DBInstance, tags map[string]string, region string) *types.InfraResource {
+	clusterProperties := make(map[string]string)
+	addStringProperty(rdsPropertyArn, cluster.DBClusterArn, &clusterProperties)
+	addStringProperty("id", cluster.DBClusterIdentifier, &clusterProperties)
+	addTimeProperty("launch-time", cluster.ClusterCreateTime, &clusterProperties)
+	addStringProperty("engine", cluster.Engine, &clusterProperties)
+	addStringProperty("engine-version", cluster.EngineVersion, &clusterProperties)
+	addStringProperty("engine-mode", cluster.EngineMode, &clusterProperties)
+	addInstancesProperty("instance-class", instances, func(instance *rds.DBInstance) *string { return instance.DBInstanceClass }, &clusterProperties)
+	addBoolProperty("multi-az", cluster.MultiAZ, &clusterProperties)
+	addInt64Property("allocated-storage", cluster.AllocatedStorage, &clusterProperties)
+	addInstancesProperty("storage-type", instances, func(instance *rds.DBInstance) *string { return instance.StorageType }, &clusterProperties)
+	addStringProperty("state", cluster.Status, &clusterProperties)
+	addStringProperty("endpoint", cluster.Endpoint, &clusterProperties)
+	addStringProperty("reader-endpoint", cluster.ReaderEndpoint, &clusterProperties)
+	addInt64Property("port", cluster.Port, &clusterProperties)
+
+	resource := &types.InfraResource{
+		Provider:     awsProviderName,
+		ID:           *cluster.DBClusterIdentifier,
+		Location:     region,
+		ResourceType: resourceTypeAurora,
+		Properties:   clusterProperties,
+		Tags:         tags}
+	if cluster.ClusterCreateTime != nil {
+		resource.LaunchTime = *cluster.ClusterCreateTime
+	}
+
+	return resource
+}
+
+func (a *Aurora) updateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	if len(resource.ID) == 0 {
+		return fmt.Errorf("Could not update the Aurora cluster tag because the cluster's ID is not set")
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the Aurora cluster tag because the tag key is not set")
+	}
+
+	return addRDSTag(a.session, resource, tagKey, tagValue)
+}
+
+func (a *Aurora) updateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	if len(resource.ID) == 0 {
+		return fmt.Errorf("Could not update the Aurora cluster state because the cluster's ID is not set")
+	}
+
+	rdsService := rds.New(getRegionSession(a.session, resource.Location))
+
+	// Describe the cluster again since a cluster that was listed as available might already be stopping
+	clusters, clustersErr := rdsService.DescribeDBClusters(&rds.DescribeDBClustersInput{
+		DBClusterIdentifier: &resource.ID,
+	})
+	if clustersErr != nil {
+		return clustersErr
+	}
+	if len(clusters.DBClusters) == 0 || clusters.DBClusters[0].Status == nil {
+		return fmt.Errorf("Could not get the state of the Aurora cluster '%s'", resource.ID)
+	}
+	curState := *clusters.DBClusters[0].Status
+
+	switch state {
+	case types.ResourceStateStopped:
+		if curState == rdsStatusStopped || curState == rdsStatusStopping {
+			return nil
+		}
+
+		_, stopErr := rdsService.StopDBCluster(&rds.StopDBClusterInput{
+			DBClusterIdentifier: &resource.ID,
+		})
+
+		return stopErr
+	case types.ResourceStateRunning:
+		if curState == rdsStatusAvailable || curState == rdsStatusStarting {
+			return nil
+		}
+
+		_, startErr := rdsService.StartDBCluster(&rds.StartDBClusterInput{
+			DBClusterIdentifier: &resource.ID,
+		})
+
+		return startErr
+	}
+
+	return fmt.Errorf("Cannot update the state of an Aurora cluster to '%s'", state)
+}
+
+// getClusterInstances returns the DB instances that are members of a DB cluster, keyed by the
+// identifier of their cluster
+func getClusterInstances(ctx context.Context, rdsService *rds.RDS) (map[string][]*rds.DBInstance, error) {
+	clusterInstances := make(map[string][]*rds.DBInstance)
+
+	input := &rds.DescribeDBInstancesInput{}
+	for {
+		var page *rds.DescribeDBInstancesOutput
+		instancesErr := callAPI(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = rdsService.DescribeDBInstancesWithContext(ctx, input, withoutSDKRetries)
+			return err
+		})
+		if instancesErr != nil {
+			return nil, instancesErr
+		}
+
+		for _, curInstance := range page.DBInstances {
+			if curInstance.DBClusterIdentifier != nil {
+				clusterID := *curInstance.DBClusterIdentifier
+				clusterInstances[clusterID] = append(clusterInstances[clusterID], curInstance)
+			}
+		}
+
+		if page.Marker == nil || len(*page.Marker) == 0 {
+			break
+		}
+		input.Marker = page.Marker
+	}
+
+	return clusterInstances, nil
+}
+
+// addInstancesProperty adds the distinct values of a field of the cluster's DB instances to the
+// properties as a sorted comma separated list. Clusters with members of different classes have more
+// than one value
+func addInstancesProperty(propName string, instances []*rds.DBInstance, field func(instance *rds.DBInstance) *string, properties *map[string]string) {
+	seen := make(map[string]bool)
+	values := []string{}
+	for _, curInstance := range instances {
+		value := field(curInstance)
+		if value == nil || len(*value) == 0 || seen[*value] {
+			continue
+		}
+
+		seen[*value] = true
+		values = append(values, *value)
+	}
+
+	if len(values) > 0 {
+		sort.Strings(values)
+		(*properties)[propName] = strings.Join(values, ",")
+	}
+}
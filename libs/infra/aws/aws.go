@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -8,55 +9,88 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/infra/retry"
+	"github.com/onaio/sre-tooling/libs/infra/workers"
 	"github.com/onaio/sre-tooling/libs/types"
 )
 
 const awsProviderName string = "AWS"
 
 type AWS struct {
-	resourceTypes []resourceType
-	dataMutex     sync.Mutex
+	config    *config.Provider
+	accounts  []*account
+	dataMutex sync.Mutex
 }
 
 type resourceType interface {
 	init(*session.Session) error
 	getName() string
-	getResources(filter *types.InfraFilter) ([]*types.InfraResource, error)
+	getResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error)
 	updateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error
 	updateResourceState(resource *types.InfraResource, safe bool, state string) error
 }
 
 type awsResourceHandler func(resourceType resourceType, resources []*types.InfraResource, err error)
 
+// regionResourceGetter returns the resources of a resource type in the provided region
+type regionResourceGetter func(ctx context.Context, session *session.Session, region string, filter *types.InfraFilter) ([]*types.InfraResource, error)
+
+// New returns an AWS provider that uses the provided configuration instead of the environment
+func New(providerConfig *config.Provider) *AWS {
+	return &AWS{config: providerConfig}
+}
+
 func (aws *AWS) GetName() string {
 	return awsProviderName
 }
 
 func (a *AWS) Init() error {
-	session := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	accounts, accountsErr := getAccounts(a.config)
+	if accountsErr != nil {
+		return accountsErr
+	}
+	a.accounts = accounts
+
+	return nil
+}
 
-	ec2 := new(EC2)
-	ec2Err := ec2.init(session)
-	if ec2Err != nil {
-		return ec2Err
+// GetCostsAndUsages returns the costs and usages across all the AWS accounts. Costs in the same group
+// are summed
+func (a *AWS) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	groupAmounts := make(map[string]float64)
+	for _, curAccount := range a.accounts {
+		if !considerAccount(curAccount, filter.Accounts) {
+			continue
+		}
+
+		accountErr := a.addAccountCostsAndUsages(curAccount, filter, groupAmounts)
+		if accountErr != nil {
+			return nil, accountErr
+		}
 	}
 
-	a.resourceTypes = []resourceType{
-		ec2,
+	costsAndUsages := &types.CostAndUsageOutput{
+		Provider: a.GetName(),
+		Groups:   groupAmounts,
+		Period: &types.CostAndUsagePeriod{
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+		},
 	}
 
-	return nil
+	return costsAndUsages, nil
 }
 
-func (a *AWS) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
-	session := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	ceService := costexplorer.New(session)
+// addAccountCostsAndUsages adds the costs in the provided account to groupAmounts
+func (a *AWS) addAccountCostsAndUsages(account *account, filter *types.CostAndUsageFilter, groupAmounts map[string]float64) error {
+	ceService := costexplorer.New(account.session)
 
 	groupDefinitions := []*costexplorer.GroupDefinition{}
 	for groupType, groupKey := range filter.GroupBy {
@@ -80,10 +114,9 @@ func (a *AWS) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAn
 	}
 	costAndUsageOutput, ceErr := ceService.GetCostAndUsage(costAndUsageInput)
 	if ceErr != nil {
-		return nil, ceErr
+		return ceErr
 	}
 
-	groupAmounts := make(map[string]float64)
 	for _, resultsByTime := range costAndUsageOutput.ResultsByTime {
 		for _, groups := range resultsByTime.Groups {
 			for _, metrics := range groups.Metrics {
@@ -99,47 +132,55 @@ func (a *AWS) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAn
 		}
 	}
 
-	costsAndUsages := &types.CostAndUsageOutput{
-		Provider: a.GetName(),
-		Groups:   groupAmounts,
-		Period: &types.CostAndUsagePeriod{
-			StartDate: filter.StartDate,
-			EndDate:   filter.EndDate,
-		},
-	}
-
-	return costsAndUsages, nil
+	return nil
 }
 
-func (a *AWS) GetResources(filter *types.InfraFilter) ([]*types.InfraResource, error) {
+func (a *AWS) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
 	allResources := []*types.InfraResource{}
 	dataWG := new(sync.WaitGroup)
 
-	var finalErr error
-	for _, curType := range a.resourceTypes {
-		if considerResourceType(resourceTypeEc2, filter) {
-			handler := func(resourceType resourceType, resources []*types.InfraResource, err error) {
-				a.dataMutex.Lock()
-				allResources = append(allResources, resources...)
-				if err != nil {
-					finalErr = err
+	var failures types.InfraFailures
+	for _, curAccount := range a.accounts {
+		if !considerAccount(curAccount, filter.Accounts) {
+			continue
+		}
+
+		for _, curType := range curAccount.resourceTypes {
+			if considerResourceType(curType.getName(), filter) {
+				handler := func(resourceType resourceType, resources []*types.InfraResource, err error) {
+					a.dataMutex.Lock()
+					allResources = append(allResources, resources...)
+					if err != nil {
+						failures = append(failures, types.ToInfraFailures(err, awsProviderName, resourceType.getName(), "")...)
+					}
+					a.dataMutex.Unlock()
 				}
-				a.dataMutex.Unlock()
+				dataWG.Add(1)
+				go a.getResourcesOfType(ctx, dataWG, curAccount, curType, filter, handler)
 			}
-			dataWG.Add(1)
-			go a.getResourcesOfType(dataWG, curType, filter, handler)
 		}
 	}
 
 	dataWG.Wait()
 
-	return allResources, finalErr
+	if len(failures) > 0 {
+		return allResources, failures
+	}
+
+	return allResources, nil
 }
 
-func (a *AWS) getResourcesOfType(wg *sync.WaitGroup, resourceType resourceType, filter *types.InfraFilter, handler awsResourceHandler) {
+func (a *AWS) getResourcesOfType(ctx context.Context, wg *sync.WaitGroup, account *account, resourceType resourceType, filter *types.InfraFilter, handler awsResourceHandler) {
 	defer wg.Done()
 
-	resources, resourceErr := resourceType.getResources(filter)
+	resources, resourceErr := resourceType.getResources(ctx, filter)
+	for _, curResource := range resources {
+		if curResource.Properties == nil {
+			curResource.Properties = make(map[string]string)
+		}
+		curResource.Properties[propertyAccountID] = account.id
+	}
+
 	handler(resourceType, resources, resourceErr)
 }
 
@@ -148,7 +189,12 @@ func (a *AWS) UpdateResourceTag(resource *types.InfraResource, tagKey *string, t
 		return fmt.Errorf("Resource's provider is %s instead of EC2. Cannot update the tag", resource.Provider)
 	}
 
-	for _, curType := range a.resourceTypes {
+	account, accountErr := a.getResourceAccount(resource)
+	if accountErr != nil {
+		return accountErr
+	}
+
+	for _, curType := range account.resourceTypes {
 		if curType.getName() == resource.ResourceType {
 			return curType.updateResourceTag(resource, tagKey, tagValue)
 		}
@@ -162,7 +208,12 @@ func (a *AWS) UpdateResourceState(resource *types.InfraResource, safe bool, stat
 		return fmt.Errorf("Resource's provider is %s instead of EC2. Cannot update the tag", resource.Provider)
 	}
 
-	for _, curType := range a.resourceTypes {
+	account, accountErr := a.getResourceAccount(resource)
+	if accountErr != nil {
+		return accountErr
+	}
+
+	for _, curType := range account.resourceTypes {
 		if curType.getName() == resource.ResourceType {
 			return curType.updateResourceState(resource, safe, state)
 		}
@@ -171,6 +222,121 @@ func (a *AWS) UpdateResourceState(resource *types.InfraResource, safe bool, stat
 	return fmt.Errorf("Cannot update resource state for type '%s'", resource.ResourceType)
 }
 
+// getResourceAccount returns the account the resource is in, using the resource's account ID property.
+// If the property isn't set, the resource is assumed to be in the only configured account
+func (a *AWS) getResourceAccount(resource *types.InfraResource) (*account, error) {
+	accountID := resource.Properties[propertyAccountID]
+	if len(accountID) == 0 && len(a.accounts) == 1 {
+		return a.accounts[0], nil
+	}
+
+	for _, curAccount := range a.accounts {
+		if curAccount.id == accountID {
+			return curAccount, nil
+		}
+	}
+
+	return nil, fmt.Errorf("AWS account '%s' of resource '%s' isn't configured", accountID, resource.ID)
+}
+
+// getRegions returns the names of the regions available to the AWS account
+func getRegions(ctx context.Context, session *session.Session) ([]string, error) {
+	regions := []string{}
+
+	ec2Service := ec2.New(session)
+	var awsRegions *ec2.DescribeRegionsOutput
+	regionErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		awsRegions, err = ec2Service.DescribeRegionsWithContext(ctx, &ec2.DescribeRegionsInput{}, withoutSDKRetries)
+		return err
+	})
+	if regionErr != nil {
+		return nil, regionErr
+	}
+
+	for _, curRegion := range awsRegions.Regions {
+		regions = append(regions, *curRegion.RegionName)
+	}
+
+	return regions, nil
+}
+
+// getRegionSession returns a copy of the provided session for the provided region. Don't update
+// the region in the shared session since it is used concurrently across regions
+func getRegionSession(session *session.Session, region string) *session.Session {
+	return session.Copy(&aws.Config{Region: aws.String(region)})
+}
+
+// getResourcesInRegions calls the provided getter for each of the account's regions that match the filter,
+// using the workers pool, and returns the combined list of resources. If the getter fails in some of the
+// regions, the resources in the other regions are returned with types.InfraFailures
+func getResourcesInRegions(ctx context.Context, session *session.Session, filter *types.InfraFilter, getter regionResourceGetter) ([]*types.InfraResource, error) {
+	allResources := []*types.InfraResource{}
+
+	allRegions, regionErr := getRegions(ctx, session)
+	if regionErr != nil {
+		return allResources, regionErr
+	}
+
+	regions := []string{}
+	for _, curRegion := range allRegions {
+		if considerRegion(curRegion, filter) {
+			regions = append(regions, curRegion)
+		}
+	}
+
+	var failures types.InfraFailures
+	dataMutex := new(sync.Mutex)
+	workers.Run(ctx, len(regions), func(ctx context.Context, index int) {
+		region := regions[index]
+		resources, err := getter(ctx, getRegionSession(session, region), region, filter)
+		dataMutex.Lock()
+		allResources = append(allResources, resources...)
+		if err != nil {
+			failures = append(failures, &types.InfraFailure{Region: region, Err: err})
+		}
+		dataMutex.Unlock()
+	})
+
+	if len(failures) > 0 {
+		return allResources, failures
+	}
+
+	return allResources, nil
+}
+
+// callAPI calls the AWS API using call. The call is retried using the retry package if the API throttles
+// it or fails with a 5xx error, so call needs to pass withoutSDKRetries to the SDK
+func callAPI(ctx context.Context, call func(ctx context.Context) error) error {
+	return retry.Do(ctx, isRetryableAWSError, call)
+}
+
+// withoutSDKRetries disables the SDK's own retries for requests retried using callAPI
+var withoutSDKRetries request.Option = func(r *request.Request) {
+	r.Retryer = client.DefaultRetryer{NumMaxRetries: 0}
+}
+
+// isRetryableAWSError checks whether err is a throttling or 5xx error returned by the AWS API
+func isRetryableAWSError(err error) bool {
+	if request.IsErrorThrottle(err) {
+		return true
+	}
+	if failure, ok := err.(awserr.RequestFailure); ok {
+		return retry.IsRetryableStatus(failure.StatusCode())
+	}
+
+	return false
+}
+
+// isAWSErrorCode checks whether err is an AWS error with the provided code
+func isAWSErrorCode(err error, code string) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == code
+	}
+
+	return false
+}
+
 func addStringProperty(propName string, propValue *string, properties *map[string]string) {
 	if propValue != nil {
 		(*properties)[propName] = *propValue
@@ -183,6 +349,18 @@ func addTimeProperty(propName string, propValue *time.Time, properties *map[stri
 	}
 }
 
+func addInt64Property(propName string, propValue *int64, properties *map[string]string) {
+	if propValue != nil {
+		(*properties)[propName] = strconv.FormatInt(*propValue, 10)
+	}
+}
+
+func addBoolProperty(propName string, propValue *bool, properties *map[string]string) {
+	if propValue != nil {
+		(*properties)[propName] = strconv.FormatBool(*propValue)
+	}
+}
+
 func considerRegion(region string, filter *types.InfraFilter) bool {
 	if len(filter.Regions) == 0 {
 		return true
@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+type EBS struct {
+	session *session.Session
+}
+
+const resourceTypeEbs string = "EBS"
+
+func (e *EBS) init(session *session.Session) error {
+	e.session = session
+
+	return nil
+}
+
+func (e *EBS) getResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	return getResourcesInRegions(ctx, e.session, filter, e.getEBSVolumesInRegion)
+}
+
+func (e *EBS) getName() string {
+	return resourceTypeEbs
+}
+
+func (e *EBS) getEBSVolumesInRegion(ctx context.Context, session *session.Session, region string, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	volumes := []*types.InfraResource{}
+
+	pageCount := 0
+	ec2Service := ec2.New(session)
+	input := &ec2.DescribeVolumesInput{
+		Filters: constructEC2TagFilters(filter),
+	}
+	var volumesErr error
+	for {
+		var page *ec2.DescribeVolumesOutput
+		volumesErr = callAPI(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = ec2Service.DescribeVolumesWithContext(ctx, input, withoutSDKRetries)
+			return err
+		})
+		if volumesErr != nil {
+			break
+		}
+
+		pageCount++
+		for _, curVolume := range page.Volumes {
+			volumes = append(volumes, e.getEBSVolumeResource(curVolume, region))
+		}
+
+		if page.NextToken == nil || len(*page.NextToken) == 0 {
+			break
+		}
+		input.NextToken = page.NextToken
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d EBS volumes from %d pages in %s", len(volumes), pageCount, region))
+
+	return volumes, volumesErr
+}
+
+// getEBSVolumeResource converts the provided EBS volume into an InfraResource
+func (e *EBS) getEBSVolumeResource(volume *ec2.Volume, region string) *types.InfraResource {
+	volumeTags := make(map[string]string)
+	for _, curVolumeTag := range volume.Tags {
+		volumeTags[*curVolumeTag.Key] = *curVolumeTag.Value
+	}
+
+	volumeProperties := make(map[string]string)
+	addStringProperty("availability-zone", volume.AvailabilityZone, &volumeProperties)
+	addStringProperty("id", volume.VolumeId, &volumeProperties)
+	addTimeProperty("create-time", volume.CreateTime, &volumeProperties)
+	addInt64Property("size", volume.Size, &volumeProperties)
+	addStringProperty("volume-type", volume.VolumeType, &volumeProperties)
+	addStringProperty("state", volume.State, &volumeProperties)
+	addInt64Property("iops", volume.Iops, &volumeProperties)
+	addBoolProperty("encrypted", volume.Encrypted, &volumeProperties)
+	addStringProperty("snapshot-id", volume.SnapshotId, &volumeProperties)
+	for _, curAttachment := range volume.Attachments {
+		// Only io1/io2 volumes can be attached to more than one instance. Use the first attachment
+		addStringProperty("attached-instance", curAttachment.InstanceId, &volumeProperties)
+		addStringProperty("attachment-state", curAttachment.State, &volumeProperties)
+		break
+	}
+
+	resource := &types.InfraResource{
+		Provider:     awsProviderName,
+		ID:           *volume.VolumeId,
+		Location:     region,
+		ResourceType: resourceTypeEbs,
+		Properties:   volumeProperties,
+		Tags:         volumeTags}
+	if volume.CreateTime != nil {
+		resource.LaunchTime = *volume.CreateTime
+	}
+
+	return resource
+}
+
+func (e *EBS) updateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	if len(resource.ID) == 0 {
+		return fmt.Errorf("Could not update the EBS volume tag because the volume's ID is not set")
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the EBS volume tag because the tag key is not set")
+	}
+
+	return createEC2Tag(e.session, resource, tagKey, tagValue)
+}
+
+func (e *EBS) updateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	return fmt.Errorf("Cannot update the state of an EBS volume to '%s'", state)
+}
@@ -1,20 +1,21 @@
 package aws
 
 import (
+	"context"
 	"fmt"
-	"sync"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	infraFilter "github.com/onaio/sre-tooling/libs/infra/filter"
+	"github.com/onaio/sre-tooling/libs/notification"
 	"github.com/onaio/sre-tooling/libs/types"
 )
 
 type EC2 struct {
-	dataMutex sync.Mutex
-	session   *session.Session
+	session *session.Session
 }
-type ec2ResourceHandler func(region *string, resources []*types.InfraResource, err error)
 
 const resourceTypeEc2 string = "EC2"
 
@@ -24,124 +25,106 @@ func (e *EC2) init(session *session.Session) error {
 	return nil
 }
 
-func (e *EC2) getResources(filter *types.InfraFilter) ([]*types.InfraResource, error) {
-	allResources := []*types.InfraResource{}
-
-	regions, regionErr := e.getRegions()
-	if regionErr != nil {
-		return allResources, regionErr
-	}
-
-	var finalErr error
-	dataWG := new(sync.WaitGroup)
-	for _, curRegion := range regions {
-		if considerRegion(curRegion, filter) {
-			handler := func(region *string, resources []*types.InfraResource, err error) {
-				e.dataMutex.Lock()
-				allResources = append(allResources, resources...)
-				if err != nil {
-					finalErr = err
-				}
-				e.dataMutex.Unlock()
-			}
-			dataWG.Add(1)
-			go e.getEC2InstancesInRegion(dataWG, curRegion, filter, handler)
-		}
-	}
-
-	dataWG.Wait()
-
-	return allResources, finalErr
+func (e *EC2) getResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	return getResourcesInRegions(ctx, e.session, filter, e.getEC2InstancesInRegion)
 }
 
 func (e *EC2) getName() string {
 	return resourceTypeEc2
 }
 
-func (e *EC2) getRegions() ([]string, error) {
-	regions := []string{}
-
-	ec2Service := ec2.New(e.session)
-	awsRegions, regionErr := ec2Service.DescribeRegions(nil)
-	for _, curRegion := range awsRegions.Regions {
-		regions = append(regions, *curRegion.RegionName)
-	}
-	if regionErr != nil {
-		return nil, regionErr
-	}
-
-	return regions, nil
-}
-
-func (e *EC2) getEC2InstancesInRegion(wg *sync.WaitGroup, region string, filter *types.InfraFilter, handler ec2ResourceHandler) {
-	defer wg.Done()
-
+func (e *EC2) getEC2InstancesInRegion(ctx context.Context, session *session.Session, region string, filter *types.InfraFilter) ([]*types.InfraResource, error) {
 	virtualMachines := []*types.InfraResource{}
-	var finalErr error
-
-	// Don't use the shared session since you will be updating the region in the session
-	awsConfig := aws.Config{
-		Region: &region}
-	// Load session from shared config
-	session := session.Must(session.NewSessionWithOptions(session.Options{
-		Config:            awsConfig,
-		SharedConfigState: session.SharedConfigEnable,
-	}))
 
+	// Follow every page of results. A single call to DescribeInstances only returns the first page. Pages
+	// are fetched one at a time so that only the failed page is retried
+	pageCount := 0
 	ec2Service := ec2.New(session)
-	ec2Instances, ec2InstancesErr := ec2Service.DescribeInstances(
-		e.constructEC2DescribeInstancesInput(filter),
-	)
-
-	if ec2InstancesErr != nil {
-		finalErr = ec2InstancesErr
-	}
+	input := e.constructEC2DescribeInstancesInput(filter)
+	var ec2InstancesErr error
+	for {
+		var page *ec2.DescribeInstancesOutput
+		ec2InstancesErr = callAPI(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = ec2Service.DescribeInstancesWithContext(ctx, input, withoutSDKRetries)
+			return err
+		})
+		if ec2InstancesErr != nil {
+			break
+		}
 
-	if finalErr == nil {
-		for _, curReservation := range ec2Instances.Reservations {
+		pageCount++
+		for _, curReservation := range page.Reservations {
 			for _, curInstance := range curReservation.Instances {
-				instanceTags := make(map[string]string)
-				for _, curInstanceTag := range curInstance.Tags {
-					instanceTags[*curInstanceTag.Key] = *curInstanceTag.Value
-				}
-
-				instanceProperties := make(map[string]string)
-				addStringProperty("availability-zone", curInstance.Placement.AvailabilityZone, &instanceProperties)
-				addStringProperty("id", curInstance.InstanceId, &instanceProperties)
-				addTimeProperty("launch-time", curInstance.LaunchTime, &instanceProperties)
-				addStringProperty("private-ip", curInstance.PrivateIpAddress, &instanceProperties)
-				addStringProperty("private-dns-name", curInstance.PrivateDnsName, &instanceProperties)
-				addStringProperty("public-ip", curInstance.PublicIpAddress, &instanceProperties)
-				addStringProperty("public-dns-name", curInstance.PublicDnsName, &instanceProperties)
-				addStringProperty("image-id", curInstance.ImageId, &instanceProperties)
-				addStringProperty("vpc-id", curInstance.VpcId, &instanceProperties)
-				addStringProperty("instance-type", curInstance.InstanceType, &instanceProperties)
-				addStringProperty("key-name", curInstance.KeyName, &instanceProperties)
-				addStringProperty("state", curInstance.State.Name, &instanceProperties)
-				addStringProperty("architecture", curInstance.Architecture, &instanceProperties)
-				addStringProperty("platform", curInstance.Platform, &instanceProperties)
-
-				resource := types.InfraResource{
-					Provider:     awsProviderName,
-					ID:           *curInstance.InstanceId,
-					Location:     region,
-					ResourceType: resourceTypeEc2,
-					LaunchTime:   *curInstance.LaunchTime,
-					Properties:   instanceProperties,
-					Tags:         instanceTags}
-				virtualMachines = append(virtualMachines, &resource)
+				virtualMachines = append(virtualMachines, e.getEC2InstanceResource(curInstance, region))
 			}
 		}
+
+		if page.NextToken == nil || len(*page.NextToken) == 0 {
+			break
+		}
+		input.NextToken = page.NextToken
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d EC2 instances from %d pages in %s", len(virtualMachines), pageCount, region))
+
+	return virtualMachines, ec2InstancesErr
+}
+
+// getEC2InstanceResource converts the provided EC2 instance into an InfraResource
+func (e *EC2) getEC2InstanceResource(instance *ec2.Instance, region string) *types.InfraResource {
+	instanceTags := make(map[string]string)
+	for _, curInstanceTag := range instance.Tags {
+		instanceTags[*curInstanceTag.Key] = *curInstanceTag.Value
 	}
 
-	handler(&region, virtualMachines, finalErr)
+	instanceProperties := make(map[string]string)
+	addStringProperty("availability-zone", instance.Placement.AvailabilityZone, &instanceProperties)
+	addStringProperty("id", instance.InstanceId, &instanceProperties)
+	addTimeProperty("launch-time", instance.LaunchTime, &instanceProperties)
+	addStringProperty("private-ip", instance.PrivateIpAddress, &instanceProperties)
+	addStringProperty("private-dns-name", instance.PrivateDnsName, &instanceProperties)
+	addStringProperty("public-ip", instance.PublicIpAddress, &instanceProperties)
+	addStringProperty("public-dns-name", instance.PublicDnsName, &instanceProperties)
+	addStringProperty("image-id", instance.ImageId, &instanceProperties)
+	addStringProperty("vpc-id", instance.VpcId, &instanceProperties)
+	addStringProperty("instance-type", instance.InstanceType, &instanceProperties)
+	addStringProperty("key-name", instance.KeyName, &instanceProperties)
+	addStringProperty("state", instance.State.Name, &instanceProperties)
+	addStringProperty("architecture", instance.Architecture, &instanceProperties)
+	addStringProperty("platform", instance.Platform, &instanceProperties)
+
+	return &types.InfraResource{
+		Provider:     awsProviderName,
+		ID:           *instance.InstanceId,
+		Location:     region,
+		ResourceType: resourceTypeEc2,
+		LaunchTime:   *instance.LaunchTime,
+		Properties:   instanceProperties,
+		Tags:         instanceTags}
 }
 
 func (e *EC2) constructEC2DescribeInstancesInput(filter *types.InfraFilter) *ec2.DescribeInstancesInput {
-	if len(filter.Tags) == 0 {
-		return nil
+	return &ec2.DescribeInstancesInput{
+		Filters: constructEC2TagFilters(filter),
 	}
+}
+
+// ec2ExpressionFilterNames maps the fields in filter expressions to the names of the EC2 API filters
+// that can be used to fetch only the instances with the values the expression requires
+var ec2ExpressionFilterNames = map[string]string{
+	"property:state":             "instance-state-name",
+	"property:instance-type":     "instance-type",
+	"property:image-id":          "image-id",
+	"property:vpc-id":            "vpc-id",
+	"property:key-name":          "key-name",
+	"property:architecture":      "architecture",
+	"property:availability-zone": "availability-zone",
+}
 
+// constructEC2TagFilters returns the EC2 API filters matching the tags in the provided filter and the
+// tag and property values required by the filter's expression
+func constructEC2TagFilters(filter *types.InfraFilter) []*ec2.Filter {
 	var filters []*ec2.Filter
 	for curTagKey, curTagValue := range filter.Tags {
 		filterName := "tag:" + curTagKey
@@ -151,9 +134,26 @@ func (e *EC2) constructEC2DescribeInstancesInput(filter *types.InfraFilter) *ec2
 		})
 	}
 
-	return &ec2.DescribeInstancesInput{
-		Filters: filters,
+	for curField, curValues := range infraFilter.RequiredValues(filter.Expression) {
+		filterName, supported := ec2ExpressionFilterNames[curField]
+		if strings.HasPrefix(curField, "tag:") {
+			// Leave the tag to the -filter-tag filter if both are set
+			if _, tagFiltered := filter.Tags[strings.TrimPrefix(curField, "tag:")]; tagFiltered {
+				continue
+			}
+			filterName, supported = curField, true
+		}
+		if !supported {
+			continue
+		}
+
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String(filterName),
+			Values: aws.StringSlice(curValues),
+		})
 	}
+
+	return filters
 }
 
 func (e *EC2) updateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
@@ -164,15 +164,23 @@ func (e *EC2) updateResourceTag(resource *types.InfraResource, tagKey *string, t
 		return fmt.Errorf("Could not update the EC2 instance tag because the tag key is not set")
 	}
 
-	awsConfig := aws.Config{
-		Region: &resource.Location}
-	// Load session from shared config
-	session := session.Must(session.NewSessionWithOptions(session.Options{
-		Config:            awsConfig,
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	return createEC2Tag(e.session, resource, tagKey, tagValue)
+}
 
-	ec2Service := ec2.New(session)
+// createEC2Tag creates or updates the tag of a resource managed through the EC2 API
+// (e.g. an EC2 instance or an EBS volume), or removes the tag if tagValue is nil
+func createEC2Tag(session *session.Session, resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	ec2Service := ec2.New(getRegionSession(session, resource.Location))
+
+	if tagValue == nil {
+		// Tags without a value are deleted whatever their value is
+		_, deleteTagErr := ec2Service.DeleteTags(&ec2.DeleteTagsInput{
+			Resources: []*string{&resource.ID},
+			Tags:      []*ec2.Tag{&ec2.Tag{Key: tagKey}},
+		})
+
+		return deleteTagErr
+	}
 
 	tag := ec2.Tag{Key: tagKey, Value: tagValue}
 	_, creatTagErr := ec2Service.CreateTags(&ec2.CreateTagsInput{
@@ -184,5 +192,64 @@ func (e *EC2) updateResourceTag(resource *types.InfraResource, tagKey *string, t
 }
 
 func (e *EC2) updateResourceState(resource *types.InfraResource, safe bool, state string) error {
-	return fmt.Errorf("Not implemented yet")
+	if len(resource.ID) == 0 {
+		return fmt.Errorf("Could not update the EC2 instance state because the instance's ID is not set")
+	}
+
+	ec2Service := ec2.New(getRegionSession(e.session, resource.Location))
+
+	// Don't rely on the state in the resource's properties since it might be stale
+	curState, curStateErr := e.getInstanceState(ec2Service, &resource.ID)
+	if curStateErr != nil {
+		return curStateErr
+	}
+
+	switch state {
+	case types.ResourceStateStopped:
+		if curState == ec2.InstanceStateNameStopped || curState == ec2.InstanceStateNameStopping {
+			return nil
+		}
+
+		_, stopErr := ec2Service.StopInstances(&ec2.StopInstancesInput{
+			InstanceIds: []*string{&resource.ID},
+		})
+
+		return stopErr
+	case types.ResourceStateTerminated:
+		if curState == ec2.InstanceStateNameTerminated || curState == ec2.InstanceStateNameShuttingDown {
+			return nil
+		}
+
+		if safe && curState != ec2.InstanceStateNameStopped {
+			return fmt.Errorf("Refusing to terminate the EC2 instance '%s' because it is '%s' instead of '%s'", resource.ID, curState, ec2.InstanceStateNameStopped)
+		}
+
+		_, terminateErr := ec2Service.TerminateInstances(&ec2.TerminateInstancesInput{
+			InstanceIds: []*string{&resource.ID},
+		})
+
+		return terminateErr
+	}
+
+	return fmt.Errorf("Cannot update the state of an EC2 instance to '%s'", state)
+}
+
+// getInstanceState returns the current state of the EC2 instance with the provided ID
+func (e *EC2) getInstanceState(ec2Service *ec2.EC2, instanceID *string) (string, error) {
+	ec2Instances, ec2InstancesErr := ec2Service.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{instanceID},
+	})
+	if ec2InstancesErr != nil {
+		return "", ec2InstancesErr
+	}
+
+	for _, curReservation := range ec2Instances.Reservations {
+		for _, curInstance := range curReservation.Instances {
+			if curInstance.State != nil && curInstance.State.Name != nil {
+				return *curInstance.State.Name, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("Could not get the state of the EC2 instance '%s'", *instanceID)
 }
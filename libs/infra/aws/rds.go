@@ -0,0 +1,229 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// RDS handles RDS DB instances. Aurora clusters are handled by Aurora
+type RDS struct {
+	session *session.Session
+}
+
+const resourceTypeRds string = "RDS"
+const rdsPropertyArn string = "arn"
+const rdsStatusAvailable string = "available"
+const rdsStatusStarting string = "starting"
+const rdsStatusStopped string = "stopped"
+const rdsStatusStopping string = "stopping"
+
+func (r *RDS) init(session *session.Session) error {
+	r.session = session
+
+	return nil
+}
+
+func (r *RDS) getResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	return getResourcesInRegions(ctx, r.session, filter, r.getRDSInstancesInRegion)
+}
+
+func (r *RDS) getName() string {
+	return resourceTypeRds
+}
+
+func (r *RDS) getRDSInstancesInRegion(ctx context.Context, session *session.Session, region string, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	instances := []*types.InfraResource{}
+
+	pageCount := 0
+	rdsService := rds.New(session)
+	input := &rds.DescribeDBInstancesInput{}
+	var instancesErr error
+	for {
+		var page *rds.DescribeDBInstancesOutput
+		instancesErr = callAPI(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = rdsService.DescribeDBInstancesWithContext(ctx, input, withoutSDKRetries)
+			return err
+		})
+		if instancesErr != nil {
+			break
+		}
+
+		pageCount++
+		for _, curInstance := range page.DBInstances {
+			// The RDS API doesn't support filtering DB instances using tags
+			tags, tagsErr := getRDSTags(ctx, rdsService, curInstance.DBInstanceArn)
+			if tagsErr != nil {
+				instancesErr = tagsErr
+				break
+			}
+
+			if considerTags(tags, filter) {
+				instances = append(instances, r.getRDSInstanceResource(curInstance, tags, region))
+			}
+		}
+		if instancesErr != nil {
+			break
+		}
+
+		if page.Marker == nil || len(*page.Marker) == 0 {
+			break
+		}
+		input.Marker = page.Marker
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d RDS instances from %d pages in %s", len(instances), pageCount, region))
+
+	return instances, instancesErr
+}
+
+// getRDSInstanceResource converts the provided RDS DB instance into an InfraResource
+func (r *RDS) getRDSInstanceResource(instance *rds.DBInstance, tags map[string]string, region string) *types.InfraResource {
+	instanceProperties := make(map[string]string)
+	addStringProperty(rdsPropertyArn, instance.DBInstanceArn, &instanceProperties)
+	addStringProperty("availability-zone", instance.AvailabilityZone, &instanceProperties)
+	addStringProperty("id", instance.DBInstanceIdentifier, &instanceProperties)
+	addTimeProperty("launch-time", instance.InstanceCreateTime, &instanceProperties)
+	addStringProperty("engine", instance.Engine, &instanceProperties)
+	addStringProperty("engine-version", instance.EngineVersion, &instanceProperties)
+	addStringProperty("instance-class", instance.DBInstanceClass, &instanceProperties)
+	addBoolProperty("multi-az", instance.MultiAZ, &instanceProperties)
+	addInt64Property("allocated-storage", instance.AllocatedStorage, &instanceProperties)
+	addStringProperty("storage-type", instance.StorageType, &instanceProperties)
+	addStringProperty("state", instance.DBInstanceStatus, &instanceProperties)
+	addStringProperty("cluster-id", instance.DBClusterIdentifier, &instanceProperties)
+	if instance.Endpoint != nil {
+		addStringProperty("endpoint", instance.Endpoint.Address, &instanceProperties)
+		addInt64Property("port", instance.Endpoint.Port, &instanceProperties)
+	}
+
+	resource := &types.InfraResource{
+		Provider:     awsProviderName,
+		ID:           *instance.DBInstanceIdentifier,
+		Location:     region,
+		ResourceType: resourceTypeRds,
+		Properties:   instanceProperties,
+		Tags:         tags}
+	if instance.InstanceCreateTime != nil {
+		resource.LaunchTime = *instance.InstanceCreateTime
+	}
+
+	return resource
+}
+
+func (r *RDS) updateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	if len(resource.ID) == 0 {
+		return fmt.Errorf("Could not update the RDS instance tag because the instance's ID is not set")
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the RDS instance tag because the tag key is not set")
+	}
+
+	return addRDSTag(r.session, resource, tagKey, tagValue)
+}
+
+func (r *RDS) updateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	if len(resource.ID) == 0 {
+		return fmt.Errorf("Could not update the RDS instance state because the instance's ID is not set")
+	}
+
+	// Instances in an Aurora cluster can't be stopped or started individually
+	if clusterID := resource.Properties["cluster-id"]; len(clusterID) > 0 {
+		return fmt.Errorf("Cannot update the state of the RDS instance '%s' since it belongs to the cluster '%s'. Update the state of the cluster instead", resource.ID, clusterID)
+	}
+
+	rdsService := rds.New(getRegionSession(r.session, resource.Location))
+
+	// Describe the instance again to get its current status rather than the one it was listed with
+	instances, instancesErr := rdsService.DescribeDBInstances(&rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: &resource.ID,
+	})
+	if instancesErr != nil {
+		return instancesErr
+	}
+	if len(instances.DBInstances) == 0 || instances.DBInstances[0].DBInstanceStatus == nil {
+		return fmt.Errorf("Could not get the state of the RDS instance '%s'", resource.ID)
+	}
+	curState := *instances.DBInstances[0].DBInstanceStatus
+
+	switch state {
+	case types.ResourceStateStopped:
+		if curState == rdsStatusStopped || curState == rdsStatusStopping {
+			return nil
+		}
+
+		_, stopErr := rdsService.StopDBInstance(&rds.StopDBInstanceInput{
+			DBInstanceIdentifier: &resource.ID,
+		})
+
+		return stopErr
+	case types.ResourceStateRunning:
+		if curState == rdsStatusAvailable || curState == rdsStatusStarting {
+			return nil
+		}
+
+		_, startErr := rdsService.StartDBInstance(&rds.StartDBInstanceInput{
+			DBInstanceIdentifier: &resource.ID,
+		})
+
+		return startErr
+	}
+
+	return fmt.Errorf("Cannot update the state of an RDS instance to '%s'", state)
+}
+
+// getRDSTags returns the tags of the RDS resource with the provided ARN
+func getRDSTags(ctx context.Context, rdsService *rds.RDS, arn *string) (map[string]string, error) {
+	tags := make(map[string]string)
+	if arn == nil {
+		return tags, nil
+	}
+
+	var tagsOutput *rds.ListTagsForResourceOutput
+	tagsErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		tagsOutput, err = rdsService.ListTagsForResourceWithContext(ctx, &rds.ListTagsForResourceInput{
+			ResourceName: arn,
+		}, withoutSDKRetries)
+		return err
+	})
+	if tagsErr != nil {
+		return nil, tagsErr
+	}
+
+	for _, curTag := range tagsOutput.TagList {
+		tags[*curTag.Key] = *curTag.Value
+	}
+
+	return tags, nil
+}
+
+// addRDSTag creates or updates the tag of an RDS instance or cluster, or removes the tag if tagValue is nil
+func addRDSTag(session *session.Session, resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	arn := resource.Properties[rdsPropertyArn]
+	if len(arn) == 0 {
+		return fmt.Errorf("Could not update the tag of the %s resource '%s' because its ARN is not set", resource.ResourceType, resource.ID)
+	}
+
+	rdsService := rds.New(getRegionSession(session, resource.Location))
+	if tagValue == nil {
+		_, removeTagErr := rdsService.RemoveTagsFromResource(&rds.RemoveTagsFromResourceInput{
+			ResourceName: &arn,
+			TagKeys:      []*string{tagKey},
+		})
+
+		return removeTagErr
+	}
+
+	_, addTagErr := rdsService.AddTagsToResource(&rds.AddTagsToResourceInput{
+		ResourceName: &arn,
+		Tags:         []*rds.Tag{{Key: tagKey, Value: tagValue}},
+	})
+
+	return addTagErr
+}
@@ -0,0 +1,342 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/onaio/sre-tooling/libs/infra/workers"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// S3 handles S3 buckets. Buckets are listed globally then queried in their own regions
+type S3 struct {
+	session *session.Session
+}
+
+const resourceTypeS3 string = "S3"
+
+// Error codes returned by the S3 API when a bucket doesn't have the requested configuration
+const s3ErrNoSuchTagSet string = "NoSuchTagSet"
+const s3ErrNoSuchEncryption string = "ServerSideEncryptionConfigurationNotFoundError"
+const s3ErrNoSuchLifecycle string = "NoSuchLifecycleConfiguration"
+const s3ErrNoSuchPublicAccessBlock string = "NoSuchPublicAccessBlockConfiguration"
+
+// Values of the public-access-block property
+const s3PublicAccessBlockEnabled string = "enabled"
+const s3PublicAccessBlockPartial string = "partial"
+const s3PublicAccessBlockDisabled string = "disabled"
+
+// s3DefaultRegion is the region of buckets whose location constraint is empty
+const s3DefaultRegion string = "us-east-1"
+const s3LegacyEURegion string = "EU"
+const s3LegacyEURegionName string = "eu-west-1"
+
+func (s *S3) init(session *session.Session) error {
+	s.session = session
+
+	return nil
+}
+
+func (s *S3) getName() string {
+	return resourceTypeS3
+}
+
+func (s *S3) getResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	allResources := []*types.InfraResource{}
+
+	s3Service := s3.New(s.session)
+	var bucketsOutput *s3.ListBucketsOutput
+	bucketsErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		bucketsOutput, err = s3Service.ListBucketsWithContext(ctx, &s3.ListBucketsInput{}, withoutSDKRetries)
+		return err
+	})
+	if bucketsErr != nil {
+		return allResources, bucketsErr
+	}
+
+	var failures types.InfraFailures
+	dataMutex := new(sync.Mutex)
+	workers.Run(ctx, len(bucketsOutput.Buckets), func(ctx context.Context, index int) {
+		bucket := bucketsOutput.Buckets[index]
+		resource, resourceErr := s.getS3BucketResource(ctx, s3Service, bucket, filter)
+		dataMutex.Lock()
+		if resource != nil {
+			allResources = append(allResources, resource)
+		}
+		if resourceErr != nil {
+			failures = append(failures, &types.InfraFailure{Err: fmt.Errorf("Could not get the bucket '%s': %v", aws.StringValue(bucket.Name), resourceErr)})
+		}
+		dataMutex.Unlock()
+	})
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d of %d S3 buckets", len(allResources), len(bucketsOutput.Buckets)))
+
+	if len(failures) > 0 {
+		return allResources, failures
+	}
+
+	return allResources, nil
+}
+
+// getS3BucketResource converts the provided bucket into an InfraResource. nil is returned if
+// the bucket doesn't match the filter
+func (s *S3) getS3BucketResource(ctx context.Context, s3Service *s3.S3, bucket *s3.Bucket, filter *types.InfraFilter) (*types.InfraResource, error) {
+	region, regionErr := getS3BucketRegion(ctx, s3Service, bucket.Name)
+	if regionErr != nil {
+		return nil, regionErr
+	}
+	if !considerRegion(region, filter) {
+		return nil, nil
+	}
+
+	regionS3Service := s3.New(getRegionSession(s.session, region))
+	tags, tagsErr := getS3BucketTags(ctx, regionS3Service, bucket.Name)
+	if tagsErr != nil {
+		return nil, tagsErr
+	}
+	if !considerTags(tags, filter) {
+		return nil, nil
+	}
+
+	bucketProperties := make(map[string]string)
+	addStringProperty("id", bucket.Name, &bucketProperties)
+	addStringProperty("region", &region, &bucketProperties)
+	addTimeProperty("creation-date", bucket.CreationDate, &bucketProperties)
+
+	var versioning *s3.GetBucketVersioningOutput
+	versioningErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		versioning, err = regionS3Service.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{
+			Bucket: bucket.Name,
+		}, withoutSDKRetries)
+		return err
+	})
+	if versioningErr != nil {
+		return nil, versioningErr
+	}
+	addStringProperty("versioning", versioning.Status, &bucketProperties)
+
+	encryption, encryptionErr := getS3BucketEncryption(ctx, regionS3Service, bucket.Name)
+	if encryptionErr != nil {
+		return nil, encryptionErr
+	}
+	bucketProperties["encryption"] = encryption
+
+	lifecycleRuleCount, lifecycleErr := getS3BucketLifecycleRuleCount(ctx, regionS3Service, bucket.Name)
+	if lifecycleErr != nil {
+		return nil, lifecycleErr
+	}
+	bucketProperties["lifecycle-rule-count"] = strconv.Itoa(lifecycleRuleCount)
+
+	publicAccessBlock, publicAccessBlockErr := getS3BucketPublicAccessBlock(ctx, regionS3Service, bucket.Name)
+	if publicAccessBlockErr != nil {
+		return nil, publicAccessBlockErr
+	}
+	bucketProperties["public-access-block"] = publicAccessBlock
+
+	resource := &types.InfraResource{
+		Provider:     awsProviderName,
+		ID:           *bucket.Name,
+		Location:     region,
+		ResourceType: resourceTypeS3,
+		Properties:   bucketProperties,
+		Tags:         tags}
+	if bucket.CreationDate != nil {
+		resource.LaunchTime = *bucket.CreationDate
+	}
+
+	return resource, nil
+}
+
+// getS3BucketRegion returns the region the bucket is in
+func getS3BucketRegion(ctx context.Context, s3Service *s3.S3, bucketName *string) (string, error) {
+	var location *s3.GetBucketLocationOutput
+	locationErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		location, err = s3Service.GetBucketLocationWithContext(ctx, &s3.GetBucketLocationInput{
+			Bucket: bucketName,
+		}, withoutSDKRetries)
+		return err
+	})
+	if locationErr != nil {
+		return "", locationErr
+	}
+
+	if location.LocationConstraint == nil || len(*location.LocationConstraint) == 0 {
+		return s3DefaultRegion, nil
+	}
+
+	// Buckets created in eu-west-1 a long time ago have the legacy "EU" location constraint
+	if *location.LocationConstraint == s3LegacyEURegion {
+		return s3LegacyEURegionName, nil
+	}
+
+	return *location.LocationConstraint, nil
+}
+
+// getS3BucketTags returns the bucket's tags. An empty map is returned if the bucket doesn't have tags
+func getS3BucketTags(ctx context.Context, s3Service *s3.S3, bucketName *string) (map[string]string, error) {
+	tags := make(map[string]string)
+	var tagging *s3.GetBucketTaggingOutput
+	taggingErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		tagging, err = s3Service.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{
+			Bucket: bucketName,
+		}, withoutSDKRetries)
+		return err
+	})
+	if isAWSErrorCode(taggingErr, s3ErrNoSuchTagSet) {
+		return tags, nil
+	}
+	if taggingErr != nil {
+		return nil, taggingErr
+	}
+
+	for _, curTag := range tagging.TagSet {
+		tags[*curTag.Key] = *curTag.Value
+	}
+
+	return tags, nil
+}
+
+// getS3BucketEncryption returns the server-side encryption algorithm used by default in the bucket,
+// or "none" if the bucket doesn't have default encryption configured
+func getS3BucketEncryption(ctx context.Context, s3Service *s3.S3, bucketName *string) (string, error) {
+	var encryption *s3.GetBucketEncryptionOutput
+	encryptionErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		encryption, err = s3Service.GetBucketEncryptionWithContext(ctx, &s3.GetBucketEncryptionInput{
+			Bucket: bucketName,
+		}, withoutSDKRetries)
+		return err
+	})
+	if isAWSErrorCode(encryptionErr, s3ErrNoSuchEncryption) {
+		return "none", nil
+	}
+	if encryptionErr != nil {
+		return "", encryptionErr
+	}
+
+	if encryption.ServerSideEncryptionConfiguration != nil {
+		for _, curRule := range encryption.ServerSideEncryptionConfiguration.Rules {
+			if curRule.ApplyServerSideEncryptionByDefault != nil && curRule.ApplyServerSideEncryptionByDefault.SSEAlgorithm != nil {
+				return *curRule.ApplyServerSideEncryptionByDefault.SSEAlgorithm, nil
+			}
+		}
+	}
+
+	return "none", nil
+}
+
+// getS3BucketLifecycleRuleCount returns the number of lifecycle rules configured in the bucket
+func getS3BucketLifecycleRuleCount(ctx context.Context, s3Service *s3.S3, bucketName *string) (int, error) {
+	var lifecycle *s3.GetBucketLifecycleConfigurationOutput
+	lifecycleErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		lifecycle, err = s3Service.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{
+			Bucket: bucketName,
+		}, withoutSDKRetries)
+		return err
+	})
+	if isAWSErrorCode(lifecycleErr, s3ErrNoSuchLifecycle) {
+		return 0, nil
+	}
+	if lifecycleErr != nil {
+		return 0, lifecycleErr
+	}
+
+	return len(lifecycle.Rules), nil
+}
+
+// getS3BucketPublicAccessBlock returns whether all ("enabled"), some ("partial") or none ("disabled")
+// of the bucket's public access block settings are turned on
+func getS3BucketPublicAccessBlock(ctx context.Context, s3Service *s3.S3, bucketName *string) (string, error) {
+	var publicAccessBlock *s3.GetPublicAccessBlockOutput
+	publicAccessBlockErr := callAPI(ctx, func(ctx context.Context) error {
+		var err error
+		publicAccessBlock, err = s3Service.GetPublicAccessBlockWithContext(ctx, &s3.GetPublicAccessBlockInput{
+			Bucket: bucketName,
+		}, withoutSDKRetries)
+		return err
+	})
+	if isAWSErrorCode(publicAccessBlockErr, s3ErrNoSuchPublicAccessBlock) {
+		return s3PublicAccessBlockDisabled, nil
+	}
+	if publicAccessBlockErr != nil {
+		return "", publicAccessBlockErr
+	}
+
+	config := publicAccessBlock.PublicAccessBlockConfiguration
+	if config == nil {
+		return s3PublicAccessBlockDisabled, nil
+	}
+
+	settings := []*bool{config.BlockPublicAcls, config.IgnorePublicAcls, config.BlockPublicPolicy, config.RestrictPublicBuckets}
+	enabledCount := 0
+	for _, curSetting := range settings {
+		if curSetting != nil && *curSetting {
+			enabledCount++
+		}
+	}
+
+	switch enabledCount {
+	case 0:
+		return s3PublicAccessBlockDisabled, nil
+	case len(settings):
+		return s3PublicAccessBlockEnabled, nil
+	}
+
+	return s3PublicAccessBlockPartial, nil
+}
+
+func (s *S3) updateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	if len(resource.ID) == 0 {
+		return fmt.Errorf("Could not update the S3 bucket tag because the bucket's name is not set")
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the S3 bucket tag because the tag key is not set")
+	}
+
+	// PutBucketTagging replaces the bucket's entire tag set so fetch the current tags first
+	s3Service := s3.New(getRegionSession(s.session, resource.Location))
+	tags, tagsErr := getS3BucketTags(context.Background(), s3Service, &resource.ID)
+	if tagsErr != nil {
+		return tagsErr
+	}
+	if tagValue == nil {
+		delete(tags, *tagKey)
+
+		// PutBucketTagging doesn't accept an empty tag set
+		if len(tags) == 0 {
+			_, deleteErr := s3Service.DeleteBucketTagging(&s3.DeleteBucketTaggingInput{Bucket: &resource.ID})
+			return deleteErr
+		}
+	} else {
+		tags[*tagKey] = *tagValue
+	}
+
+	tagSet := []*s3.Tag{}
+	for curKey, curValue := range tags {
+		key := curKey
+		value := curValue
+		tagSet = append(tagSet, &s3.Tag{Key: &key, Value: &value})
+	}
+
+	_, putErr := s3Service.PutBucketTagging(&s3.PutBucketTaggingInput{
+		Bucket:  &resource.ID,
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	})
+
+	return putErr
+}
+
+func (s *S3) updateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	return fmt.Errorf("Cannot update the state of an S3 bucket to '%s'", state)
+}
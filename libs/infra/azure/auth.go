@@ -0,0 +1,38 @@
+package azure
+
+// This file contains the logic for getting OAuth 2.0 access tokens for the Azure Resource Manager API
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+)
+
+const accessTokenEnvVar string = "SRE_INFRA_AZURE_ACCESS_TOKEN"
+const tenantIDEnvVar string = "AZURE_TENANT_ID"
+const clientIDEnvVar string = "AZURE_CLIENT_ID"
+const clientSecretEnvVar string = "AZURE_CLIENT_SECRET"
+const tokenURLFormat string = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+const managementScope string = "https://management.azure.com/.default"
+
+// newTokenSource creates a token source from the provided access token or, if it is empty, the service
+// principal's credentials
+func newTokenSource(staticToken string, tenantID string, clientID string, clientSecret string) (*rest.TokenSource, error) {
+	if len(staticToken) > 0 {
+		return rest.NewStaticTokenSource(staticToken), nil
+	}
+
+	if len(tenantID) == 0 || len(clientID) == 0 || len(clientSecret) == 0 {
+		return nil, fmt.Errorf("Either %s or %s, %s and %s need to be set to authenticate against Azure", accessTokenEnvVar, tenantIDEnvVar, clientIDEnvVar, clientSecretEnvVar)
+	}
+
+	return rest.NewTokenSource(fmt.Sprintf(tokenURLFormat, url.PathEscape(tenantID)), func() (url.Values, error) {
+		return url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"scope":         {managementScope},
+		}, nil
+	}), nil
+}
@@ -0,0 +1,386 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const azureProviderName string = "Azure"
+const resourceTypeVM string = "VM"
+const subscriptionsEnvVar string = "SRE_INFRA_AZURE_SUBSCRIPTIONS"
+const subscriptionsSeparator string = ","
+const managementURL string = "https://management.azure.com"
+const computeAPIVersion string = "2021-07-01"
+const tagsAPIVersion string = "2021-04-01"
+
+// Power states of virtual machines, as reported in the VM's instance view
+const powerStatePrefix string = "PowerState/"
+const powerStateRunning string = "running"
+const powerStateStarting string = "starting"
+const powerStateDeallocated string = "deallocated"
+const powerStateDeallocating string = "deallocating"
+
+const propertyResourceID string = "resource-id"
+const propertySubscription string = "subscription"
+const propertyResourceGroup string = "resource-group"
+const propertyState string = "state"
+
+// propertyAccountID is set to the VM's subscription so that VMs can be filtered by subscription using -filter-account
+const propertyAccountID string = "account-id"
+
+// Azure fetches virtual machines and costs from the Azure Resource Manager API
+type Azure struct {
+	config        *config.Provider
+	subscriptions []string
+	arm           *rest.Client
+}
+
+type virtualMachineList struct {
+	Value    []*virtualMachine `json:"value"`
+	NextLink string            `json:"nextLink"`
+}
+
+type virtualMachine struct {
+	ID         string                    `json:"id"`
+	Name       string                    `json:"name"`
+	Location   string                    `json:"location"`
+	Tags       map[string]string         `json:"tags"`
+	Properties *virtualMachineProperties `json:"properties"`
+}
+
+type virtualMachineProperties struct {
+	VMID              string `json:"vmId"`
+	ProvisioningState string `json:"provisioningState"`
+	TimeCreated       string `json:"timeCreated"`
+	HardwareProfile   *struct {
+		VMSize string `json:"vmSize"`
+	} `json:"hardwareProfile"`
+	StorageProfile *struct {
+		OSDisk *struct {
+			OSType string `json:"osType"`
+		} `json:"osDisk"`
+	} `json:"storageProfile"`
+	InstanceView *instanceView `json:"instanceView"`
+}
+
+type instanceView struct {
+	Statuses []*instanceViewStatus `json:"statuses"`
+}
+
+type instanceViewStatus struct {
+	Code string `json:"code"`
+}
+
+type tagsPatchRequest struct {
+	Operation  string             `json:"operation"`
+	Properties *tagsPatchResource `json:"properties"`
+}
+
+type tagsPatchResource struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// IsConfigured checks whether the Azure subscriptions to fetch resources from have been set
+func IsConfigured() bool {
+	return len(parseSubscriptions(os.Getenv(subscriptionsEnvVar))) > 0
+}
+
+// New returns an Azure provider that uses the provided configuration instead of the environment
+func New(providerConfig *config.Provider) *Azure {
+	return &Azure{config: providerConfig}
+}
+
+func (a *Azure) GetName() string {
+	return azureProviderName
+}
+
+// Init initializes the provider using the subscriptions and credentials in the provider's configuration
+// or, if not configured, the environment
+func (a *Azure) Init() error {
+	subscriptions := a.config.GetAccounts(subscriptionsEnvVar)
+	if len(subscriptions) == 0 {
+		return fmt.Errorf("The Azure subscriptions need to be set in %s or the providers configuration file to use the Azure provider", subscriptionsEnvVar)
+	}
+
+	tokenSource, tokenErr := newTokenSource(
+		a.config.GetSetting("access_token", accessTokenEnvVar),
+		a.config.GetSetting("tenant_id", tenantIDEnvVar),
+		a.config.GetSetting("client_id", clientIDEnvVar),
+		a.config.GetSetting("client_secret", clientSecretEnvVar))
+	if tokenErr != nil {
+		return tokenErr
+	}
+
+	a.init(subscriptions, rest.NewClient(managementURL, rest.BearerToken(tokenSource.GetToken)))
+
+	return nil
+}
+
+func (a *Azure) init(subscriptions []string, arm *rest.Client) {
+	a.subscriptions = subscriptions
+	a.arm = arm
+}
+
+func parseSubscriptions(subscriptionsValue string) []string {
+	subscriptions := []string{}
+	for _, curSubscription := range strings.Split(subscriptionsValue, subscriptionsSeparator) {
+		curSubscription = strings.TrimSpace(curSubscription)
+		if len(curSubscription) > 0 {
+			subscriptions = append(subscriptions, curSubscription)
+		}
+	}
+
+	return subscriptions
+}
+
+// considerSubscription checks whether the subscription is one of the accounts in the filter
+func considerSubscription(subscription string, filterAccounts []string) bool {
+	if len(filterAccounts) == 0 {
+		return true
+	}
+
+	for _, curAccount := range filterAccounts {
+		if strings.EqualFold(curAccount, subscription) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *Azure) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	allResources := []*types.InfraResource{}
+	if !filter.ConsiderResourceType(resourceTypeVM) {
+		return allResources, nil
+	}
+
+	// Keep fetching the VMs in the other subscriptions if fetching the VMs in a subscription fails
+	var failures types.InfraFailures
+	for _, curSubscription := range a.subscriptions {
+		if !considerSubscription(curSubscription, filter.Accounts) {
+			continue
+		}
+
+		resources, resourcesErr := a.getVirtualMachinesInSubscription(ctx, curSubscription, filter)
+		allResources = append(allResources, resources...)
+		if resourcesErr != nil {
+			failures = append(failures, types.ToInfraFailures(resourcesErr, azureProviderName, resourceTypeVM, curSubscription)...)
+		}
+	}
+
+	if len(failures) > 0 {
+		return allResources, failures
+	}
+
+	return allResources, nil
+}
+
+// getVirtualMachinesInSubscription returns the virtual machines, in all the resource groups in the
+// subscription, that match the filter. If fetching a page fails, the VMs in the previous pages are
+// returned with the error
+func (a *Azure) getVirtualMachinesInSubscription(ctx context.Context, subscription string, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	resources := []*types.InfraResource{}
+
+	pageCount := 0
+	pagePath := fmt.Sprintf("subscriptions/%s/providers/Microsoft.Compute/virtualMachines", url.PathEscape(subscription))
+	query := url.Values{"api-version": {computeAPIVersion}, "statusOnly": {"true"}}
+	for len(pagePath) > 0 {
+		page := new(virtualMachineList)
+		pageErr := a.arm.GetWithContext(ctx, pagePath, query, page)
+		if pageErr != nil {
+			return resources, pageErr
+		}
+		pageCount++
+
+		for _, curVM := range page.Value {
+			if !filter.ConsiderRegion(curVM.Location) || !filter.ConsiderTags(curVM.Tags) {
+				continue
+			}
+
+			resources = append(resources, getVirtualMachineResource(subscription, curVM))
+		}
+
+		// The next link already has the query parameters
+		pagePath = page.NextLink
+		query = nil
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d Azure VMs from %d pages in %s", len(resources), pageCount, subscription))
+
+	return resources, nil
+}
+
+// getVirtualMachineResource converts the provided virtual machine into an InfraResource
+func getVirtualMachineResource(subscription string, vm *virtualMachine) *types.InfraResource {
+	properties := map[string]string{
+		propertyResourceID:    vm.ID,
+		propertySubscription:  subscription,
+		propertyAccountID:     subscription,
+		propertyResourceGroup: getResourceGroup(vm.ID),
+		"name":                vm.Name,
+		"location":            vm.Location,
+	}
+
+	resource := &types.InfraResource{
+		Provider:     azureProviderName,
+		ID:           vm.Name,
+		Location:     vm.Location,
+		ResourceType: resourceTypeVM,
+		Tags:         make(map[string]string),
+		Properties:   properties,
+	}
+	for curKey, curValue := range vm.Tags {
+		resource.Tags[curKey] = curValue
+	}
+
+	if vm.Properties != nil {
+		properties["id"] = vm.Properties.VMID
+		properties["provisioning-state"] = vm.Properties.ProvisioningState
+		properties["launch-time"] = vm.Properties.TimeCreated
+		if vm.Properties.HardwareProfile != nil {
+			properties["vm-size"] = vm.Properties.HardwareProfile.VMSize
+		}
+		if vm.Properties.StorageProfile != nil && vm.Properties.StorageProfile.OSDisk != nil {
+			properties["os-type"] = vm.Properties.StorageProfile.OSDisk.OSType
+		}
+		if powerState := getPowerState(vm.Properties.InstanceView); len(powerState) > 0 {
+			properties[propertyState] = powerState
+		}
+		if launchTime, launchTimeErr := time.Parse(time.RFC3339, vm.Properties.TimeCreated); launchTimeErr == nil {
+			resource.LaunchTime = launchTime
+		}
+	}
+
+	return resource
+}
+
+// getPowerState returns the power state (e.g. "running" or "deallocated") in the instance view
+func getPowerState(view *instanceView) string {
+	if view == nil {
+		return ""
+	}
+
+	for _, curStatus := range view.Statuses {
+		if strings.HasPrefix(curStatus.Code, powerStatePrefix) {
+			return strings.TrimPrefix(curStatus.Code, powerStatePrefix)
+		}
+	}
+
+	return ""
+}
+
+// getResourceGroup returns the name of the resource group in an ARM resource ID
+func getResourceGroup(resourceID string) string {
+	segments := strings.Split(resourceID, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		if strings.EqualFold(segments[i], "resourceGroups") {
+			return segments[i+1]
+		}
+	}
+
+	return ""
+}
+
+// getResourceID returns the ARM ID of the resource's virtual machine
+func getResourceID(resource *types.InfraResource) (string, error) {
+	if resource.Provider != azureProviderName {
+		return "", fmt.Errorf("Resource's provider is %s instead of %s", resource.Provider, azureProviderName)
+	}
+	if resource.ResourceType != resourceTypeVM {
+		return "", fmt.Errorf("Resources of type '%s' are not supported by the %s provider", resource.ResourceType, azureProviderName)
+	}
+
+	resourceID := resource.Properties[propertyResourceID]
+	if len(resourceID) == 0 {
+		return "", fmt.Errorf("The resource ID of the Azure VM '%s' is not set", resource.ID)
+	}
+
+	return resourceID, nil
+}
+
+// UpdateResourceTag merges the tag into the virtual machine's tags. If tagValue is nil, the tag is
+// deleted, provided its value is still the one in the resource's tags
+func (a *Azure) UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	resourceID, resourceIDErr := getResourceID(resource)
+	if resourceIDErr != nil {
+		return resourceIDErr
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the Azure VM tag because the tag key is not set")
+	}
+
+	patch := &tagsPatchRequest{
+		Operation:  "Merge",
+		Properties: &tagsPatchResource{Tags: map[string]string{}},
+	}
+	if tagValue == nil {
+		curValue, hasTag := resource.Tags[*tagKey]
+		if !hasTag {
+			return nil
+		}
+
+		// Deleting only removes the tags with the same name and value
+		patch.Operation = "Delete"
+		patch.Properties.Tags[*tagKey] = curValue
+	} else {
+		patch.Properties.Tags[*tagKey] = *tagValue
+	}
+
+	return a.arm.Do(
+		"PATCH",
+		resourceID+"/providers/Microsoft.Resources/tags/default",
+		url.Values{"api-version": {tagsAPIVersion}},
+		patch,
+		nil)
+}
+
+// UpdateResourceState deallocates, starts or deletes the virtual machine. VMs are deallocated instead
+// of only being powered off so that their compute isn't billed. In safe mode, only deallocated VMs are deleted
+func (a *Azure) UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	resourceID, resourceIDErr := getResourceID(resource)
+	if resourceIDErr != nil {
+		return resourceIDErr
+	}
+
+	// Get the VM's current power state from its instance view since the VM might have been deallocated
+	// since it was listed
+	view := new(instanceView)
+	viewErr := a.arm.Get(resourceID+"/instanceView", url.Values{"api-version": {computeAPIVersion}}, view)
+	if viewErr != nil {
+		return viewErr
+	}
+	curState := getPowerState(view)
+
+	query := url.Values{"api-version": {computeAPIVersion}}
+	switch state {
+	case types.ResourceStateStopped:
+		if curState == powerStateDeallocated || curState == powerStateDeallocating {
+			return nil
+		}
+
+		return a.arm.Post(resourceID+"/deallocate", query, nil, nil)
+	case types.ResourceStateRunning:
+		if curState == powerStateRunning || curState == powerStateStarting {
+			return nil
+		}
+
+		return a.arm.Post(resourceID+"/start", query, nil, nil)
+	case types.ResourceStateTerminated:
+		if safe && curState != powerStateDeallocated {
+			return fmt.Errorf("Refusing to delete the Azure VM '%s' because it is '%s' instead of '%s'", resource.ID, curState, powerStateDeallocated)
+		}
+
+		return a.arm.Do("DELETE", resourceID, query, nil, nil)
+	}
+
+	return fmt.Errorf("Cannot update the state of an Azure VM to '%s'", state)
+}
@@ -0,0 +1,268 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/infra/rest/resttest"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const testSubscription = "00000000-0000-0000-0000-000000000001"
+const testResourceGroup = "test-group"
+
+// fakeARM is a local fake of the parts of the Azure Resource Manager API used by the provider
+type fakeARM struct {
+	server         *httptest.Server
+	tagsPatchBody  *tagsPatchRequest
+	tagsPatchPath  string
+	costQueryBody  *costQuery
+	deallocateHits int
+}
+
+func newFakeARM(t *testing.T) *fakeARM {
+	arm := &fakeARM{}
+	vmsPath := "/subscriptions/" + testSubscription + "/providers/Microsoft.Compute/virtualMachines"
+	vm1Path := getTestVMID("vm-1")
+	mux := http.NewServeMux()
+	mux.HandleFunc(vmsPath, func(w http.ResponseWriter, r *http.Request) {
+		if !resttest.IsAuthorized(w, r) {
+			return
+		}
+
+		// Return the VMs across two pages to test pagination
+		if r.URL.Query().Get("$skiptoken") == "" {
+			resttest.WriteJSON(t, w, map[string]interface{}{
+				"value":    []interface{}{getTestVM("vm-1", "westeurope", "running")},
+				"nextLink": arm.server.URL + vmsPath + "?api-version=" + computeAPIVersion + "&statusOnly=true&$skiptoken=page-2",
+			})
+			return
+		}
+
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"value": []interface{}{getTestVM("vm-2", "eastus", "deallocated")},
+		})
+	})
+	mux.HandleFunc(vm1Path+"/providers/Microsoft.Resources/tags/default", func(w http.ResponseWriter, r *http.Request) {
+		arm.tagsPatchPath = r.Method + " " + r.URL.Path
+		arm.tagsPatchBody = new(tagsPatchRequest)
+		if !resttest.ReadJSON(t, w, r, arm.tagsPatchBody) {
+			return
+		}
+		resttest.WriteJSON(t, w, map[string]interface{}{})
+	})
+	mux.HandleFunc(vm1Path+"/instanceView", func(w http.ResponseWriter, r *http.Request) {
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"statuses": []interface{}{
+				map[string]string{"code": "ProvisioningState/succeeded"},
+				map[string]string{"code": "PowerState/running"},
+			},
+		})
+	})
+	mux.HandleFunc(vm1Path+"/deallocate", func(w http.ResponseWriter, r *http.Request) {
+		arm.deallocateHits++
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/subscriptions/"+testSubscription+"/providers/Microsoft.CostManagement/query", func(w http.ResponseWriter, r *http.Request) {
+		arm.costQueryBody = new(costQuery)
+		if !resttest.ReadJSON(t, w, r, arm.costQueryBody) {
+			return
+		}
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"properties": map[string]interface{}{
+				"columns": []interface{}{
+					map[string]string{"name": "Cost", "type": "Number"},
+					map[string]string{"name": "ServiceName", "type": "String"},
+					map[string]string{"name": "Currency", "type": "String"},
+				},
+				"rows": []interface{}{
+					[]interface{}{12.5, "Virtual Machines", "USD"},
+					[]interface{}{3.25, "Storage", "USD"},
+				},
+			},
+		})
+	})
+	arm.server = httptest.NewServer(mux)
+
+	return arm
+}
+
+func getTestVMID(name string) string {
+	return "/subscriptions/" + testSubscription + "/resourceGroups/" + testResourceGroup + "/providers/Microsoft.Compute/virtualMachines/" + name
+}
+
+func getTestVM(name string, location string, powerState string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       getTestVMID(name),
+		"name":     name,
+		"location": location,
+		"tags":     map[string]string{"owner": "alice"},
+		"properties": map[string]interface{}{
+			"vmId":              "1234",
+			"provisioningState": "Succeeded",
+			"timeCreated":       "2020-05-01T10:00:00.0000000+00:00",
+			"hardwareProfile":   map[string]string{"vmSize": "Standard_B2s"},
+			"storageProfile":    map[string]interface{}{"osDisk": map[string]string{"osType": "Linux"}},
+			"instanceView": map[string]interface{}{
+				"statuses": []interface{}{map[string]string{"code": "PowerState/" + powerState}},
+			},
+		},
+	}
+}
+
+func (arm *fakeARM) getProvider() *Azure {
+	provider := new(Azure)
+	provider.init(
+		[]string{testSubscription},
+		rest.NewClient(arm.server.URL, resttest.Authorize))
+
+	return provider
+}
+
+// Test whether GetResources follows the next links and applies the filter
+func TestGetResources(t *testing.T) {
+	arm := newFakeARM(t)
+	defer arm.server.Close()
+	provider := arm.getProvider()
+
+	t.Run("all", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+		if resourcesErr != nil {
+			t.Fatalf("Expecting error to be nil; got %v", resourcesErr)
+		}
+		if len(resources) != 2 {
+			t.Fatalf("Expecting 2 VMs from the 2 pages; got %d", len(resources))
+		}
+	})
+
+	t.Run("region", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"westeurope"}})
+		if resourcesErr != nil {
+			t.Fatalf("Expecting error to be nil; got %v", resourcesErr)
+		}
+		if len(resources) != 1 {
+			t.Fatalf("Expecting 1 VM in westeurope; got %d", len(resources))
+		}
+
+		resource := resources[0]
+		if resource.ID != "vm-1" || resource.Location != "westeurope" || resource.ResourceType != resourceTypeVM {
+			t.Errorf("Unexpected resource %+v", resource)
+		}
+		if resource.Tags["owner"] != "alice" {
+			t.Errorf("Expecting the VM's tags to be set; got %v", resource.Tags)
+		}
+		if resource.Properties[propertyResourceGroup] != testResourceGroup || resource.Properties[propertySubscription] != testSubscription {
+			t.Errorf("Expecting the resource group and subscription to be set; got %v", resource.Properties)
+		}
+		if resource.Properties["vm-size"] != "Standard_B2s" || resource.Properties[propertyState] != "running" {
+			t.Errorf("Unexpected resource properties %v", resource.Properties)
+		}
+		if resource.LaunchTime.IsZero() {
+			t.Errorf("Expecting the launch time to be set")
+		}
+	})
+
+	t.Run("account", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Accounts: []string{"other-subscription"}})
+		if resourcesErr != nil || len(resources) != 0 {
+			t.Errorf("Expecting no resources and no error; got %d resources and error %v", len(resources), resourcesErr)
+		}
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Tags: map[string]string{"owner": "bob"}})
+		if resourcesErr != nil || len(resources) != 0 {
+			t.Errorf("Expecting no resources and no error; got %d resources and error %v", len(resources), resourcesErr)
+		}
+	})
+}
+
+// Test whether UpdateResourceTag merges the tag into the VM's tags
+func TestUpdateResourceTag(t *testing.T) {
+	arm := newFakeARM(t)
+	defer arm.server.Close()
+	provider := arm.getProvider()
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"westeurope"}})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 resource; got %d resources and error %v", len(resources), resourcesErr)
+	}
+
+	tagKey := "env"
+	tagValue := "prod"
+	updateErr := provider.UpdateResourceTag(resources[0], &tagKey, &tagValue)
+	if updateErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", updateErr)
+	}
+
+	if arm.tagsPatchBody == nil {
+		t.Fatalf("Expecting the VM's tags to be patched")
+	}
+	if arm.tagsPatchPath != "PATCH "+getTestVMID("vm-1")+"/providers/Microsoft.Resources/tags/default" {
+		t.Errorf("Unexpected tags request %s", arm.tagsPatchPath)
+	}
+	if arm.tagsPatchBody.Operation != "Merge" || arm.tagsPatchBody.Properties.Tags["env"] != "prod" {
+		t.Errorf("Unexpected tags request body %+v", arm.tagsPatchBody)
+	}
+}
+
+// Test whether UpdateResourceState deallocates running VMs and refuses to delete them in safe mode
+func TestUpdateResourceState(t *testing.T) {
+	arm := newFakeARM(t)
+	defer arm.server.Close()
+	provider := arm.getProvider()
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"westeurope"}})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 resource; got %d resources and error %v", len(resources), resourcesErr)
+	}
+
+	stopErr := provider.UpdateResourceState(resources[0], true, types.ResourceStateStopped)
+	if stopErr != nil || arm.deallocateHits != 1 {
+		t.Errorf("Expecting the VM to be deallocated; got %d calls and error %v", arm.deallocateHits, stopErr)
+	}
+
+	deleteErr := provider.UpdateResourceState(resources[0], true, types.ResourceStateTerminated)
+	if deleteErr == nil {
+		t.Errorf("Expecting an error when deleting a running VM in safe mode")
+	}
+}
+
+// Test whether GetCostsAndUsages queries Cost Management and sums the costs per group
+func TestGetCostsAndUsages(t *testing.T) {
+	arm := newFakeARM(t)
+	defer arm.server.Close()
+	provider := arm.getProvider()
+
+	costs, costsErr := provider.GetCostsAndUsages(&types.CostAndUsageFilter{
+		StartDate: "2020-05-01",
+		EndDate:   "2020-06-01",
+		Regions:   []string{"westeurope"},
+		Tags:      map[string]string{"env": "dev"},
+		GroupBy:   map[string]string{"DIMENSION": "SERVICE"},
+	})
+	if costsErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", costsErr)
+	}
+
+	if costs.Groups["Virtual Machines"] != 12.5 || costs.Groups["Storage"] != 3.25 {
+		t.Errorf("Unexpected cost groups %v", costs.Groups)
+	}
+
+	if arm.costQueryBody == nil {
+		t.Fatalf("Expecting a query to be sent to Cost Management")
+	}
+	if arm.costQueryBody.TimePeriod.To != "2020-05-31T23:59:59Z" {
+		t.Errorf("Expecting the query to end on the day before the end date; got %s", arm.costQueryBody.TimePeriod.To)
+	}
+	dataset := arm.costQueryBody.Dataset
+	if len(dataset.Grouping) != 1 || dataset.Grouping[0].Name != "ServiceName" {
+		t.Errorf("Expecting the costs to be grouped by service; got %+v", dataset.Grouping)
+	}
+	if dataset.Filter == nil || len(dataset.Filter.And) != 2 {
+		t.Errorf("Expecting the region and tag filters to be combined; got %+v", dataset.Filter)
+	}
+}
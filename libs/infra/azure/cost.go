@@ -0,0 +1,254 @@
+package azure
+
+// This file contains the logic for getting costs from the Cost Management query API
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const costManagementAPIVersion string = "2021-10-01"
+const costDateLayout string = "2006-01-02"
+const totalGroupKey string = "Total"
+const costColumn string = "Cost"
+const tagValueColumn string = "TagValue"
+
+// Group types, as used by the AWS Cost Explorer, that can be passed in CostAndUsageFilter.GroupBy
+const groupTypeDimension string = "DIMENSION"
+const groupTypeTag string = "TAG"
+
+// costDimensions maps the dimensions costs can be grouped by to Cost Management's dimensions
+var costDimensions = map[string]string{
+	"SERVICE":        "ServiceName",
+	"REGION":         "ResourceLocation",
+	"RESOURCE_GROUP": "ResourceGroupName",
+	"METER":          "MeterCategory",
+}
+
+type costQuery struct {
+	Type       string           `json:"type"`
+	Timeframe  string           `json:"timeframe"`
+	TimePeriod *costQueryPeriod `json:"timePeriod"`
+	Dataset    *costDataset     `json:"dataset"`
+}
+
+type costQueryPeriod struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type costDataset struct {
+	Granularity string                      `json:"granularity"`
+	Aggregation map[string]*costAggregation `json:"aggregation"`
+	Grouping    []*costGrouping             `json:"grouping,omitempty"`
+	Filter      *costFilter                 `json:"filter,omitempty"`
+}
+
+type costAggregation struct {
+	Name     string `json:"name"`
+	Function string `json:"function"`
+}
+
+type costGrouping struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type costFilter struct {
+	And        []*costFilter       `json:"and,omitempty"`
+	Dimensions *costFilterCriteria `json:"dimensions,omitempty"`
+	Tags       *costFilterCriteria `json:"tags,omitempty"`
+}
+
+type costFilterCriteria struct {
+	Name     string   `json:"name"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+type costQueryResult struct {
+	Properties *costQueryResultProperties `json:"properties"`
+}
+
+type costQueryResultProperties struct {
+	NextLink string              `json:"nextLink"`
+	Columns  []*costColumnHeader `json:"columns"`
+	Rows     [][]interface{}     `json:"rows"`
+}
+
+type costColumnHeader struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// GetCostsAndUsages sums the actual costs in all the subscriptions, grouped as requested in the filter
+func (a *Azure) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	query, groupColumns, queryErr := constructCostQuery(filter)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	groupAmounts := make(map[string]float64)
+	for _, curSubscription := range a.subscriptions {
+		if !considerSubscription(curSubscription, filter.Accounts) {
+			continue
+		}
+
+		queryErr := a.addSubscriptionCosts(curSubscription, query, groupColumns, groupAmounts)
+		if queryErr != nil {
+			return nil, queryErr
+		}
+	}
+
+	return &types.CostAndUsageOutput{
+		Provider: azureProviderName,
+		Groups:   groupAmounts,
+		Period: &types.CostAndUsagePeriod{
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+		},
+	}, nil
+}
+
+// addSubscriptionCosts runs the query against the subscription and adds the cost in each of the
+// result's rows to its group's amount
+func (a *Azure) addSubscriptionCosts(subscription string, query *costQuery, groupColumns []string, groupAmounts map[string]float64) error {
+	pagePath := fmt.Sprintf("subscriptions/%s/providers/Microsoft.CostManagement/query", url.PathEscape(subscription))
+	pageQuery := url.Values{"api-version": {costManagementAPIVersion}}
+	for len(pagePath) > 0 {
+		result := new(costQueryResult)
+		resultErr := a.arm.Post(pagePath, pageQuery, query, result)
+		if resultErr != nil {
+			return resultErr
+		}
+		if result.Properties == nil {
+			return fmt.Errorf("Azure didn't return the results of the cost query for %s", subscription)
+		}
+
+		columnIndexes := make(map[string]int)
+		for i, curColumn := range result.Properties.Columns {
+			columnIndexes[curColumn.Name] = i
+		}
+		costIndex, costIndexOk := columnIndexes[costColumn]
+		if !costIndexOk {
+			return fmt.Errorf("The results of the Azure cost query for %s don't have the %s column", subscription, costColumn)
+		}
+
+		for _, curRow := range result.Properties.Rows {
+			if len(curRow) != len(result.Properties.Columns) {
+				return fmt.Errorf("Expecting %d columns in the Azure cost query results; got %d", len(result.Properties.Columns), len(curRow))
+			}
+
+			amount, amountOk := curRow[costIndex].(float64)
+			if !amountOk {
+				continue
+			}
+
+			groupValues := []string{}
+			for _, curColumn := range groupColumns {
+				value := ""
+				if columnIndex, columnIndexOk := columnIndexes[curColumn]; columnIndexOk && curRow[columnIndex] != nil {
+					value = fmt.Sprintf("%v", curRow[columnIndex])
+				}
+				groupValues = append(groupValues, value)
+			}
+
+			key := totalGroupKey
+			if len(groupValues) > 0 {
+				key = strings.Join(groupValues, ", ")
+			}
+			groupAmounts[key] += amount
+		}
+
+		// The next link already has the query parameters
+		pagePath = result.Properties.NextLink
+		pageQuery = nil
+	}
+
+	return nil
+}
+
+// constructCostQuery returns the Cost Management query for the costs matching the filter, and the names
+// of the result columns that make up a group's key
+func constructCostQuery(filter *types.CostAndUsageFilter) (*costQuery, []string, error) {
+	startDate, startDateErr := time.Parse(costDateLayout, filter.StartDate)
+	if startDateErr != nil {
+		return nil, nil, startDateErr
+	}
+	endDate, endDateErr := time.Parse(costDateLayout, filter.EndDate)
+	if endDateErr != nil {
+		return nil, nil, endDateErr
+	}
+
+	dataset := &costDataset{
+		Granularity: "None",
+		Aggregation: map[string]*costAggregation{
+			"totalCost": &costAggregation{Name: costColumn, Function: "Sum"},
+		},
+	}
+	query := &costQuery{
+		Type:      "ActualCost",
+		Timeframe: "Custom",
+		// The end date in the filter is exclusive while the one in Cost Management queries is inclusive
+		TimePeriod: &costQueryPeriod{
+			From: startDate.Format(time.RFC3339),
+			To:   endDate.Add(-time.Second).Format(time.RFC3339),
+		},
+		Dataset: dataset,
+	}
+
+	conditions := []*costFilter{}
+	if len(filter.ResourceTypes) > 0 {
+		conditions = append(conditions, &costFilter{Dimensions: &costFilterCriteria{Name: costDimensions["SERVICE"], Operator: "In", Values: filter.ResourceTypes}})
+	}
+	if len(filter.Regions) > 0 {
+		conditions = append(conditions, &costFilter{Dimensions: &costFilterCriteria{Name: costDimensions["REGION"], Operator: "In", Values: filter.Regions}})
+	}
+
+	tagKeys := []string{}
+	for curKey := range filter.Tags {
+		tagKeys = append(tagKeys, curKey)
+	}
+	sort.Strings(tagKeys)
+	for _, curKey := range tagKeys {
+		conditions = append(conditions, &costFilter{Tags: &costFilterCriteria{Name: curKey, Operator: "In", Values: []string{filter.Tags[curKey]}}})
+	}
+
+	if len(conditions) == 1 {
+		dataset.Filter = conditions[0]
+	} else if len(conditions) > 1 {
+		dataset.Filter = &costFilter{And: conditions}
+	}
+
+	groupTypes := []string{}
+	for curType := range filter.GroupBy {
+		groupTypes = append(groupTypes, curType)
+	}
+	sort.Strings(groupTypes)
+	groupColumns := []string{}
+	for _, curType := range groupTypes {
+		curKey := filter.GroupBy[curType]
+		switch strings.ToUpper(curType) {
+		case groupTypeDimension:
+			dimension, dimensionOk := costDimensions[strings.ToUpper(curKey)]
+			if !dimensionOk {
+				return nil, nil, fmt.Errorf("Cannot group Azure costs by the dimension '%s'", curKey)
+			}
+			dataset.Grouping = append(dataset.Grouping, &costGrouping{Type: "Dimension", Name: dimension})
+			groupColumns = append(groupColumns, dimension)
+		case groupTypeTag:
+			// Costs grouped by a tag have the tag's value in the TagValue column
+			dataset.Grouping = append(dataset.Grouping, &costGrouping{Type: "TagKey", Name: curKey})
+			groupColumns = append(groupColumns, tagValueColumn)
+		default:
+			return nil, nil, fmt.Errorf("Cannot group Azure costs by '%s'", curType)
+		}
+	}
+
+	return query, groupColumns, nil
+}
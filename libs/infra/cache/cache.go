@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const cacheDirEnvVar string = "XDG_CACHE_HOME"
+const fileNameSuffix string = ".json"
+
+// providerSeparator separates the provider from the hash of the key in file names. It is never in
+// the sanitized provider names
+const providerSeparator string = "_"
+
+// Cache stores the resources fetched from the providers in files, one per provider and key, that
+// expire after the TTL
+type Cache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// entry is the content of a cache file
+type entry struct {
+	Key       string
+	Time      time.Time
+	Resources []*types.InfraResource
+}
+
+// DefaultDir returns the directory the resources are cached in, which is ~/.cache/sre-tooling/resources
+// unless XDG_CACHE_HOME is set
+func DefaultDir() (string, error) {
+	cacheDir := os.Getenv(cacheDirEnvVar)
+	if len(cacheDir) == 0 {
+		home, homeErr := os.UserHomeDir()
+		if homeErr != nil {
+			return "", homeErr
+		}
+		cacheDir = filepath.Join(home, ".cache")
+	}
+
+	return filepath.Join(cacheDir, "sre-tooling", "resources"), nil
+}
+
+// Get returns the provider's resources cached with the key and the time they were fetched at. false is
+// returned if the resources are not cached or have expired
+func (c *Cache) Get(provider string, key string) ([]*types.InfraResource, time.Time, bool) {
+	content, readErr := ioutil.ReadFile(c.getPath(provider, key))
+	if readErr != nil {
+		return nil, time.Time{}, false
+	}
+
+	cached := new(entry)
+	unmarshalErr := json.Unmarshal(content, cached)
+	if unmarshalErr != nil || cached.Key != key || time.Since(cached.Time) >= c.TTL {
+		return nil, time.Time{}, false
+	}
+
+	return cached.Resources, cached.Time, true
+}
+
+// Put caches the provider's resources with the key
+func (c *Cache) Put(provider string, key string, resources []*types.InfraResource) error {
+	content, marshalErr := json.Marshal(&entry{Key: key, Time: time.Now(), Resources: resources})
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	mkdirErr := os.MkdirAll(c.Dir, 0700)
+	if mkdirErr != nil {
+		return mkdirErr
+	}
+
+	// Write to a temporary file first so that other runs never read a partially written file
+	tmpFile, tmpErr := ioutil.TempFile(c.Dir, ".tmp-")
+	if tmpErr != nil {
+		return tmpErr
+	}
+	_, writeErr := tmpFile.Write(content)
+	closeErr := tmpFile.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(tmpFile.Name())
+		return writeErr
+	}
+
+	return os.Rename(tmpFile.Name(), c.getPath(provider, key))
+}
+
+// ClearProvider removes all the resources cached for the provider
+func (c *Cache) ClearProvider(provider string) error {
+	paths, globErr := filepath.Glob(filepath.Join(c.Dir, sanitizeProvider(provider)+providerSeparator+"*"+fileNameSuffix))
+	if globErr != nil {
+		return globErr
+	}
+
+	return removeFiles(paths)
+}
+
+// Clear removes all the cached resources
+func (c *Cache) Clear() error {
+	paths, globErr := filepath.Glob(filepath.Join(c.Dir, "*"+fileNameSuffix))
+	if globErr != nil {
+		return globErr
+	}
+
+	return removeFiles(paths)
+}
+
+func removeFiles(paths []string) error {
+	for _, curPath := range paths {
+		removeErr := os.Remove(curPath)
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			return removeErr
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) getPath(provider string, key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, sanitizeProvider(provider)+providerSeparator+hex.EncodeToString(hash[:])+fileNameSuffix)
+}
+
+// sanitizeProvider returns the provider's name in lower case with the characters that are not letters,
+// digits, '.' or '-' replaced with '-'
+func sanitizeProvider(provider string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+
+		return '-'
+	}, strings.ToLower(provider))
+}
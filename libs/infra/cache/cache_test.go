@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+func newTestCache(t *testing.T, ttl time.Duration) *Cache {
+	dir, dirErr := ioutil.TempDir("", "cache")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+
+	return &Cache{Dir: dir, TTL: ttl}
+}
+
+func TestPutAndGet(t *testing.T) {
+	c := newTestCache(t, time.Hour)
+	defer os.RemoveAll(c.Dir)
+
+	resources := []*types.InfraResource{{Provider: "AWS", ID: "i-1", Tags: map[string]string{"Owner": "sre"}}}
+	putErr := c.Put("AWS", "ec2|us-east-1|", resources)
+	if putErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", putErr)
+	}
+
+	cached, cacheTime, ok := c.Get("AWS", "ec2|us-east-1|")
+	if !ok || len(cached) != 1 || cached[0].ID != "i-1" || cached[0].Tags["Owner"] != "sre" {
+		t.Errorf("Expecting the cached resources; got %v", cached)
+	}
+	if time.Since(cacheTime) > time.Minute {
+		t.Errorf("Expecting the time the resources were cached; got %v", cacheTime)
+	}
+
+	if _, _, ok := c.Get("AWS", "ec2|eu-west-1|"); ok {
+		t.Errorf("Expecting resources cached with another key not to be returned")
+	}
+	if _, _, ok := c.Get("DigitalOcean", "ec2|us-east-1|"); ok {
+		t.Errorf("Expecting resources cached for another provider not to be returned")
+	}
+}
+
+func TestGetExpired(t *testing.T) {
+	c := newTestCache(t, time.Nanosecond)
+	defer os.RemoveAll(c.Dir)
+
+	putErr := c.Put("AWS", "key", []*types.InfraResource{{ID: "i-1"}})
+	if putErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", putErr)
+	}
+	time.Sleep(time.Millisecond)
+
+	if _, _, ok := c.Get("AWS", "key"); ok {
+		t.Errorf("Expecting expired resources not to be returned")
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := newTestCache(t, time.Hour)
+	defer os.RemoveAll(c.Dir)
+
+	for _, curProvider := range []string{"AWS", "DigitalOcean"} {
+		putErr := c.Put(curProvider, "key", []*types.InfraResource{{ID: "resource"}})
+		if putErr != nil {
+			t.Fatalf("Expecting error to be nil; got %v", putErr)
+		}
+	}
+
+	clearErr := c.ClearProvider("AWS")
+	if clearErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", clearErr)
+	}
+	if _, _, ok := c.Get("AWS", "key"); ok {
+		t.Errorf("Expecting the cleared provider's resources not to be returned")
+	}
+	if _, _, ok := c.Get("DigitalOcean", "key"); !ok {
+		t.Errorf("Expecting the other provider's resources to still be cached")
+	}
+
+	clearErr = c.Clear()
+	if clearErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", clearErr)
+	}
+	if _, _, ok := c.Get("DigitalOcean", "key"); ok {
+		t.Errorf("Expecting no resources to be cached after clearing the cache")
+	}
+}
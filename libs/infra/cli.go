@@ -4,6 +4,7 @@ package infra
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"sort"
@@ -12,8 +13,20 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/onaio/sre-tooling/libs/cli/flags"
 	"github.com/onaio/sre-tooling/libs/types"
+	"gopkg.in/yaml.v2"
 )
 
+// Formats that resource tables can be rendered in
+const (
+	OutputTable  string = "table"
+	OutputJSON   string = "json"
+	OutputYAML   string = "yaml"
+	OutputNDJSON string = "ndjson"
+)
+
+// fieldTypeSeparator separates the type of a field (e.g. "tag") from its name
+const fieldTypeSeparator string = ":"
+
 // ResourceTable is responsible for rendering cloud resources in a table
 type ResourceTable struct {
 	showFlag              *flags.StringArray
@@ -23,6 +36,9 @@ type ResourceTable struct {
 	resourceSeparatorFlag *string
 	listFieldsFlag        *bool
 	defaultFieldValueFlag *string
+	outputFlag            *string
+	templateFlag          *string
+	templateFileFlag      *string
 }
 
 func (rt *ResourceTable) Init(
@@ -32,7 +48,10 @@ func (rt *ResourceTable) Init(
 	fieldSeparatorFlag *string,
 	resourceSeparatorFlag *string,
 	listFieldsFlag *bool,
-	defaultFieldValueFlag *string) {
+	defaultFieldValueFlag *string,
+	outputFlag *string,
+	templateFlag *string,
+	templateFileFlag *string) {
 	rt.showFlag = showFlag
 	rt.hideHeadersFlag = hideHeadersFlag
 	rt.csvFlag = csvFlag
@@ -40,9 +59,12 @@ func (rt *ResourceTable) Init(
 	rt.resourceSeparatorFlag = resourceSeparatorFlag
 	rt.listFieldsFlag = listFieldsFlag
 	rt.defaultFieldValueFlag = defaultFieldValueFlag
+	rt.outputFlag = outputFlag
+	rt.templateFlag = templateFlag
+	rt.templateFileFlag = templateFileFlag
 }
 
-func AddResourceTableFlags(flagSet *flag.FlagSet) (*flags.StringArray, *bool, *bool, *string, *string, *bool, *string) {
+func AddResourceTableFlags(flagSet *flag.FlagSet) (*flags.StringArray, *bool, *bool, *string, *string, *bool, *string, *string, *string, *string) {
 	showFlag := new(flags.StringArray)
 	flagSet.Var(showFlag, "show", "Resource's field to be shown e.g tag:Name. Multiple values can be provided by specifying multiple -show")
 	hideHeadersFlag := flagSet.Bool("hide-headers", false, "Whether to hide the names of the fields shown")
@@ -50,12 +72,28 @@ func AddResourceTableFlags(flagSet *flag.FlagSet) (*flags.StringArray, *bool, *b
 	fieldSeparatorFlag := flagSet.String("field-separator", "\t", "What to use to separate displayed fields. Only applies if -csv is enabled")
 	resourceSeparatorFlag := flagSet.String("resource-separator", "\n", "What to use to separate displayed resources. Only applies of -list-fields or -csv is enabled")
 	listFieldsFlag := flagSet.Bool("list-fields", false, "Whether to just list the fields available to be displayed, instead of the resources")
-	defaultFieldValueFlag := flagSet.String("default-field-value", "", "Text that should be set if a resource's queried field doesn't have a value")
+	defaultFieldValueFlag := flagSet.String("default-field-value", "", "Text that should be set if a resource's queried field doesn't have a value. Only applies to the table output")
+	outputFlag := flagSet.String("output", OutputTable, fmt.Sprintf("How to render the resources. Possible values are '%s', '%s' (a list of objects with the tag, property and data fields), '%s' and '%s' (one JSON object per line)", OutputTable, OutputJSON, OutputYAML, OutputNDJSON))
+	templateFlag := flagSet.String("template", "", "Go template to render each resource with instead of rendering a table e.g. '{{.ID}} {{index .Properties \"public-ip\"}} {{.Tags.Name}}'. Rendered resources are separated using -resource-separator")
+	templateFileFlag := flagSet.String("template-file", "", "Path to a file with the Go template to render each resource with. See -template")
 
-	return showFlag, hideHeadersFlag, csvFlag, fieldSeparatorFlag, resourceSeparatorFlag, listFieldsFlag, defaultFieldValueFlag
+	return showFlag, hideHeadersFlag, csvFlag, fieldSeparatorFlag, resourceSeparatorFlag, listFieldsFlag, defaultFieldValueFlag, outputFlag, templateFlag, templateFileFlag
 }
 
 func (rt *ResourceTable) RenderResources(allResources []*types.InfraResource) (string, error) {
+	tmpl, tmplErr := rt.getTemplate()
+	if tmplErr != nil {
+		return "", tmplErr
+	}
+	if tmpl != nil && !*rt.listFieldsFlag {
+		items := make([]interface{}, len(allResources))
+		for i, curResource := range allResources {
+			items[i] = curResource
+		}
+
+		return rt.renderTemplate(tmpl, items)
+	}
+
 	rows := make([]map[string]string, len(allResources))
 	headers := make(map[string]bool)
 	for rowIndex, curResource := range allResources {
@@ -68,6 +106,19 @@ func (rt *ResourceTable) RenderResources(allResources []*types.InfraResource) (s
 }
 
 func (rt *ResourceTable) RenderCostSpikes(costSpikes []*types.CostSpikeOutput) (string, error) {
+	tmpl, tmplErr := rt.getTemplate()
+	if tmplErr != nil {
+		return "", tmplErr
+	}
+	if tmpl != nil && !*rt.listFieldsFlag {
+		items := make([]interface{}, len(costSpikes))
+		for i, curSpike := range costSpikes {
+			items[i] = curSpike
+		}
+
+		return rt.renderTemplate(tmpl, items)
+	}
+
 	rows := make([]map[string]string, len(costSpikes))
 	headers := make(map[string]bool)
 
@@ -113,6 +164,8 @@ func (rt *ResourceTable) Render(headers map[string]bool, rows []map[string]strin
 
 	if *rt.listFieldsFlag {
 		output = strings.Join(displayedHeaders, *rt.resourceSeparatorFlag)
+	} else if *rt.outputFlag != OutputTable {
+		return renderStructured(*rt.outputFlag, displayedHeaders, rows)
 	} else {
 		buf := new(bytes.Buffer)
 		table := tablewriter.NewWriter(buf)
@@ -154,12 +207,106 @@ func (rt *ResourceTable) Render(headers map[string]bool, rows []map[string]strin
 	return output, nil
 }
 
+// renderStructured renders the displayed fields of each row in a structured format. Fields are
+// nested in an object per field type e.g. {"tag": {"Name": "..."}, "property": {...}}. Fields that a
+// row doesn't have are left out
+func renderStructured(output string, displayedHeaders []string, rows []map[string]string) (string, error) {
+	objects := make([]map[string]interface{}, len(rows))
+	for rowIndex, curRow := range rows {
+		objects[rowIndex] = make(map[string]interface{})
+		for _, curHeader := range displayedHeaders {
+			curValue, ok := curRow[curHeader]
+			if !ok {
+				continue
+			}
+
+			separatorIndex := strings.Index(curHeader, fieldTypeSeparator)
+			if separatorIndex < 0 {
+				objects[rowIndex][curHeader] = curValue
+				continue
+			}
+
+			fieldType := curHeader[:separatorIndex]
+			fields, fieldsOk := objects[rowIndex][fieldType].(map[string]string)
+			if !fieldsOk {
+				fields = make(map[string]string)
+				objects[rowIndex][fieldType] = fields
+			}
+			fields[curHeader[separatorIndex+len(fieldTypeSeparator):]] = curValue
+		}
+	}
+
+	switch output {
+	case OutputJSON:
+		encoded, encodeErr := json.MarshalIndent(objects, "", "  ")
+		if encodeErr != nil {
+			return "", encodeErr
+		}
+
+		return string(encoded) + "\n", nil
+	case OutputNDJSON:
+		buf := new(bytes.Buffer)
+		for _, curObject := range objects {
+			encoded, encodeErr := json.Marshal(curObject)
+			if encodeErr != nil {
+				return "", encodeErr
+			}
+			buf.Write(encoded)
+			buf.WriteString("\n")
+		}
+
+		return buf.String(), nil
+	case OutputYAML:
+		encoded, encodeErr := yaml.Marshal(objects)
+		if encodeErr != nil {
+			return "", encodeErr
+		}
+
+		return string(encoded), nil
+	}
+
+	return "", fmt.Errorf("Unrecognized output '%s'. Possible values are '%s', '%s', '%s' and '%s'", output, OutputTable, OutputJSON, OutputYAML, OutputNDJSON)
+}
+
+// RenderFailures renders the failures to fetch some of the resources in a table, sorted by provider,
+// resource type and region, to be shown after the resources that were fetched. The failures are rendered
+// in a table regardless of -output and -template
+func RenderFailures(failures types.InfraFailures) string {
+	sorted := make(types.InfraFailures, len(failures))
+	copy(sorted, failures)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Provider != sorted[j].Provider {
+			return sorted[i].Provider < sorted[j].Provider
+		}
+		if sorted[i].ResourceType != sorted[j].ResourceType {
+			return sorted[i].ResourceType < sorted[j].ResourceType
+		}
+
+		return sorted[i].Region < sorted[j].Region
+	})
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("Could not fetch all the resources. Failures:\n")
+	table := tablewriter.NewWriter(buf)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Provider", "Resource Type", "Region", "Error"})
+	for _, curFailure := range sorted {
+		table.Append([]string{curFailure.Provider, curFailure.ResourceType, curFailure.Region, fmt.Sprintf("%v", curFailure.Err)})
+	}
+	table.Render()
+
+	return buf.String()
+}
+
 func (rt *ResourceTable) addResourceTableFields(headers map[string]bool, rows []map[string]string, rowIndex int, newFields map[string]string, fieldType string) (map[string]bool, []map[string]string) {
 	if rows[rowIndex] == nil {
 		rows[rowIndex] = make(map[string]string)
 	}
 	for curKey, curVal := range newFields {
-		fieldName := fieldType + ":" + curKey
+		fieldName := fieldType + fieldTypeSeparator + curKey
 		rows[rowIndex][fieldName] = curVal
 		headers[fieldName] = rt.considerResourceTableField(fieldName)
 	}
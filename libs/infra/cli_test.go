@@ -1,12 +1,15 @@
 package infra
 
 import (
+	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/onaio/sre-tooling/libs/cli/flags"
 	"github.com/onaio/sre-tooling/libs/types"
+	"gopkg.in/yaml.v2"
 )
 
 // Test whether setting the "hide headers" argument in the resource table actually works
@@ -18,6 +21,8 @@ func TestResourceTableRenderHeader(t *testing.T) {
 	resourceSeparatorFlag := "\n"
 	listFieldsFlag := false
 	defaultFieldValueFlag := ""
+	outputFlag := OutputTable
+	templateFlag := ""
 
 	resource1 := types.InfraResource{
 		Provider:   "testProvider",
@@ -40,10 +45,13 @@ func TestResourceTableRenderHeader(t *testing.T) {
 			&fieldSeparatorFlag,
 			&resourceSeparatorFlag,
 			&listFieldsFlag,
-			&defaultFieldValueFlag)
+			&defaultFieldValueFlag,
+			&outputFlag,
+			&templateFlag,
+			&templateFlag)
 		table, tableErr := rt.RenderResources(resources)
 		if tableErr != nil {
-			t.Errorf("Not expecting an error to be returned: %w", tableErr)
+			t.Errorf("Not expecting an error to be returned: %v", tableErr)
 		}
 		if strings.Contains(table, "tag:Name") {
 			t.Errorf("Not expecting the tag:Name header")
@@ -61,10 +69,13 @@ func TestResourceTableRenderHeader(t *testing.T) {
 			&fieldSeparatorFlag,
 			&resourceSeparatorFlag,
 			&listFieldsFlag,
-			&defaultFieldValueFlag)
+			&defaultFieldValueFlag,
+			&outputFlag,
+			&templateFlag,
+			&templateFlag)
 		table, tableErr := rt.RenderResources(resources)
 		if tableErr != nil {
-			t.Errorf("Not expecting an error to be returned: %w", tableErr)
+			t.Errorf("Not expecting an error to be returned: %v", tableErr)
 		}
 		if !strings.Contains(table, "tag:Name") {
 			t.Errorf("Expecting the tag:Name header to be present")
@@ -82,6 +93,8 @@ func TestResourceTableShowColumns(t *testing.T) {
 	resourceSeparatorFlag := "\n"
 	listFieldsFlag := false
 	defaultFieldValueFlag := ""
+	outputFlag := OutputTable
+	templateFlag := ""
 	hideHeadersFlag := false
 
 	nameTagValue := "testResourceNameTag"
@@ -113,10 +126,13 @@ func TestResourceTableShowColumns(t *testing.T) {
 			&fieldSeparatorFlag,
 			&resourceSeparatorFlag,
 			&listFieldsFlag,
-			&defaultFieldValueFlag)
+			&defaultFieldValueFlag,
+			&outputFlag,
+			&templateFlag,
+			&templateFlag)
 		table, tableErr := rt.RenderResources(resources)
 		if tableErr != nil {
-			t.Errorf("Not expecting an error to be returned: %w", tableErr)
+			t.Errorf("Not expecting an error to be returned: %v", tableErr)
 		}
 		if !strings.Contains(table, nameTagValue) {
 			t.Errorf("Expecting the '%s' tag value '%s' to be present", nameTag, nameTagValue)
@@ -141,10 +157,13 @@ func TestResourceTableShowColumns(t *testing.T) {
 			&fieldSeparatorFlag,
 			&resourceSeparatorFlag,
 			&listFieldsFlag,
-			&defaultFieldValueFlag)
+			&defaultFieldValueFlag,
+			&outputFlag,
+			&templateFlag,
+			&templateFlag)
 		table, tableErr := rt.RenderResources(resources)
 		if tableErr != nil {
-			t.Errorf("Not expecting an error to be returned: %w", tableErr)
+			t.Errorf("Not expecting an error to be returned: %v", tableErr)
 		}
 		if !strings.Contains(table, nameTagValue) {
 			t.Errorf("Expecting the '%s' tag value '%s' to be present", nameTag, nameTagValue)
@@ -168,10 +187,13 @@ func TestResourceTableShowColumns(t *testing.T) {
 			&fieldSeparatorFlag,
 			&resourceSeparatorFlag,
 			&listFieldsFlag,
-			&defaultFieldValueFlag)
+			&defaultFieldValueFlag,
+			&outputFlag,
+			&templateFlag,
+			&templateFlag)
 		table, tableErr := rt.RenderResources(resources)
 		if tableErr != nil {
-			t.Errorf("Not expecting an error to be returned: %w", tableErr)
+			t.Errorf("Not expecting an error to be returned: %v", tableErr)
 		}
 		if !strings.Contains(table, nameTagValue) {
 			t.Errorf("Expecting the '%s' tag value '%s' to be present", nameTag, nameTagValue)
@@ -184,3 +206,96 @@ func TestResourceTableShowColumns(t *testing.T) {
 		}
 	})
 }
+
+// Test whether the resource table renders the shown fields as nested objects in the structured outputs
+func TestResourceTableStructuredOutput(t *testing.T) {
+	showFlag := new(flags.StringArray)
+	showFlag.Set("tag:Name")
+	showFlag.Set("property:public-ip")
+	hideHeadersFlag := false
+	csvFlag := false
+	fieldSeparatorFlag := "\t"
+	resourceSeparatorFlag := "\n"
+	listFieldsFlag := false
+	defaultFieldValueFlag := ""
+	templateFlag := ""
+
+	resources := []*types.InfraResource{
+		&types.InfraResource{
+			Tags:       map[string]string{"Name": "resource-1", "Owner": "sre"},
+			Properties: map[string]string{"public-ip": "192.0.2.1"}},
+		&types.InfraResource{
+			Tags: map[string]string{"Name": "resource-2"}},
+	}
+	expected := []map[string]map[string]string{
+		{"tag": {"Name": "resource-1"}, "property": {"public-ip": "192.0.2.1"}},
+		{"tag": {"Name": "resource-2"}},
+	}
+
+	render := func(outputFlag string) string {
+		rt := new(ResourceTable)
+		rt.Init(
+			showFlag,
+			&hideHeadersFlag,
+			&csvFlag,
+			&fieldSeparatorFlag,
+			&resourceSeparatorFlag,
+			&listFieldsFlag,
+			&defaultFieldValueFlag,
+			&outputFlag,
+			&templateFlag,
+			&templateFlag)
+		output, outputErr := rt.RenderResources(resources)
+		if outputErr != nil {
+			t.Fatalf("Not expecting an error to be returned: %v", outputErr)
+		}
+
+		return output
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var objects []map[string]map[string]string
+		unmarshalErr := json.Unmarshal([]byte(render(OutputJSON)), &objects)
+		if unmarshalErr != nil {
+			t.Fatalf("Expecting valid JSON; got %v", unmarshalErr)
+		}
+		if !reflect.DeepEqual(objects, expected) {
+			t.Errorf("Expecting %v; got %v", expected, objects)
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSuffix(render(OutputNDJSON), "\n"), "\n")
+		if len(lines) != len(expected) {
+			t.Fatalf("Expecting one line per resource; got %d lines", len(lines))
+		}
+		for i, curLine := range lines {
+			var object map[string]map[string]string
+			unmarshalErr := json.Unmarshal([]byte(curLine), &object)
+			if unmarshalErr != nil || !reflect.DeepEqual(object, expected[i]) {
+				t.Errorf("Expecting line %d to be %v; got %s and error %v", i, expected[i], curLine, unmarshalErr)
+			}
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		var objects []map[string]map[string]string
+		unmarshalErr := yaml.Unmarshal([]byte(render(OutputYAML)), &objects)
+		if unmarshalErr != nil {
+			t.Fatalf("Expecting valid YAML; got %v", unmarshalErr)
+		}
+		if !reflect.DeepEqual(objects, expected) {
+			t.Errorf("Expecting %v; got %v", expected, objects)
+		}
+	})
+
+	t.Run("unrecognized", func(t *testing.T) {
+		outputFlag := "xml"
+		rt := new(ResourceTable)
+		rt.Init(showFlag, &hideHeadersFlag, &csvFlag, &fieldSeparatorFlag, &resourceSeparatorFlag, &listFieldsFlag, &defaultFieldValueFlag, &outputFlag, &templateFlag, &templateFlag)
+		_, outputErr := rt.RenderResources(resources)
+		if outputErr == nil {
+			t.Errorf("Expecting an error for an unrecognized output")
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onaio/sre-tooling/libs/types"
+	"gopkg.in/yaml.v2"
+)
+
+const configDirEnvVar string = "XDG_CONFIG_HOME"
+const fileName string = "providers.yaml"
+const envListSeparator string = ","
+
+// Config is the content of the providers configuration file, which declares the providers to use. The
+// keys in Providers are the names of the providers e.g. "aws", "gcp" or the name of a provider plugin
+type Config struct {
+	Providers map[string]*Provider `yaml:"providers"`
+}
+
+// Provider is the configuration of a provider. The keys in Credentials and Settings are specific to each
+// provider. Settings that are not set are read from the provider's environment variables
+type Provider struct {
+	Enabled     *bool             `yaml:"enabled"`
+	Credentials map[string]string `yaml:"credentials"`
+	Accounts    []string          `yaml:"accounts"`
+	Settings    map[string]string `yaml:"settings"`
+	Defaults    *Filter           `yaml:"defaults"`
+}
+
+// Filter holds the default filters of a provider. A default is only used when the same filter is not
+// set on the command line
+type Filter struct {
+	Accounts      []string          `yaml:"accounts"`
+	ResourceTypes []string          `yaml:"resource_types"`
+	Regions       []string          `yaml:"regions"`
+	Tags          map[string]string `yaml:"tags"`
+}
+
+// DefaultPath returns the path to the providers configuration file used if no other path is provided,
+// which is ~/.config/sre-tooling/providers.yaml unless XDG_CONFIG_HOME is set
+func DefaultPath() (string, error) {
+	configDir := os.Getenv(configDirEnvVar)
+	if len(configDir) == 0 {
+		home, homeErr := os.UserHomeDir()
+		if homeErr != nil {
+			return "", homeErr
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(configDir, "sre-tooling", fileName), nil
+}
+
+// Load reads the providers configuration file at path, or at the default path if path is empty. nil is
+// returned, without an error, if path is empty and there is no file at the default path
+func Load(path string) (*Config, error) {
+	if len(path) == 0 {
+		defaultPath, defaultPathErr := DefaultPath()
+		if defaultPathErr != nil {
+			return nil, nil
+		}
+		if _, statErr := os.Stat(defaultPath); os.IsNotExist(statErr) {
+			return nil, nil
+		}
+		path = defaultPath
+	}
+
+	content, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	config := new(Config)
+	parseErr := yaml.UnmarshalStrict(content, config)
+	if parseErr != nil {
+		return nil, fmt.Errorf("Could not parse the providers configuration file %s: %v", path, parseErr)
+	}
+
+	return config, nil
+}
+
+// GetProvider returns the configuration of the provider with the name, and whether the provider is
+// enabled. Case is ignored. Providers in the file are enabled unless enabled is set to false
+func (c *Config) GetProvider(name string) (*Provider, bool) {
+	for curName, curProvider := range c.Providers {
+		if !strings.EqualFold(curName, name) {
+			continue
+		}
+
+		if curProvider == nil {
+			curProvider = new(Provider)
+		}
+
+		return curProvider, curProvider.Enabled == nil || *curProvider.Enabled
+	}
+
+	return nil, false
+}
+
+// GetSetting returns the value of the credential or setting with the name. If it is not set, or the
+// provider has no configuration, the value of the environment variable is returned
+func (p *Provider) GetSetting(name string, envVar string) string {
+	if p != nil {
+		if value, ok := p.Credentials[name]; ok {
+			return value
+		}
+		if value, ok := p.Settings[name]; ok {
+			return value
+		}
+	}
+
+	if len(envVar) == 0 {
+		return ""
+	}
+
+	return os.Getenv(envVar)
+}
+
+// GetAccounts returns the provider's accounts. If they are not set, or the provider has no configuration,
+// the accounts in the comma-separated list in the environment variable are returned
+func (p *Provider) GetAccounts(envVar string) []string {
+	if p != nil && len(p.Accounts) > 0 {
+		return p.Accounts
+	}
+
+	accounts := []string{}
+	for _, curAccount := range strings.Split(os.Getenv(envVar), envListSeparator) {
+		curAccount = strings.TrimSpace(curAccount)
+		if len(curAccount) > 0 {
+			accounts = append(accounts, curAccount)
+		}
+	}
+
+	return accounts
+}
+
+// ApplyDefaults returns a copy of the filter with the provider's default filters set where the filter
+// doesn't have a value
+func (p *Provider) ApplyDefaults(filter *types.InfraFilter) *types.InfraFilter {
+	if p == nil || p.Defaults == nil {
+		return filter
+	}
+
+	filterWithDefaults := *filter
+	if len(filterWithDefaults.Accounts) == 0 {
+		filterWithDefaults.Accounts = p.Defaults.Accounts
+	}
+	if len(filterWithDefaults.ResourceTypes) == 0 {
+		filterWithDefaults.ResourceTypes = p.Defaults.ResourceTypes
+	}
+	if len(filterWithDefaults.Regions) == 0 {
+		filterWithDefaults.Regions = p.Defaults.Regions
+	}
+	if len(filterWithDefaults.Tags) == 0 {
+		filterWithDefaults.Tags = p.Defaults.Tags
+	}
+
+	return &filterWithDefaults
+}
+
+// ApplyCostDefaults returns a copy of the cost filter with the provider's default filters set where the
+// filter doesn't have a value
+func (p *Provider) ApplyCostDefaults(filter *types.CostAndUsageFilter) *types.CostAndUsageFilter {
+	if p == nil || p.Defaults == nil {
+		return filter
+	}
+
+	filterWithDefaults := *filter
+	if len(filterWithDefaults.Accounts) == 0 {
+		filterWithDefaults.Accounts = p.Defaults.Accounts
+	}
+	if len(filterWithDefaults.Regions) == 0 {
+		filterWithDefaults.Regions = p.Defaults.Regions
+	}
+	if len(filterWithDefaults.Tags) == 0 {
+		filterWithDefaults.Tags = p.Defaults.Tags
+	}
+
+	return &filterWithDefaults
+}
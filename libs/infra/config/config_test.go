@@ -0,0 +1,123 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const testConfig = `providers:
+  aws:
+    credentials:
+      profile: production
+      region: eu-west-1
+    accounts:
+    - arn:aws:iam::123456789012:role/sre-tooling
+    defaults:
+      regions:
+      - eu-west-1
+      tags:
+        Environment: production
+  GCP:
+    enabled: false
+  file:
+    settings:
+      inventory: /etc/sre-tooling/inventory.yml
+`
+
+func writeTestConfig(t *testing.T, content string) (string, func()) {
+	dir, dirErr := ioutil.TempDir("", "config")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+
+	path := filepath.Join(dir, fileName)
+	writeErr := ioutil.WriteFile(path, []byte(content), 0600)
+	if writeErr != nil {
+		t.Fatalf("Could not write the configuration file: %v", writeErr)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// Test whether providers, their settings and whether they are enabled are read from the file
+func TestLoad(t *testing.T) {
+	path, cleanup := writeTestConfig(t, testConfig)
+	defer cleanup()
+
+	config, configErr := Load(path)
+	if configErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", configErr)
+	}
+
+	awsConfig, awsEnabled := config.GetProvider("AWS")
+	if !awsEnabled || awsConfig.GetSetting("profile", "") != "production" || awsConfig.GetSetting("region", "") != "eu-west-1" {
+		t.Errorf("Expecting AWS to be enabled with its settings; got %+v", awsConfig)
+	}
+	if accounts := awsConfig.GetAccounts("SRE_INFRA_AWS_ACCOUNTS"); len(accounts) != 1 {
+		t.Errorf("Expecting the accounts in the file to be used; got %v", accounts)
+	}
+
+	if _, gcpEnabled := config.GetProvider("gcp"); gcpEnabled {
+		t.Errorf("Expecting GCP to be disabled")
+	}
+	if azureConfig, azureEnabled := config.GetProvider("azure"); azureConfig != nil || azureEnabled {
+		t.Errorf("Expecting Azure not to be configured")
+	}
+
+	_, invalidErr := Load(filepath.Join(filepath.Dir(path), "missing.yaml"))
+	if invalidErr == nil {
+		t.Errorf("Expecting an error for a file that doesn't exist")
+	}
+}
+
+// Test whether unknown fields are rejected so that typos aren't silently ignored
+func TestLoadUnknownField(t *testing.T) {
+	path, cleanup := writeTestConfig(t, "providers:\n  aws:\n    credential:\n      profile: production\n")
+	defer cleanup()
+
+	_, configErr := Load(path)
+	if configErr == nil {
+		t.Errorf("Expecting an error for the unknown credential field")
+	}
+}
+
+// Test whether settings fall back to the environment
+func TestGetSetting(t *testing.T) {
+	os.Setenv("SRE_TEST_SETTING", "from-environment")
+	defer os.Unsetenv("SRE_TEST_SETTING")
+
+	var noConfig *Provider
+	if value := noConfig.GetSetting("token", "SRE_TEST_SETTING"); value != "from-environment" {
+		t.Errorf("Expecting the environment variable to be used without a configuration; got '%s'", value)
+	}
+
+	config := &Provider{Credentials: map[string]string{"token": "from-file"}}
+	if value := config.GetSetting("token", "SRE_TEST_SETTING"); value != "from-file" {
+		t.Errorf("Expecting the credential in the file to be used; got '%s'", value)
+	}
+	if value := config.GetSetting("other", "SRE_TEST_SETTING"); value != "from-environment" {
+		t.Errorf("Expecting the environment variable to be used for settings not in the file; got '%s'", value)
+	}
+}
+
+// Test whether default filters are only used for filters not set on the command line
+func TestApplyDefaults(t *testing.T) {
+	config := &Provider{Defaults: &Filter{Regions: []string{"eu-west-1"}, Tags: map[string]string{"Environment": "production"}}}
+	filter := &types.InfraFilter{Regions: []string{"us-east-1"}}
+
+	filterWithDefaults := config.ApplyDefaults(filter)
+	if !reflect.DeepEqual(filterWithDefaults.Regions, []string{"us-east-1"}) {
+		t.Errorf("Expecting the region on the command line to be used; got %v", filterWithDefaults.Regions)
+	}
+	if filterWithDefaults.Tags["Environment"] != "production" {
+		t.Errorf("Expecting the default tags to be used; got %v", filterWithDefaults.Tags)
+	}
+	if filter.Tags != nil {
+		t.Errorf("Expecting the original filter not to be changed; got %+v", filter)
+	}
+}
@@ -0,0 +1,364 @@
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const digitalOceanProviderName string = "DigitalOcean"
+const resourceTypeDroplet string = "Droplet"
+const tokenEnvVar string = "SRE_INFRA_DIGITALOCEAN_TOKEN"
+const apiURL string = "https://api.digitalocean.com/v2"
+const pageSize = 200
+
+// tagSeparator separates the key and the value in DigitalOcean tags, which are plain strings
+const tagSeparator string = ":"
+
+// Droplet statuses
+const dropletStatusActive string = "active"
+const dropletStatusOff string = "off"
+
+const propertyState string = "state"
+
+// DigitalOcean fetches droplets from the DigitalOcean API
+type DigitalOcean struct {
+	config *config.Provider
+	api    *rest.Client
+}
+
+type dropletList struct {
+	Droplets []*droplet `json:"droplets"`
+	Links    *struct {
+		Pages *struct {
+			Next string `json:"next"`
+		} `json:"pages"`
+	} `json:"links"`
+}
+
+type dropletResponse struct {
+	Droplet *droplet `json:"droplet"`
+}
+
+type droplet struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	CreatedAt string   `json:"created_at"`
+	SizeSlug  string   `json:"size_slug"`
+	Memory    int      `json:"memory"`
+	VCPUs     int      `json:"vcpus"`
+	Disk      int      `json:"disk"`
+	Tags      []string `json:"tags"`
+	Region    *struct {
+		Slug string `json:"slug"`
+	} `json:"region"`
+	Image *struct {
+		Slug         string `json:"slug"`
+		Distribution string `json:"distribution"`
+	} `json:"image"`
+	Networks *struct {
+		V4 []*struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+	} `json:"networks"`
+}
+
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+type tagResourcesRequest struct {
+	Resources []*tagResource `json:"resources"`
+}
+
+type tagResource struct {
+	ResourceID   string `json:"resource_id"`
+	ResourceType string `json:"resource_type"`
+}
+
+type actionRequest struct {
+	Type string `json:"type"`
+}
+
+// IsConfigured checks whether the DigitalOcean API token has been set
+func IsConfigured() bool {
+	return len(os.Getenv(tokenEnvVar)) > 0
+}
+
+// New returns a DigitalOcean provider that uses the provided configuration instead of the environment
+func New(providerConfig *config.Provider) *DigitalOcean {
+	return &DigitalOcean{config: providerConfig}
+}
+
+func (d *DigitalOcean) GetName() string {
+	return digitalOceanProviderName
+}
+
+// Init initializes the provider using the API token in the provider's configuration or, if not
+// configured, the environment
+func (d *DigitalOcean) Init() error {
+	token := d.config.GetSetting("token", tokenEnvVar)
+	if len(token) == 0 {
+		return fmt.Errorf("The DigitalOcean API token needs to be set in %s or the providers configuration file to use the DigitalOcean provider", tokenEnvVar)
+	}
+
+	d.init(rest.NewClient(apiURL, rest.BearerToken(func() (string, error) { return token, nil })))
+
+	return nil
+}
+
+func (d *DigitalOcean) init(api *rest.Client) {
+	d.api = api
+}
+
+func (d *DigitalOcean) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	resources := []*types.InfraResource{}
+	if !filter.ConsiderResourceType(resourceTypeDroplet) {
+		return resources, nil
+	}
+
+	pageCount := 0
+	pagePath := "droplets"
+	query := url.Values{"per_page": {strconv.Itoa(pageSize)}}
+	for len(pagePath) > 0 {
+		page := new(dropletList)
+		pageErr := d.api.GetWithContext(ctx, pagePath, query, page)
+		if pageErr != nil {
+			// Return the droplets in the previous pages with the failure
+			return resources, types.ToInfraFailures(pageErr, digitalOceanProviderName, resourceTypeDroplet, "")
+		}
+		pageCount++
+
+		for _, curDroplet := range page.Droplets {
+			resource := getDropletResource(curDroplet)
+			if !filter.ConsiderRegion(resource.Location) || !filter.ConsiderTags(resource.Tags) {
+				continue
+			}
+
+			resources = append(resources, resource)
+		}
+
+		// The next page's link already has the query parameters
+		pagePath = ""
+		query = nil
+		if page.Links != nil && page.Links.Pages != nil {
+			pagePath = page.Links.Pages.Next
+		}
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d DigitalOcean droplets from %d pages", len(resources), pageCount))
+
+	return resources, nil
+}
+
+// getDropletResource converts the provided droplet into an InfraResource
+func getDropletResource(droplet *droplet) *types.InfraResource {
+	properties := map[string]string{
+		"id":          strconv.Itoa(droplet.ID),
+		"name":        droplet.Name,
+		propertyState: droplet.Status,
+		"size":        droplet.SizeSlug,
+		"memory":      strconv.Itoa(droplet.Memory),
+		"vcpus":       strconv.Itoa(droplet.VCPUs),
+		"disk":        strconv.Itoa(droplet.Disk),
+		"launch-time": droplet.CreatedAt,
+	}
+	if droplet.Image != nil {
+		properties["image"] = droplet.Image.Slug
+		properties["distribution"] = droplet.Image.Distribution
+	}
+	if droplet.Networks != nil {
+		for _, curNetwork := range droplet.Networks.V4 {
+			properties[curNetwork.Type+"-ip"] = curNetwork.IPAddress
+		}
+	}
+
+	location := ""
+	if droplet.Region != nil {
+		location = droplet.Region.Slug
+	}
+
+	resource := &types.InfraResource{
+		Provider:     digitalOceanProviderName,
+		ID:           strconv.Itoa(droplet.ID),
+		Location:     location,
+		ResourceType: resourceTypeDroplet,
+		Tags:         parseTags(droplet.Tags),
+		Properties:   properties,
+	}
+	if launchTime, launchTimeErr := time.Parse(time.RFC3339, droplet.CreatedAt); launchTimeErr == nil {
+		resource.LaunchTime = launchTime
+	}
+
+	return resource
+}
+
+// parseTags converts DigitalOcean's "key:value" tags into a map. Tags without a value are mapped to an
+// empty value
+func parseTags(doTags []string) map[string]string {
+	tags := make(map[string]string)
+	for _, curTag := range doTags {
+		tagParts := strings.SplitN(curTag, tagSeparator, 2)
+		if len(tagParts) == 2 {
+			tags[tagParts[0]] = tagParts[1]
+		} else {
+			tags[tagParts[0]] = ""
+		}
+	}
+
+	return tags
+}
+
+// formatTag converts the key and value into a DigitalOcean tag
+func formatTag(key string, value string) string {
+	if len(value) == 0 {
+		return key
+	}
+
+	return key + tagSeparator + value
+}
+
+// getDropletID returns the ID of the resource's droplet
+func getDropletID(resource *types.InfraResource) (string, error) {
+	if resource.Provider != digitalOceanProviderName {
+		return "", fmt.Errorf("Resource's provider is %s instead of %s", resource.Provider, digitalOceanProviderName)
+	}
+	if resource.ResourceType != resourceTypeDroplet {
+		return "", fmt.Errorf("Resources of type '%s' are not supported by the %s provider", resource.ResourceType, digitalOceanProviderName)
+	}
+	if len(resource.ID) == 0 {
+		return "", fmt.Errorf("The ID of the DigitalOcean droplet is not set")
+	}
+
+	return resource.ID, nil
+}
+
+// getDroplet fetches the droplet with the provided ID
+func (d *DigitalOcean) getDroplet(dropletID string) (*droplet, error) {
+	response := new(dropletResponse)
+	getErr := d.api.Get("droplets/"+url.PathEscape(dropletID), nil, response)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if response.Droplet == nil {
+		return nil, fmt.Errorf("DigitalOcean didn't return the droplet '%s'", dropletID)
+	}
+
+	return response.Droplet, nil
+}
+
+// UpdateResourceTag sets the value of the droplet's tag. Since DigitalOcean tags are plain strings, the
+// "key:value" tag is created and attached to the droplet, and any other tag with the same key is detached.
+// If tagValue is nil, all the droplet's tags with the key are detached
+func (d *DigitalOcean) UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	dropletID, dropletIDErr := getDropletID(resource)
+	if dropletIDErr != nil {
+		return dropletIDErr
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the DigitalOcean droplet tag because the tag key is not set")
+	}
+
+	curDroplet, dropletErr := d.getDroplet(dropletID)
+	if dropletErr != nil {
+		return dropletErr
+	}
+
+	resources := &tagResourcesRequest{
+		Resources: []*tagResource{&tagResource{ResourceID: dropletID, ResourceType: "droplet"}},
+	}
+
+	newTag := ""
+	if tagValue != nil {
+		newTag = formatTag(*tagKey, *tagValue)
+
+		// Creating a tag that already exists is not an error
+		createErr := d.api.Post("tags", nil, &tagRequest{Name: newTag}, nil)
+		if createErr != nil {
+			return createErr
+		}
+		attachErr := d.api.Post("tags/"+url.PathEscape(newTag)+"/resources", nil, resources, nil)
+		if attachErr != nil {
+			return attachErr
+		}
+	}
+
+	for _, curTag := range curDroplet.Tags {
+		if curTag == newTag || strings.SplitN(curTag, tagSeparator, 2)[0] != *tagKey {
+			continue
+		}
+
+		detachErr := d.api.Do("DELETE", "tags/"+url.PathEscape(curTag)+"/resources", nil, resources, nil)
+		if detachErr != nil {
+			return detachErr
+		}
+	}
+
+	return nil
+}
+
+// UpdateResourceState powers off, powers on or deletes the droplet. In safe mode, only droplets that
+// are off are deleted
+func (d *DigitalOcean) UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	dropletID, dropletIDErr := getDropletID(resource)
+	if dropletIDErr != nil {
+		return dropletIDErr
+	}
+
+	// Fetch the droplet again since power actions are asynchronous and might have changed its status
+	// since it was listed
+	curDroplet, dropletErr := d.getDroplet(dropletID)
+	if dropletErr != nil {
+		return dropletErr
+	}
+
+	actionsPath := "droplets/" + url.PathEscape(dropletID) + "/actions"
+	switch state {
+	case types.ResourceStateStopped:
+		if curDroplet.Status == dropletStatusOff {
+			return nil
+		}
+
+		return d.api.Post(actionsPath, nil, &actionRequest{Type: "power_off"}, nil)
+	case types.ResourceStateRunning:
+		if curDroplet.Status == dropletStatusActive {
+			return nil
+		}
+
+		return d.api.Post(actionsPath, nil, &actionRequest{Type: "power_on"}, nil)
+	case types.ResourceStateTerminated:
+		if safe && curDroplet.Status != dropletStatusOff {
+			return fmt.Errorf("Refusing to delete the DigitalOcean droplet '%s' because it is '%s' instead of '%s'", resource.ID, curDroplet.Status, dropletStatusOff)
+		}
+
+		return d.api.Do("DELETE", "droplets/"+url.PathEscape(dropletID), nil, nil, nil)
+	}
+
+	return fmt.Errorf("Cannot update the state of a DigitalOcean droplet to '%s'", state)
+}
+
+// GetCostsAndUsages returns no costs since the DigitalOcean API doesn't break costs down by service,
+// region or tag
+func (d *DigitalOcean) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	notification.SendVerboseMessage("Costs are not available for DigitalOcean")
+
+	return &types.CostAndUsageOutput{
+		Provider: digitalOceanProviderName,
+		Groups:   make(map[string]float64),
+		Period: &types.CostAndUsagePeriod{
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+		},
+	}, nil
+}
@@ -0,0 +1,166 @@
+package digitalocean
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/infra/rest/resttest"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// fakeAPI is a local fake of the parts of the DigitalOcean API used by the provider
+type fakeAPI struct {
+	server   *httptest.Server
+	requests []string
+	actions  []string
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/droplets", func(w http.ResponseWriter, r *http.Request) {
+		if !resttest.IsAuthorized(w, r) {
+			return
+		}
+
+		// Return the droplets across two pages to test pagination
+		if r.URL.Query().Get("page") == "" {
+			resttest.WriteJSON(t, w, map[string]interface{}{
+				"droplets": []interface{}{getDroplet(1, "ams3", "active")},
+				"links":    map[string]interface{}{"pages": map[string]string{"next": api.server.URL + "/droplets?page=2&per_page=1"}},
+			})
+			return
+		}
+
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"droplets": []interface{}{getDroplet(2, "nyc1", "off")},
+			"links":    map[string]interface{}{},
+		})
+	})
+	mux.HandleFunc("/droplets/1", func(w http.ResponseWriter, r *http.Request) {
+		resttest.WriteJSON(t, w, map[string]interface{}{"droplet": getDroplet(1, "ams3", "active")})
+	})
+	mux.HandleFunc("/droplets/1/actions", func(w http.ResponseWriter, r *http.Request) {
+		action := new(actionRequest)
+		if !resttest.ReadJSON(t, w, r, action) {
+			return
+		}
+		api.actions = append(api.actions, action.Type)
+		resttest.WriteJSON(t, w, map[string]interface{}{})
+	})
+	mux.HandleFunc("/tags", func(w http.ResponseWriter, r *http.Request) {
+		tag := new(tagRequest)
+		if !resttest.ReadJSON(t, w, r, tag) {
+			return
+		}
+		api.requests = append(api.requests, r.Method+" tag "+tag.Name)
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/tags/", func(w http.ResponseWriter, r *http.Request) {
+		api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	api.server = httptest.NewServer(mux)
+
+	return api
+}
+
+func getDroplet(id int, region string, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id,
+		"name":       "droplet",
+		"status":     status,
+		"created_at": "2020-05-01T10:00:00Z",
+		"size_slug":  "s-1vcpu-1gb",
+		"tags":       []string{"owner:alice", "env:dev", "web"},
+		"region":     map[string]string{"slug": region},
+		"networks": map[string]interface{}{
+			"v4": []interface{}{map[string]string{"ip_address": "10.0.0.2", "type": "private"}, map[string]string{"ip_address": "1.2.3.4", "type": "public"}},
+		},
+	}
+}
+
+func (api *fakeAPI) getProvider() *DigitalOcean {
+	provider := new(DigitalOcean)
+	provider.init(rest.NewClient(api.server.URL, resttest.Authorize))
+
+	return provider
+}
+
+// Test whether "key:value" tags are mapped to tag keys and values
+func TestParseTags(t *testing.T) {
+	tags := parseTags([]string{"owner:alice", "url:https://example.com", "web"})
+	expected := map[string]string{"owner": "alice", "url": "https://example.com", "web": ""}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expecting %v; got %v", expected, tags)
+	}
+}
+
+// Test whether GetResources follows all the pages of droplets and applies the filter
+func TestGetResources(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 2 {
+		t.Fatalf("Expecting 2 droplets from the 2 pages; got %d and error %v", len(resources), resourcesErr)
+	}
+
+	resources, resourcesErr = provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"ams3"}, Tags: map[string]string{"owner": "alice"}})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 droplet in ams3; got %d and error %v", len(resources), resourcesErr)
+	}
+
+	resource := resources[0]
+	if resource.ID != "1" || resource.ResourceType != resourceTypeDroplet || resource.Tags["env"] != "dev" {
+		t.Errorf("Unexpected resource %+v", resource)
+	}
+	if resource.Properties["public-ip"] != "1.2.3.4" || resource.Properties[propertyState] != "active" {
+		t.Errorf("Unexpected resource properties %v", resource.Properties)
+	}
+}
+
+// Test whether UpdateResourceTag attaches the new tag and detaches the tag with the old value
+func TestUpdateResourceTag(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	tagKey := "env"
+	tagValue := "prod"
+	resource := &types.InfraResource{Provider: digitalOceanProviderName, ResourceType: resourceTypeDroplet, ID: "1"}
+	updateErr := provider.UpdateResourceTag(resource, &tagKey, &tagValue)
+	if updateErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", updateErr)
+	}
+
+	expected := []string{"DELETE /tags/env:dev/resources", "POST /tags/env:prod/resources", "POST tag env:prod"}
+	sort.Strings(api.requests)
+	if !reflect.DeepEqual(api.requests, expected) {
+		t.Errorf("Expecting requests %v; got %v", expected, api.requests)
+	}
+}
+
+// Test whether UpdateResourceState powers off active droplets and refuses to delete them in safe mode
+func TestUpdateResourceState(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	resource := &types.InfraResource{Provider: digitalOceanProviderName, ResourceType: resourceTypeDroplet, ID: "1"}
+	stopErr := provider.UpdateResourceState(resource, true, types.ResourceStateStopped)
+	if stopErr != nil || !reflect.DeepEqual(api.actions, []string{"power_off"}) {
+		t.Errorf("Expecting the droplet to be powered off; got actions %v and error %v", api.actions, stopErr)
+	}
+
+	deleteErr := provider.UpdateResourceState(resource, true, types.ResourceStateTerminated)
+	if deleteErr == nil {
+		t.Errorf("Expecting an error when deleting an active droplet in safe mode")
+	}
+}
@@ -0,0 +1,298 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+	"gopkg.in/yaml.v2"
+)
+
+const fileProviderName string = "file"
+const inventoryEnvVar string = "SRE_INFRA_FILE_INVENTORY"
+const propertyState string = "state"
+
+// propertyProvider holds the provider set in the inventory record, if any. Resources loaded from the
+// inventory always have the file provider as their provider so that updates are routed back to the file
+const propertyProvider string = "provider"
+
+// inventoryMutex stops concurrent tag and state updates from overwriting each other's changes
+var inventoryMutex sync.Mutex
+
+// File loads resources from a YAML or JSON inventory file. Inventory files whose name ends in ".json" are
+// read as JSON and all others as YAML
+type File struct {
+	config *config.Provider
+	path   string
+}
+
+// inventory is the content of an inventory file
+type inventory struct {
+	Resources []*record `yaml:"resources" json:"resources"`
+}
+
+// record is a resource in an inventory file
+type record struct {
+	Provider   string            `yaml:"provider,omitempty" json:"provider,omitempty"`
+	ID         string            `yaml:"id" json:"id"`
+	Location   string            `yaml:"location,omitempty" json:"location,omitempty"`
+	Type       string            `yaml:"type" json:"type"`
+	LaunchTime string            `yaml:"launch_time,omitempty" json:"launch_time,omitempty"`
+	Tags       map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Properties map[string]string `yaml:"properties,omitempty" json:"properties,omitempty"`
+}
+
+// IsConfigured checks whether the path to the inventory file has been set
+func IsConfigured() bool {
+	return len(os.Getenv(inventoryEnvVar)) > 0
+}
+
+// New returns a file provider that uses the provided configuration instead of the environment
+func New(providerConfig *config.Provider) *File {
+	return &File{config: providerConfig}
+}
+
+func (f *File) GetName() string {
+	return fileProviderName
+}
+
+// Init initializes the provider using the inventory file in the provider's configuration or, if not
+// configured, the environment
+func (f *File) Init() error {
+	path := f.config.GetSetting("inventory", inventoryEnvVar)
+	if len(path) == 0 {
+		return fmt.Errorf("The inventory file needs to be set in %s or the providers configuration file to use the file provider", inventoryEnvVar)
+	}
+
+	f.init(path)
+
+	return nil
+}
+
+func (f *File) init(path string) {
+	f.path = path
+}
+
+func (f *File) isJSON() bool {
+	return strings.ToLower(filepath.Ext(f.path)) == ".json"
+}
+
+// readInventory reads and parses the inventory file
+func (f *File) readInventory() (*inventory, error) {
+	content, readErr := ioutil.ReadFile(f.path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	inventory := new(inventory)
+	var parseErr error
+	if f.isJSON() {
+		parseErr = json.Unmarshal(content, inventory)
+	} else {
+		parseErr = yaml.UnmarshalStrict(content, inventory)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("Could not parse the inventory file %s: %v", f.path, parseErr)
+	}
+
+	for i, curRecord := range inventory.Resources {
+		if len(curRecord.ID) == 0 || len(curRecord.Type) == 0 {
+			return nil, fmt.Errorf("Resource %d in the inventory file %s doesn't have an id or a type", i+1, f.path)
+		}
+		if len(curRecord.LaunchTime) > 0 {
+			if _, timeErr := time.Parse(time.RFC3339, curRecord.LaunchTime); timeErr != nil {
+				return nil, fmt.Errorf("The launch time of the resource '%s' in the inventory file %s is not in the RFC 3339 format", curRecord.ID, f.path)
+			}
+		}
+	}
+
+	return inventory, nil
+}
+
+// writeInventory writes the inventory to a temporary file that then replaces the inventory file, so that
+// the inventory file isn't left half written if writing fails
+func (f *File) writeInventory(inventory *inventory) error {
+	var content []byte
+	var marshalErr error
+	if f.isJSON() {
+		content, marshalErr = json.MarshalIndent(inventory, "", "  ")
+	} else {
+		content, marshalErr = yaml.Marshal(inventory)
+	}
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(f.path); statErr == nil {
+		mode = info.Mode()
+	}
+
+	tmpFile, tmpErr := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if tmpErr != nil {
+		return tmpErr
+	}
+	_, writeErr := tmpFile.Write(content)
+	closeErr := tmpFile.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tmpFile.Name(), mode)
+	}
+	if writeErr == nil {
+		writeErr = os.Rename(tmpFile.Name(), f.path)
+	}
+	if writeErr != nil {
+		os.Remove(tmpFile.Name())
+		return writeErr
+	}
+
+	return nil
+}
+
+func (f *File) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	inventory, inventoryErr := f.readInventory()
+	if inventoryErr != nil {
+		return nil, inventoryErr
+	}
+
+	resources := []*types.InfraResource{}
+	for _, curRecord := range inventory.Resources {
+		resource := getRecordResource(curRecord)
+		if !filter.ConsiderResourceType(resource.ResourceType) || !filter.ConsiderRegion(resource.Location) || !filter.ConsiderTags(resource.Tags) {
+			continue
+		}
+		if !filter.ConsiderAccount(resource.Properties["account-id"]) {
+			continue
+		}
+
+		resources = append(resources, resource)
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Loaded %d resources from %s", len(resources), f.path))
+
+	return resources, nil
+}
+
+// getRecordResource converts the provided inventory record into an InfraResource
+func getRecordResource(record *record) *types.InfraResource {
+	resource := &types.InfraResource{
+		Provider:     fileProviderName,
+		ID:           record.ID,
+		Location:     record.Location,
+		ResourceType: record.Type,
+		Tags:         make(map[string]string),
+		Properties:   make(map[string]string),
+	}
+	for curKey, curValue := range record.Tags {
+		resource.Tags[curKey] = curValue
+	}
+	for curKey, curValue := range record.Properties {
+		resource.Properties[curKey] = curValue
+	}
+	if len(record.Provider) > 0 {
+		resource.Properties[propertyProvider] = record.Provider
+	}
+	if launchTime, launchTimeErr := time.Parse(time.RFC3339, record.LaunchTime); launchTimeErr == nil {
+		resource.LaunchTime = launchTime
+		resource.Properties["launch-time"] = record.LaunchTime
+	}
+
+	return resource
+}
+
+// updateRecord applies update to the resource's record in the inventory file and writes the file back
+func (f *File) updateRecord(resource *types.InfraResource, update func(record *record) error) error {
+	if resource.Provider != fileProviderName {
+		return fmt.Errorf("Resource's provider is %s instead of %s", resource.Provider, fileProviderName)
+	}
+
+	inventoryMutex.Lock()
+	defer inventoryMutex.Unlock()
+
+	inventory, inventoryErr := f.readInventory()
+	if inventoryErr != nil {
+		return inventoryErr
+	}
+
+	for _, curRecord := range inventory.Resources {
+		if curRecord.ID != resource.ID || curRecord.Type != resource.ResourceType {
+			continue
+		}
+
+		updateErr := update(curRecord)
+		if updateErr != nil {
+			return updateErr
+		}
+
+		return f.writeInventory(inventory)
+	}
+
+	return fmt.Errorf("The %s resource '%s' is not in the inventory file %s", resource.ResourceType, resource.ID, f.path)
+}
+
+// UpdateResourceTag sets the value of the tag in the resource's record in the inventory file, or removes
+// the tag if tagValue is nil
+func (f *File) UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the resource's tag because the tag key is not set")
+	}
+
+	return f.updateRecord(resource, func(record *record) error {
+		if tagValue == nil {
+			delete(record.Tags, *tagKey)
+			return nil
+		}
+
+		if record.Tags == nil {
+			record.Tags = make(map[string]string)
+		}
+		record.Tags[*tagKey] = *tagValue
+
+		return nil
+	})
+}
+
+// UpdateResourceState sets the state property in the resource's record in the inventory file. In safe
+// mode, only stopped resources are marked as terminated
+func (f *File) UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	if state != types.ResourceStateRunning && state != types.ResourceStateStopped && state != types.ResourceStateTerminated {
+		return fmt.Errorf("Cannot update the state of a resource in the inventory file to '%s'", state)
+	}
+
+	return f.updateRecord(resource, func(record *record) error {
+		curState := record.Properties[propertyState]
+		if safe && state == types.ResourceStateTerminated && curState != types.ResourceStateStopped {
+			return fmt.Errorf("Refusing to terminate the resource '%s' because it is '%s' instead of '%s'", resource.ID, curState, types.ResourceStateStopped)
+		}
+
+		if record.Properties == nil {
+			record.Properties = make(map[string]string)
+		}
+		record.Properties[propertyState] = state
+
+		return nil
+	})
+}
+
+// GetCostsAndUsages returns no costs since the inventory file doesn't have billing data
+func (f *File) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	return &types.CostAndUsageOutput{
+		Provider: fileProviderName,
+		Groups:   make(map[string]float64),
+		Period: &types.CostAndUsagePeriod{
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+		},
+	}, nil
+}
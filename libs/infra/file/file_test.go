@@ -0,0 +1,135 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const testInventory = `resources:
+- provider: bare-metal
+  id: server-1
+  location: nairobi
+  type: Server
+  launch_time: "2020-05-01T10:00:00Z"
+  tags:
+    owner: alice
+  properties:
+    public-ip: 10.0.0.1
+    state: running
+- id: server-2
+  location: amsterdam
+  type: Server
+  properties:
+    state: stopped
+`
+
+func newTestProvider(t *testing.T, name string, content string) (*File, func()) {
+	dir, dirErr := ioutil.TempDir("", "inventory")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+
+	path := filepath.Join(dir, name)
+	writeErr := ioutil.WriteFile(path, []byte(content), 0644)
+	if writeErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", writeErr)
+	}
+
+	provider := new(File)
+	provider.init(path)
+
+	return provider, func() { os.RemoveAll(dir) }
+}
+
+// Test whether GetResources loads the records in YAML and JSON inventory files and applies the filter
+func TestGetResources(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		provider, cleanup := newTestProvider(t, "inventory.yml", testInventory)
+		defer cleanup()
+
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Tags: map[string]string{"owner": "alice"}})
+		if resourcesErr != nil || len(resources) != 1 {
+			t.Fatalf("Expecting 1 resource; got %d and error %v", len(resources), resourcesErr)
+		}
+
+		resource := resources[0]
+		if resource.Provider != fileProviderName || resource.ID != "server-1" || resource.Location != "nairobi" || resource.ResourceType != "Server" {
+			t.Errorf("Unexpected resource %+v", resource)
+		}
+		if resource.Properties[propertyProvider] != "bare-metal" || resource.Properties["public-ip"] != "10.0.0.1" {
+			t.Errorf("Unexpected resource properties %v", resource.Properties)
+		}
+		if resource.LaunchTime.IsZero() {
+			t.Errorf("Expecting the launch time to be set")
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		provider, cleanup := newTestProvider(t, "inventory.json", `{"resources": [{"id": "server-1", "type": "Server", "location": "nairobi"}]}`)
+		defer cleanup()
+
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"Nairobi"}})
+		if resourcesErr != nil || len(resources) != 1 {
+			t.Fatalf("Expecting 1 resource; got %d and error %v", len(resources), resourcesErr)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		provider, cleanup := newTestProvider(t, "inventory.yml", "resources:\n- location: nairobi\n")
+		defer cleanup()
+
+		_, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+		if resourcesErr == nil {
+			t.Errorf("Expecting an error for a resource without an id or a type")
+		}
+	})
+}
+
+// Test whether tag and state updates, and removed tags, are written back to the inventory file
+func TestUpdateResource(t *testing.T) {
+	provider, cleanup := newTestProvider(t, "inventory.yml", testInventory)
+	defer cleanup()
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 2 {
+		t.Fatalf("Expecting 2 resources; got %d and error %v", len(resources), resourcesErr)
+	}
+
+	tagKey := "EndDate"
+	tagValue := "2020-06-01"
+	tagErr := provider.UpdateResourceTag(resources[0], &tagKey, &tagValue)
+	if tagErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", tagErr)
+	}
+	removedKey := "owner"
+	removeErr := provider.UpdateResourceTag(resources[0], &removedKey, nil)
+	if removeErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", removeErr)
+	}
+
+	safeErr := provider.UpdateResourceState(resources[0], true, types.ResourceStateTerminated)
+	if safeErr == nil || !strings.Contains(safeErr.Error(), "Refusing") {
+		t.Errorf("Expecting an error when terminating a running resource in safe mode; got %v", safeErr)
+	}
+	stateErr := provider.UpdateResourceState(resources[1], true, types.ResourceStateTerminated)
+	if stateErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", stateErr)
+	}
+
+	updated, updatedErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+	if updatedErr != nil || len(updated) != 2 {
+		t.Fatalf("Expecting 2 resources; got %d and error %v", len(updated), updatedErr)
+	}
+	if _, hasOwner := updated[0].Tags["owner"]; updated[0].Tags["EndDate"] != "2020-06-01" || hasOwner {
+		t.Errorf("Expecting the new tag to be added and the removed tag to be gone; got %v", updated[0].Tags)
+	}
+	if updated[0].Properties[propertyState] != types.ResourceStateRunning || updated[1].Properties[propertyState] != types.ResourceStateTerminated {
+		t.Errorf("Unexpected states '%s' and '%s'", updated[0].Properties[propertyState], updated[1].Properties[propertyState])
+	}
+}
@@ -0,0 +1,289 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// Expression is a parsed filter expression e.g. `tag:Environment == production && age > 30d`
+type Expression struct {
+	source string
+	root   node
+}
+
+// Parse parses the filter expression. See the README for the syntax
+func Parse(source string) (*Expression, error) {
+	tokens, tokensErr := tokenize(source)
+	if tokensErr != nil {
+		return nil, fmt.Errorf("Invalid filter expression. %s", tokensErr.Error())
+	}
+	if tokens[0].kind == tokenEOF {
+		return nil, fmt.Errorf("Invalid filter expression. The expression is empty")
+	}
+
+	p := &parser{tokens: tokens}
+	root, rootErr := p.parseOr()
+	if rootErr != nil {
+		return nil, fmt.Errorf("Invalid filter expression. %s", rootErr.Error())
+	}
+	if remaining := p.peek(); remaining.kind != tokenEOF {
+		return nil, fmt.Errorf("Invalid filter expression. Expecting '&&' or '||'; got %s", remaining.String())
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// Matches checks whether the resource matches the expression
+func (e *Expression) Matches(resource *types.InfraResource) bool {
+	return e.root.matches(resource, time.Now())
+}
+
+// String returns the expression as it was provided to Parse
+func (e *Expression) String() string {
+	return e.source
+}
+
+// MarshalJSON encodes the expression as its source e.g. when sending filters to provider plugins
+func (e *Expression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.source)
+}
+
+// RequiredValues returns the values that resources need to have in the tag and property fields
+// (e.g. "tag:Owner" or "property:state") compared to values in the expression, for providers to use to
+// narrow down the resources they fetch. A resource needs to have one of the values of each field.
+// Fields in terms that aren't required by the whole expression, e.g. because they are in an OR group
+// with other fields, are left out
+func RequiredValues(expression types.InfraExpression) map[string][]string {
+	values := make(map[string][]string)
+	parsed, ok := expression.(*Expression)
+	if !ok || parsed == nil {
+		return values
+	}
+
+	for _, curTerm := range getAndTerms(parsed.root) {
+		fieldName, fieldValues := getEqualityValues(curTerm)
+		if len(fieldName) == 0 {
+			continue
+		}
+		if _, exists := values[fieldName]; exists {
+			// A second term for the same field can only narrow the values down further. Leave it to the
+			// client-side evaluation
+			continue
+		}
+		values[fieldName] = fieldValues
+	}
+
+	return values
+}
+
+// getAndTerms returns the terms of the top-level AND group
+func getAndTerms(root node) []node {
+	and, ok := root.(*andNode)
+	if !ok {
+		return []node{root}
+	}
+
+	return append(getAndTerms(and.left), getAndTerms(and.right)...)
+}
+
+// getEqualityValues returns the tag or property field compared in the node and the values compared to if
+// the node is an equality comparison or an OR group of equality comparisons on the same field
+func getEqualityValues(term node) (string, []string) {
+	switch curTerm := term.(type) {
+	case *comparisonNode:
+		if curTerm.operator != "==" || (curTerm.field.name != tagFieldPrefix && curTerm.field.name != propertyFieldPrefix) {
+			return "", nil
+		}
+
+		return curTerm.field.String(), []string{curTerm.value}
+	case *orNode:
+		leftName, leftValues := getEqualityValues(curTerm.left)
+		rightName, rightValues := getEqualityValues(curTerm.right)
+		if len(leftName) == 0 || leftName != rightName {
+			return "", nil
+		}
+
+		return leftName, append(leftValues, rightValues...)
+	}
+
+	return "", nil
+}
+
+// node is a part of a parsed expression
+type node interface {
+	matches(resource *types.InfraResource, now time.Time) bool
+}
+
+type andNode struct {
+	left  node
+	right node
+}
+
+func (n *andNode) matches(resource *types.InfraResource, now time.Time) bool {
+	return n.left.matches(resource, now) && n.right.matches(resource, now)
+}
+
+type orNode struct {
+	left  node
+	right node
+}
+
+func (n *orNode) matches(resource *types.InfraResource, now time.Time) bool {
+	return n.left.matches(resource, now) || n.right.matches(resource, now)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) matches(resource *types.InfraResource, now time.Time) bool {
+	return !n.operand.matches(resource, now)
+}
+
+// presenceNode checks whether the resource has the field e.g. whether it has a tag
+type presenceNode struct {
+	field *field
+}
+
+func (n *presenceNode) matches(resource *types.InfraResource, now time.Time) bool {
+	_, present := n.field.getValue(resource)
+	return present
+}
+
+// comparisonNode compares a field to a value. Resources without the field only match != and !~
+type comparisonNode struct {
+	field         *field
+	operator      string
+	value         string
+	pattern       *regexp.Regexp
+	timeValue     time.Time
+	durationValue time.Duration
+}
+
+func (n *comparisonNode) matches(resource *types.InfraResource, now time.Time) bool {
+	switch n.field.name {
+	case fieldLaunchTime:
+		if resource.LaunchTime.IsZero() {
+			return false
+		}
+
+		return compareOrdering(n.operator, compareTimes(resource.LaunchTime, n.timeValue))
+	case fieldAge:
+		if resource.LaunchTime.IsZero() {
+			return false
+		}
+
+		return compareOrdering(n.operator, compareDurations(now.Sub(resource.LaunchTime), n.durationValue))
+	}
+
+	value, present := n.field.getValue(resource)
+	switch n.operator {
+	case "==":
+		return present && value == n.value
+	case "!=":
+		return !present || value != n.value
+	case "=~":
+		return present && n.pattern.MatchString(value)
+	case "!~":
+		return !present || !n.pattern.MatchString(value)
+	}
+
+	return present && compareOrdering(n.operator, compareValues(value, n.value))
+}
+
+// compareValues compares the values as numbers if both are numbers or as strings otherwise
+func compareValues(a string, b string) int {
+	aNumber, aErr := strconv.ParseFloat(a, 64)
+	bNumber, bErr := strconv.ParseFloat(b, 64)
+	if aErr != nil || bErr != nil {
+		return strings.Compare(a, b)
+	}
+
+	switch {
+	case aNumber < bNumber:
+		return -1
+	case aNumber > bNumber:
+		return 1
+	}
+
+	return 0
+}
+
+func compareTimes(a time.Time, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+
+	return 0
+}
+
+func compareDurations(a time.Duration, b time.Duration) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// compareOrdering checks whether the result of a comparison satisfies the ordering operator
+func compareOrdering(operator string, comparison int) bool {
+	switch operator {
+	case "<":
+		return comparison < 0
+	case "<=":
+		return comparison <= 0
+	case ">":
+		return comparison > 0
+	case ">=":
+		return comparison >= 0
+	}
+
+	return false
+}
+
+// field is a tag or property (in which case name is the prefix and key is set) or one of the
+// resource's fields
+type field struct {
+	name string
+	key  string
+}
+
+func (f *field) String() string {
+	return f.name + f.key
+}
+
+// getValue returns the field's value in the resource and whether the resource has the field
+func (f *field) getValue(resource *types.InfraResource) (string, bool) {
+	switch f.name {
+	case tagFieldPrefix:
+		value, present := resource.Tags[f.key]
+		return value, present
+	case propertyFieldPrefix:
+		value, present := resource.Properties[f.key]
+		return value, present
+	case fieldID:
+		return resource.ID, len(resource.ID) > 0
+	case fieldProvider:
+		return resource.Provider, len(resource.Provider) > 0
+	case fieldType:
+		return resource.ResourceType, len(resource.ResourceType) > 0
+	case fieldLocation:
+		return resource.Location, len(resource.Location) > 0
+	case fieldLaunchTime, fieldAge:
+		return resource.LaunchTime.Format(time.RFC3339), !resource.LaunchTime.IsZero()
+	}
+
+	return "", false
+}
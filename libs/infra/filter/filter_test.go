@@ -0,0 +1,134 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+func getTestResource() *types.InfraResource {
+	return &types.InfraResource{
+		Provider:     "AWS",
+		ID:           "i-1234",
+		Location:     "eu-west-1",
+		ResourceType: "EC2",
+		LaunchTime:   time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC),
+		Tags:         map[string]string{"Environment": "production", "Owner": "sre", "my key": "x"},
+		Properties:   map[string]string{"state": "running", "cpu-count": "16"},
+	}
+}
+
+// Test whether expressions are matched against the resource's fields as expected
+func TestMatches(t *testing.T) {
+	resource := getTestResource()
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		expression string
+		expected   bool
+	}{
+		{"property:state == running", true},
+		{"property:state == Running", false},
+		{"property:state != running", false},
+		{"tag:Environment == production && tag:Owner == sre", true},
+		{"tag:Environment == production and tag:Owner == dev", false},
+		{"tag:Owner == dev || tag:Owner == sre", true},
+		{"tag:Owner == dev or (tag:Environment == production && type == EC2)", true},
+		{"tag:Owner =~ '^s.e$'", true},
+		{"id =~ \"^i-[0-9]+$\"", true},
+		{"location !~ ^eu-", false},
+		{"tag:Missing != x", true},
+		{"tag:Missing == x", false},
+		{"tag:Missing !~ x", true},
+		{"tag:Owner", true},
+		{"!tag:Owner", false},
+		{"!tag:Missing", true},
+		{"not tag:Missing && provider == AWS", true},
+		{"!(tag:Owner == sre)", false},
+		{"\"tag:my key\" == x", true},
+		{"property:cpu-count > 8", true},
+		{"property:cpu-count <= 8", false},
+		{"launch-time < 2020-05-02", true},
+		{"launch-time >= 2020-05-01T11:00:00Z", false},
+		{"age > 30d", true},
+		{"age > 31d", false},
+		{"age < 1d12h", false},
+		{"TYPE == EC2", true},
+	}
+
+	for _, curTestCase := range testCases {
+		expression, parseErr := Parse(curTestCase.expression)
+		if parseErr != nil {
+			t.Errorf("Expecting '%s' to be parsed; got %v", curTestCase.expression, parseErr)
+			continue
+		}
+
+		if matches := expression.root.matches(resource, now); matches != curTestCase.expected {
+			t.Errorf("Expecting '%s' to return %t; got %t", curTestCase.expression, curTestCase.expected, matches)
+		}
+	}
+}
+
+// Test whether invalid expressions are rejected with the position of the error
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		expression    string
+		expectedError string
+	}{
+		{"", "empty"},
+		{"tag:Owner = sre", "'=' at position 11"},
+		{"tag:Owner ==", "Expecting a value"},
+		{"tag:Owner == sre tag:Environment", "position 18"},
+		{"(tag:Owner == sre", "Expecting ')'"},
+		{"name == x", "Unknown field 'name'"},
+		{"tag: == x", "Missing the key"},
+		{"tag:Owner =~ '['", "Invalid regular expression"},
+		{"tag:Owner == 'sre", "Missing closing '"},
+		{"launch-time == 2020-05-01", "Only <, <=, > and >="},
+		{"launch-time < yesterday", "Expecting a date"},
+		{"age > 30 days", "Expecting a duration"},
+		{"&& tag:Owner", "Expecting a field"},
+	}
+
+	for _, curTestCase := range testCases {
+		_, parseErr := Parse(curTestCase.expression)
+		if parseErr == nil {
+			t.Errorf("Expecting '%s' to be rejected", curTestCase.expression)
+			continue
+		}
+
+		if !strings.Contains(parseErr.Error(), curTestCase.expectedError) {
+			t.Errorf("Expecting the error for '%s' to contain \"%s\"; got \"%v\"", curTestCase.expression, curTestCase.expectedError, parseErr)
+		}
+	}
+}
+
+// Test whether RequiredValues only returns the values required by the whole expression
+func TestRequiredValues(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   map[string][]string
+	}{
+		{"tag:Owner == sre && property:state == running", map[string][]string{"tag:Owner": {"sre"}, "property:state": {"running"}}},
+		{"(property:state == running || property:state == stopped) && age > 1d", map[string][]string{"property:state": {"running", "stopped"}}},
+		{"tag:Owner == sre || property:state == running", map[string][]string{}},
+		{"!(tag:Owner == sre) && tag:Owner != dev && id == i-1234", map[string][]string{}},
+	}
+
+	for _, curTestCase := range testCases {
+		expression, parseErr := Parse(curTestCase.expression)
+		if parseErr != nil {
+			t.Fatalf("Expecting '%s' to be parsed; got %v", curTestCase.expression, parseErr)
+		}
+
+		if values := RequiredValues(expression); !reflect.DeepEqual(values, curTestCase.expected) {
+			t.Errorf("Expecting the required values of '%s' to be %v; got %v", curTestCase.expression, curTestCase.expected, values)
+		}
+	}
+
+	if values := RequiredValues(nil); len(values) != 0 {
+		t.Errorf("Expecting no required values without an expression; got %v", values)
+	}
+}
@@ -0,0 +1,148 @@
+package filter
+
+// This file contains the logic for splitting filter expressions into tokens
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenOperator
+	tokenNot
+	tokenAnd
+	tokenOr
+	tokenOpenParen
+	tokenCloseParen
+)
+
+// token is a word (a field or a value, quoted or not) or a symbol in an expression
+type token struct {
+	kind     tokenKind
+	text     string
+	quoted   bool
+	position int
+}
+
+func (t *token) String() string {
+	if t.kind == tokenEOF {
+		return "the end of the expression"
+	}
+
+	return fmt.Sprintf("'%s' at position %d", t.text, t.position)
+}
+
+// comparisonOperators is ordered so that two-character operators are matched before their prefixes
+var comparisonOperators = []string{"==", "!=", "=~", "!~", "<=", ">=", "<", ">"}
+
+// wordDelimiters are the characters that end an unquoted word
+const wordDelimiters = " \t\r\n()!=<>~&|\"'"
+
+// tokenize splits the expression into tokens. The last token is always tokenEOF
+func tokenize(expression string) ([]*token, error) {
+	tokens := []*token{}
+	for i := 0; i < len(expression); {
+		position := i + 1
+		rest := expression[i:]
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(expression[i])):
+			i++
+			continue
+		case rest[0] == '(':
+			tokens = append(tokens, &token{kind: tokenOpenParen, text: "(", position: position})
+			i++
+			continue
+		case rest[0] == ')':
+			tokens = append(tokens, &token{kind: tokenCloseParen, text: ")", position: position})
+			i++
+			continue
+		case strings.HasPrefix(rest, "&&"):
+			tokens = append(tokens, &token{kind: tokenAnd, text: "&&", position: position})
+			i += 2
+			continue
+		case strings.HasPrefix(rest, "||"):
+			tokens = append(tokens, &token{kind: tokenOr, text: "||", position: position})
+			i += 2
+			continue
+		case rest[0] == '"' || rest[0] == '\'':
+			value, length, quoteErr := readQuoted(rest)
+			if quoteErr != nil {
+				return nil, fmt.Errorf("%s at position %d", quoteErr.Error(), position)
+			}
+			tokens = append(tokens, &token{kind: tokenWord, text: value, quoted: true, position: position})
+			i += length
+			continue
+		}
+
+		operator := ""
+		for _, curOperator := range comparisonOperators {
+			if strings.HasPrefix(rest, curOperator) {
+				operator = curOperator
+				break
+			}
+		}
+		if len(operator) > 0 {
+			tokens = append(tokens, &token{kind: tokenOperator, text: operator, position: position})
+			i += len(operator)
+			continue
+		}
+		if rest[0] == '!' {
+			tokens = append(tokens, &token{kind: tokenNot, text: "!", position: position})
+			i++
+			continue
+		}
+
+		length := strings.IndexAny(rest, wordDelimiters)
+		if length == 0 {
+			return nil, fmt.Errorf("Unexpected '%c' at position %d", rest[0], position)
+		}
+		if length < 0 {
+			length = len(rest)
+		}
+		tokens = append(tokens, newWordToken(rest[:length], position))
+		i += length
+	}
+
+	return append(tokens, &token{kind: tokenEOF, position: len(expression) + 1}), nil
+}
+
+// newWordToken returns the token for an unquoted word, which is either a keyword (and, or, not) or a
+// field or value
+func newWordToken(word string, position int) *token {
+	kind := tokenWord
+	switch strings.ToLower(word) {
+	case "and":
+		kind = tokenAnd
+	case "or":
+		kind = tokenOr
+	case "not":
+		kind = tokenNot
+	}
+
+	return &token{kind: kind, text: word, position: position}
+}
+
+// readQuoted reads the quoted string at the start of value and returns the unquoted string and the
+// length of the quoted string. Only the quote and backslash can be escaped so that regular expressions
+// don't need to be escaped twice
+func readQuoted(value string) (string, int, error) {
+	quote := value[0]
+	unquoted := new(strings.Builder)
+	for i := 1; i < len(value); i++ {
+		switch {
+		case value[i] == quote:
+			return unquoted.String(), i + 1, nil
+		case value[i] == '\\' && i+1 < len(value) && (value[i+1] == quote || value[i+1] == '\\'):
+			unquoted.WriteByte(value[i+1])
+			i++
+		default:
+			unquoted.WriteByte(value[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("Missing closing %c for the string", quote)
+}
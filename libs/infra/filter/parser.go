@@ -0,0 +1,235 @@
+package filter
+
+// This file contains the logic for parsing the tokens of a filter expression into a tree of nodes
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tagFieldPrefix string = "tag:"
+const propertyFieldPrefix string = "property:"
+
+// Fields that aren't tags or properties
+const (
+	fieldID         string = "id"
+	fieldProvider   string = "provider"
+	fieldType       string = "type"
+	fieldLocation   string = "location"
+	fieldLaunchTime string = "launch-time"
+	fieldAge        string = "age"
+)
+
+// Layouts accepted in launch-time comparisons
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// ageFormat is a number of days followed by a Go duration e.g. "30d", "1d12h" or "90m"
+var ageFormat = regexp.MustCompile(`^(?:(\d+)d)?(.*)$`)
+
+type parser struct {
+	tokens  []*token
+	current int
+}
+
+func (p *parser) peek() *token {
+	return p.tokens[p.current]
+}
+
+func (p *parser) next() *token {
+	curToken := p.tokens[p.current]
+	if curToken.kind != tokenEOF {
+		p.current++
+	}
+
+	return curToken
+}
+
+// parseOr parses an OR group: andExpression { ("||" | "or") andExpression }
+func (p *parser) parseOr() (node, error) {
+	left, leftErr := p.parseAnd()
+	if leftErr != nil {
+		return nil, leftErr
+	}
+
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, rightErr := p.parseAnd()
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses an AND group: unaryExpression { ("&&" | "and") unaryExpression }
+func (p *parser) parseAnd() (node, error) {
+	left, leftErr := p.parseUnary()
+	if leftErr != nil {
+		return nil, leftErr
+	}
+
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, rightErr := p.parseUnary()
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		left = &andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseUnary parses a negation, a group in parentheses, a presence check or a comparison
+func (p *parser) parseUnary() (node, error) {
+	curToken := p.next()
+	switch curToken.kind {
+	case tokenNot:
+		operand, operandErr := p.parseUnary()
+		if operandErr != nil {
+			return nil, operandErr
+		}
+
+		return &notNode{operand: operand}, nil
+	case tokenOpenParen:
+		group, groupErr := p.parseOr()
+		if groupErr != nil {
+			return nil, groupErr
+		}
+		if closing := p.next(); closing.kind != tokenCloseParen {
+			return nil, fmt.Errorf("Expecting ')' to close the '(' at position %d; got %s", curToken.position, closing.String())
+		}
+
+		return group, nil
+	case tokenWord:
+		fieldValue, fieldErr := parseField(curToken)
+		if fieldErr != nil {
+			return nil, fieldErr
+		}
+		if p.peek().kind != tokenOperator {
+			return &presenceNode{field: fieldValue}, nil
+		}
+
+		operator := p.next()
+		valueToken := p.next()
+		if !isValue(valueToken) {
+			return nil, fmt.Errorf("Expecting a value after %s; got %s", operator.String(), valueToken.String())
+		}
+
+		return newComparison(fieldValue, operator, valueToken)
+	}
+
+	return nil, fmt.Errorf("Expecting a field, '!' or '('; got %s", curToken.String())
+}
+
+// isValue checks whether the token can be used as a value. Keywords can be used as values
+func isValue(curToken *token) bool {
+	switch curToken.kind {
+	case tokenWord:
+		return true
+	case tokenAnd, tokenOr, tokenNot:
+		return curToken.text != "&&" && curToken.text != "||" && curToken.text != "!"
+	}
+
+	return false
+}
+
+// parseField parses a field name e.g. "tag:Owner", "property:state" or "launch-time"
+func parseField(curToken *token) (*field, error) {
+	name := curToken.text
+	lowerName := strings.ToLower(name)
+	for _, curPrefix := range []string{tagFieldPrefix, propertyFieldPrefix} {
+		if strings.HasPrefix(lowerName, curPrefix) {
+			key := name[len(curPrefix):]
+			if len(key) == 0 {
+				return nil, fmt.Errorf("Missing the key in the field %s", curToken.String())
+			}
+
+			return &field{name: curPrefix, key: key}, nil
+		}
+	}
+
+	switch lowerName {
+	case fieldID, fieldProvider, fieldType, fieldLocation, fieldLaunchTime, fieldAge:
+		return &field{name: lowerName}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown field %s. Fields are tag:<key>, property:<key>, %s, %s, %s, %s, %s and %s", curToken.String(), fieldID, fieldProvider, fieldType, fieldLocation, fieldLaunchTime, fieldAge)
+}
+
+// newComparison returns the node comparing the field to the value using the operator
+func newComparison(fieldValue *field, operator *token, valueToken *token) (node, error) {
+	comparison := &comparisonNode{field: fieldValue, operator: operator.text, value: valueToken.text}
+	switch fieldValue.name {
+	case fieldLaunchTime:
+		if !isOrderingOperator(operator.text) {
+			return nil, fmt.Errorf("Only <, <=, > and >= can be used with %s; got %s", fieldLaunchTime, operator.String())
+		}
+
+		for _, curLayout := range timeLayouts {
+			timeValue, timeErr := time.Parse(curLayout, valueToken.text)
+			if timeErr == nil {
+				comparison.timeValue = timeValue
+				return comparison, nil
+			}
+		}
+
+		return nil, fmt.Errorf("Expecting a date (e.g. 2020-05-01) or an RFC 3339 time after %s; got %s", operator.String(), valueToken.String())
+	case fieldAge:
+		if !isOrderingOperator(operator.text) {
+			return nil, fmt.Errorf("Only <, <=, > and >= can be used with %s; got %s", fieldAge, operator.String())
+		}
+
+		age, ageErr := parseAge(valueToken.text)
+		if ageErr != nil {
+			return nil, fmt.Errorf("Expecting a duration (e.g. 30d or 12h) after %s; got %s", operator.String(), valueToken.String())
+		}
+		comparison.durationValue = age
+
+		return comparison, nil
+	}
+
+	if operator.text == "=~" || operator.text == "!~" {
+		pattern, patternErr := regexp.Compile(valueToken.text)
+		if patternErr != nil {
+			return nil, fmt.Errorf("Invalid regular expression %s: %s", valueToken.String(), patternErr.Error())
+		}
+		comparison.pattern = pattern
+	}
+
+	return comparison, nil
+}
+
+func isOrderingOperator(operator string) bool {
+	return operator == "<" || operator == "<=" || operator == ">" || operator == ">="
+}
+
+// parseAge parses a duration that can start with a number of days e.g. "30d" or "1d12h"
+func parseAge(value string) (time.Duration, error) {
+	matches := ageFormat.FindStringSubmatch(value)
+	var age time.Duration
+	if len(matches[1]) > 0 {
+		days, daysErr := strconv.Atoi(matches[1])
+		if daysErr != nil {
+			return 0, daysErr
+		}
+		age = time.Duration(days) * 24 * time.Hour
+	}
+
+	if len(matches[2]) > 0 {
+		duration, durationErr := time.ParseDuration(matches[2])
+		if durationErr != nil {
+			return 0, durationErr
+		}
+		age += duration
+	} else if len(matches[1]) == 0 {
+		return 0, fmt.Errorf("Empty duration")
+	}
+
+	return age, nil
+}
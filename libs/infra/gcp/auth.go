@@ -0,0 +1,135 @@
+package gcp
+
+// This file contains the logic for getting OAuth 2.0 access tokens for the Google Cloud APIs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+)
+
+const accessTokenEnvVar string = "SRE_INFRA_GCP_ACCESS_TOKEN"
+const credentialsEnvVar string = "GOOGLE_APPLICATION_CREDENTIALS"
+const cloudPlatformScope string = "https://www.googleapis.com/auth/cloud-platform"
+const defaultTokenURI string = "https://oauth2.googleapis.com/token"
+const jwtBearerGrantType string = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+const tokenLifetime = time.Hour
+
+// serviceAccount signs the assertions exchanged for access tokens using a service account's key
+type serviceAccount struct {
+	key        *serviceAccountKey
+	privateKey *rsa.PrivateKey
+}
+
+// serviceAccountKey holds the fields needed from a service account's JSON key file
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// newTokenSource creates a token source from the provided access token or, if it is empty, the service
+// account key at keyPath
+func newTokenSource(staticToken string, keyPath string) (*rest.TokenSource, error) {
+	if len(staticToken) > 0 {
+		return rest.NewStaticTokenSource(staticToken), nil
+	}
+
+	if len(keyPath) == 0 {
+		return nil, fmt.Errorf("Either %s or %s need to be set to authenticate against GCP", accessTokenEnvVar, credentialsEnvVar)
+	}
+
+	keyJSON, readErr := ioutil.ReadFile(keyPath)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	key := new(serviceAccountKey)
+	unmarshalErr := json.Unmarshal(keyJSON, key)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("Could not parse the service account key in '%s': %v", keyPath, unmarshalErr)
+	}
+	if len(key.TokenURI) == 0 {
+		key.TokenURI = defaultTokenURI
+	}
+
+	privateKey, privateKeyErr := parsePrivateKey(key.PrivateKey)
+	if privateKeyErr != nil {
+		return nil, fmt.Errorf("Could not parse the private key in '%s': %v", keyPath, privateKeyErr)
+	}
+
+	account := &serviceAccount{key: key, privateKey: privateKey}
+	return rest.NewTokenSource(key.TokenURI, account.getTokenForm), nil
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key in either the PKCS #8 or the PKCS #1 format
+func parsePrivateKey(privateKeyPEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("Private key is not PEM encoded")
+	}
+
+	parsedKey, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if pkcs8Err != nil {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	rsaKey, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// getTokenForm returns the form posted to the token URI to exchange a new assertion for an access token
+func (sa *serviceAccount) getTokenForm() (url.Values, error) {
+	assertion, assertionErr := sa.getAssertion(time.Now())
+	if assertionErr != nil {
+		return nil, assertionErr
+	}
+
+	return url.Values{
+		"grant_type": {jwtBearerGrantType},
+		"assertion":  {assertion},
+	}, nil
+}
+
+// getAssertion returns the signed JWT exchanged for an access token
+func (sa *serviceAccount) getAssertion(now time.Time) (string, error) {
+	header, headerErr := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if headerErr != nil {
+		return "", headerErr
+	}
+
+	claims, claimsErr := json.Marshal(map[string]interface{}{
+		"iss":   sa.key.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   sa.key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	})
+	if claimsErr != nil {
+		return "", claimsErr
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, signErr := rsa.SignPKCS1v15(rand.Reader, sa.privateKey, crypto.SHA256, hash[:])
+	if signErr != nil {
+		return "", signErr
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,265 @@
+package gcp
+
+// This file contains the logic for getting costs from the Cloud Billing data exported to BigQuery
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const queryTimeoutMs = 60000
+const totalGroupKey string = "Total"
+
+// Group types, as used by the AWS Cost Explorer, that can be passed in CostAndUsageFilter.GroupBy
+const groupTypeDimension string = "DIMENSION"
+const groupTypeTag string = "TAG"
+
+// billingDimensionColumns maps the dimensions costs can be grouped by to the billing export's columns
+var billingDimensionColumns = map[string]string{
+	"SERVICE": "service.description",
+	"REGION":  "location.region",
+	"PROJECT": "project.id",
+	"SKU":     "sku.description",
+}
+
+var billingTableRegexp = regexp.MustCompile(`^[A-Za-z0-9_:.-]+$`)
+
+type queryRequest struct {
+	Query           string            `json:"query"`
+	UseLegacySQL    bool              `json:"useLegacySql"`
+	ParameterMode   string            `json:"parameterMode"`
+	QueryParameters []*queryParameter `json:"queryParameters"`
+	TimeoutMs       int               `json:"timeoutMs"`
+}
+
+type queryParameter struct {
+	Name           string               `json:"name"`
+	ParameterType  *queryParameterType  `json:"parameterType"`
+	ParameterValue *queryParameterValue `json:"parameterValue"`
+}
+
+type queryParameterType struct {
+	Type      string              `json:"type"`
+	ArrayType *queryParameterType `json:"arrayType,omitempty"`
+}
+
+type queryParameterValue struct {
+	Value       string                 `json:"value,omitempty"`
+	ArrayValues []*queryParameterValue `json:"arrayValues,omitempty"`
+}
+
+type queryResponse struct {
+	JobComplete  bool          `json:"jobComplete"`
+	JobReference *jobReference `json:"jobReference"`
+	PageToken    string        `json:"pageToken"`
+	Rows         []*tableRow   `json:"rows"`
+}
+
+type jobReference struct {
+	ProjectID string `json:"projectId"`
+	JobID     string `json:"jobId"`
+	Location  string `json:"location"`
+}
+
+type tableRow struct {
+	F []*tableCell `json:"f"`
+}
+
+type tableCell struct {
+	V interface{} `json:"v"`
+}
+
+func newStringParameter(name string, value string) *queryParameter {
+	return &queryParameter{
+		Name:           name,
+		ParameterType:  &queryParameterType{Type: "STRING"},
+		ParameterValue: &queryParameterValue{Value: value},
+	}
+}
+
+func newStringArrayParameter(name string, values []string) *queryParameter {
+	arrayValues := []*queryParameterValue{}
+	for _, curValue := range values {
+		arrayValues = append(arrayValues, &queryParameterValue{Value: curValue})
+	}
+
+	return &queryParameter{
+		Name:           name,
+		ParameterType:  &queryParameterType{Type: "ARRAY", ArrayType: &queryParameterType{Type: "STRING"}},
+		ParameterValue: &queryParameterValue{ArrayValues: arrayValues},
+	}
+}
+
+// GetCostsAndUsages sums the costs in the billing export table, grouped as requested in the filter
+func (g *GCP) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	if len(g.billingTable) == 0 {
+		return nil, fmt.Errorf("%s needs to be set to get GCP costs", billingTableEnvVar)
+	}
+
+	query, parameters, queryErr := constructBillingQuery(g.billingTable, filter)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	// Run the query in the project the billing table is in
+	project := strings.SplitN(strings.Replace(g.billingTable, ":", ".", 1), ".", 2)[0]
+	rows, rowsErr := g.runQuery(project, query, parameters)
+	if rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	groupAmounts := make(map[string]float64)
+	for _, curRow := range rows {
+		if len(curRow.F) != 2 {
+			return nil, fmt.Errorf("Expecting 2 columns in the GCP billing query results; got %d", len(curRow.F))
+		}
+
+		key, _ := curRow.F[0].V.(string)
+		amountString, _ := curRow.F[1].V.(string)
+		amount, amountErr := strconv.ParseFloat(amountString, 64)
+		if amountErr != nil {
+			continue
+		}
+		groupAmounts[key] += amount
+	}
+
+	return &types.CostAndUsageOutput{
+		Provider: gcpProviderName,
+		Groups:   groupAmounts,
+		Period: &types.CostAndUsagePeriod{
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+		},
+	}, nil
+}
+
+// runQuery runs the query in BigQuery and returns all the rows in the results
+func (g *GCP) runQuery(project string, query string, parameters []*queryParameter) ([]*tableRow, error) {
+	response := new(queryResponse)
+	queryErr := g.bigQuery.Post(fmt.Sprintf("projects/%s/queries", url.PathEscape(project)), nil, &queryRequest{
+		Query:           query,
+		UseLegacySQL:    false,
+		ParameterMode:   "NAMED",
+		QueryParameters: parameters,
+		TimeoutMs:       queryTimeoutMs,
+	}, response)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	rows := response.Rows
+	for !response.JobComplete || len(response.PageToken) > 0 {
+		if response.JobReference == nil {
+			return nil, fmt.Errorf("BigQuery didn't return a reference to the billing query's job")
+		}
+
+		query := url.Values{}
+		query.Set("timeoutMs", strconv.Itoa(queryTimeoutMs))
+		if len(response.JobReference.Location) > 0 {
+			query.Set("location", response.JobReference.Location)
+		}
+		if len(response.PageToken) > 0 {
+			query.Set("pageToken", response.PageToken)
+		}
+
+		jobReference := response.JobReference
+		response = new(queryResponse)
+		resultsErr := g.bigQuery.Get(fmt.Sprintf("projects/%s/queries/%s", url.PathEscape(jobReference.ProjectID), url.PathEscape(jobReference.JobID)), query, response)
+		if resultsErr != nil {
+			return nil, resultsErr
+		}
+		if response.JobReference == nil {
+			response.JobReference = jobReference
+		}
+		if response.JobComplete {
+			rows = append(rows, response.Rows...)
+		}
+	}
+
+	return rows, nil
+}
+
+// constructBillingQuery returns the standard SQL query, and its parameters, that sums the costs in the
+// billing export table matching the filter
+func constructBillingQuery(billingTable string, filter *types.CostAndUsageFilter) (string, []*queryParameter, error) {
+	if !billingTableRegexp.MatchString(billingTable) {
+		return "", nil, fmt.Errorf("'%s' is not a valid BigQuery table name", billingTable)
+	}
+
+	parameters := []*queryParameter{
+		newStringParameter("start_date", filter.StartDate),
+		newStringParameter("end_date", filter.EndDate),
+	}
+	conditions := []string{
+		"usage_start_time >= TIMESTAMP(@start_date)",
+		"usage_start_time < TIMESTAMP(@end_date)",
+	}
+
+	if len(filter.ResourceTypes) > 0 {
+		parameters = append(parameters, newStringArrayParameter("services", filter.ResourceTypes))
+		conditions = append(conditions, "service.description IN UNNEST(@services)")
+	}
+	if len(filter.Regions) > 0 {
+		parameters = append(parameters, newStringArrayParameter("regions", filter.Regions))
+		conditions = append(conditions, "location.region IN UNNEST(@regions)")
+	}
+	if len(filter.Accounts) > 0 {
+		parameters = append(parameters, newStringArrayParameter("projects", filter.Accounts))
+		conditions = append(conditions, "project.id IN UNNEST(@projects)")
+	}
+
+	tagKeys := []string{}
+	for curKey := range filter.Tags {
+		tagKeys = append(tagKeys, curKey)
+	}
+	sort.Strings(tagKeys)
+	for i, curKey := range tagKeys {
+		keyName := fmt.Sprintf("tag_key_%d", i)
+		valueName := fmt.Sprintf("tag_value_%d", i)
+		parameters = append(parameters, newStringParameter(keyName, curKey), newStringParameter(valueName, filter.Tags[curKey]))
+		conditions = append(conditions, fmt.Sprintf("EXISTS(SELECT 1 FROM UNNEST(labels) AS l WHERE l.key = @%s AND l.value = @%s)", keyName, valueName))
+	}
+
+	groupTypes := []string{}
+	for curType := range filter.GroupBy {
+		groupTypes = append(groupTypes, curType)
+	}
+	sort.Strings(groupTypes)
+	groupExpressions := []string{}
+	for i, curType := range groupTypes {
+		curKey := filter.GroupBy[curType]
+		switch strings.ToUpper(curType) {
+		case groupTypeDimension:
+			column, columnOk := billingDimensionColumns[strings.ToUpper(curKey)]
+			if !columnOk {
+				return "", nil, fmt.Errorf("Cannot group GCP costs by the dimension '%s'", curKey)
+			}
+			groupExpressions = append(groupExpressions, fmt.Sprintf("IFNULL(%s, '')", column))
+		case groupTypeTag:
+			keyName := fmt.Sprintf("group_tag_%d", i)
+			parameters = append(parameters, newStringParameter(keyName, curKey))
+			groupExpressions = append(groupExpressions, fmt.Sprintf("IFNULL((SELECT value FROM UNNEST(labels) WHERE key = @%s LIMIT 1), '')", keyName))
+		default:
+			return "", nil, fmt.Errorf("Cannot group GCP costs by '%s'", curType)
+		}
+	}
+
+	groupExpression := fmt.Sprintf("'%s'", totalGroupKey)
+	if len(groupExpressions) > 0 {
+		groupExpression = fmt.Sprintf("CONCAT(%s)", strings.Join(groupExpressions, ", ', ', "))
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s AS group_key, CAST(SUM(cost) AS STRING) AS amount FROM `%s` WHERE %s GROUP BY group_key",
+		groupExpression,
+		billingTable,
+		strings.Join(conditions, " AND "))
+
+	return query, parameters, nil
+}
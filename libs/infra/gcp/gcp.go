@@ -0,0 +1,378 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const gcpProviderName string = "GCP"
+const resourceTypeGce string = "GCE"
+const projectsEnvVar string = "SRE_INFRA_GCP_PROJECTS"
+const billingTableEnvVar string = "SRE_INFRA_GCP_BILLING_TABLE"
+const projectsSeparator string = ","
+const computeURL string = "https://compute.googleapis.com/compute/v1"
+const bigQueryURL string = "https://bigquery.googleapis.com/bigquery/v2"
+
+// Compute Engine instance statuses. An instance that is stopped has the TERMINATED status
+const gceStatusRunning string = "RUNNING"
+const gceStatusStopping string = "STOPPING"
+const gceStatusTerminated string = "TERMINATED"
+
+const propertyProject string = "project"
+const propertyState string = "state"
+
+// propertyAccountID is set to the instance's project so that instances can be filtered by project using -filter-account
+const propertyAccountID string = "account-id"
+
+// GCP fetches Compute Engine instances and billing data from the Google Cloud REST APIs
+type GCP struct {
+	config       *config.Provider
+	projects     []string
+	billingTable string
+	compute      *rest.Client
+	bigQuery     *rest.Client
+}
+
+type instanceAggregatedList struct {
+	Items         map[string]instancesScopedList `json:"items"`
+	NextPageToken string                         `json:"nextPageToken"`
+}
+
+type instancesScopedList struct {
+	Instances []*instance `json:"instances"`
+}
+
+type instance struct {
+	ID                string             `json:"id"`
+	Name              string             `json:"name"`
+	Zone              string             `json:"zone"`
+	MachineType       string             `json:"machineType"`
+	Status            string             `json:"status"`
+	CreationTimestamp string             `json:"creationTimestamp"`
+	CPUPlatform       string             `json:"cpuPlatform"`
+	Labels            map[string]string  `json:"labels"`
+	LabelFingerprint  string             `json:"labelFingerprint"`
+	NetworkInterfaces []networkInterface `json:"networkInterfaces"`
+}
+
+type networkInterface struct {
+	NetworkIP     string         `json:"networkIP"`
+	AccessConfigs []accessConfig `json:"accessConfigs"`
+}
+
+type accessConfig struct {
+	NatIP string `json:"natIP"`
+}
+
+type setLabelsRequest struct {
+	Labels           map[string]string `json:"labels"`
+	LabelFingerprint string            `json:"labelFingerprint"`
+}
+
+// IsConfigured checks whether the GCP projects to fetch resources from have been set
+func IsConfigured() bool {
+	return len(parseProjects(os.Getenv(projectsEnvVar))) > 0
+}
+
+// New returns a GCP provider that uses the provided configuration instead of the environment
+func New(providerConfig *config.Provider) *GCP {
+	return &GCP{config: providerConfig}
+}
+
+func (g *GCP) GetName() string {
+	return gcpProviderName
+}
+
+// Init initializes the provider using the projects, billing table and credentials in the provider's
+// configuration or, if not configured, the environment
+func (g *GCP) Init() error {
+	projects := g.config.GetAccounts(projectsEnvVar)
+	if len(projects) == 0 {
+		return fmt.Errorf("The GCP projects need to be set in %s or the providers configuration file to use the GCP provider", projectsEnvVar)
+	}
+
+	tokenSource, tokenErr := newTokenSource(
+		g.config.GetSetting("access_token", accessTokenEnvVar),
+		g.config.GetSetting("credentials_file", credentialsEnvVar))
+	if tokenErr != nil {
+		return tokenErr
+	}
+
+	authorize := rest.BearerToken(tokenSource.GetToken)
+	g.init(projects, g.config.GetSetting("billing_table", billingTableEnvVar), rest.NewClient(computeURL, authorize), rest.NewClient(bigQueryURL, authorize))
+
+	return nil
+}
+
+func (g *GCP) init(projects []string, billingTable string, compute *rest.Client, bigQuery *rest.Client) {
+	g.projects = projects
+	g.billingTable = billingTable
+	g.compute = compute
+	g.bigQuery = bigQuery
+}
+
+func parseProjects(projectsValue string) []string {
+	projects := []string{}
+	for _, curProject := range strings.Split(projectsValue, projectsSeparator) {
+		curProject = strings.TrimSpace(curProject)
+		if len(curProject) > 0 {
+			projects = append(projects, curProject)
+		}
+	}
+
+	return projects
+}
+
+func (g *GCP) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	allResources := []*types.InfraResource{}
+	if !filter.ConsiderResourceType(resourceTypeGce) {
+		return allResources, nil
+	}
+
+	// Keep fetching the instances in the other projects if fetching the instances in a project fails
+	var failures types.InfraFailures
+	for _, curProject := range g.projects {
+		if len(filter.Accounts) > 0 && !containsProject(filter.Accounts, curProject) {
+			continue
+		}
+
+		resources, resourcesErr := g.getInstancesInProject(ctx, curProject, filter)
+		allResources = append(allResources, resources...)
+		if resourcesErr != nil {
+			failures = append(failures, types.ToInfraFailures(resourcesErr, gcpProviderName, resourceTypeGce, curProject)...)
+		}
+	}
+
+	if len(failures) > 0 {
+		return allResources, failures
+	}
+
+	return allResources, nil
+}
+
+func containsProject(projects []string, project string) bool {
+	for _, curProject := range projects {
+		if curProject == project {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getInstancesInProject returns the Compute Engine instances, in all zones, in the project that match the filter.
+// If fetching a page fails, the instances in the previous pages are returned with the error
+func (g *GCP) getInstancesInProject(ctx context.Context, project string, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	resources := []*types.InfraResource{}
+
+	pageCount := 0
+	pageToken := ""
+	for {
+		query := url.Values{}
+		if len(pageToken) > 0 {
+			query.Set("pageToken", pageToken)
+		}
+
+		page := new(instanceAggregatedList)
+		pageErr := g.compute.GetWithContext(ctx, fmt.Sprintf("projects/%s/aggregated/instances", url.PathEscape(project)), query, page)
+		if pageErr != nil {
+			return resources, pageErr
+		}
+		pageCount++
+
+		for _, curScope := range page.Items {
+			for _, curInstance := range curScope.Instances {
+				zone := lastPathSegment(curInstance.Zone)
+				if !filter.ConsiderRegion(zone) && !filter.ConsiderRegion(getZoneRegion(zone)) {
+					continue
+				}
+				if !filter.ConsiderTags(curInstance.Labels) {
+					continue
+				}
+
+				resources = append(resources, getInstanceResource(project, curInstance))
+			}
+		}
+
+		pageToken = page.NextPageToken
+		if len(pageToken) == 0 {
+			break
+		}
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d GCE instances from %d pages in %s", len(resources), pageCount, project))
+
+	return resources, nil
+}
+
+// getState returns the state of an instance with the provided status. TERMINATED is mapped to stopped, like
+// the stopped instances of the other providers, since the instance can still be started
+func getState(status string) string {
+	if status == gceStatusTerminated {
+		return types.ResourceStateStopped
+	}
+
+	return strings.ToLower(status)
+}
+
+// getInstanceResource converts the provided Compute Engine instance into an InfraResource
+func getInstanceResource(project string, instance *instance) *types.InfraResource {
+	zone := lastPathSegment(instance.Zone)
+	properties := map[string]string{
+		"id":                instance.ID,
+		"name":              instance.Name,
+		propertyProject:     project,
+		propertyAccountID:   project,
+		"zone":              zone,
+		"region":            getZoneRegion(zone),
+		"machine-type":      lastPathSegment(instance.MachineType),
+		propertyState:       getState(instance.Status),
+		"cpu-platform":      instance.CPUPlatform,
+		"launch-time":       instance.CreationTimestamp,
+		"label-fingerprint": instance.LabelFingerprint,
+	}
+	for _, curInterface := range instance.NetworkInterfaces {
+		properties["private-ip"] = curInterface.NetworkIP
+		for _, curAccessConfig := range curInterface.AccessConfigs {
+			if len(curAccessConfig.NatIP) > 0 {
+				properties["public-ip"] = curAccessConfig.NatIP
+				break
+			}
+		}
+		break
+	}
+
+	tags := make(map[string]string)
+	for curKey, curValue := range instance.Labels {
+		tags[curKey] = curValue
+	}
+
+	resource := &types.InfraResource{
+		Provider:     gcpProviderName,
+		ID:           instance.Name,
+		Location:     zone,
+		ResourceType: resourceTypeGce,
+		Tags:         tags,
+		Properties:   properties,
+	}
+	if launchTime, launchTimeErr := time.Parse(time.RFC3339, instance.CreationTimestamp); launchTimeErr == nil {
+		resource.LaunchTime = launchTime
+	}
+
+	return resource
+}
+
+// lastPathSegment returns the last segment of a resource URL e.g. the zone's name in the instance's zone URL
+func lastPathSegment(resourceURL string) string {
+	return resourceURL[strings.LastIndex(resourceURL, "/")+1:]
+}
+
+// getZoneRegion returns the region a zone is in e.g. "us-central1" for "us-central1-a"
+func getZoneRegion(zone string) string {
+	separatorIndex := strings.LastIndex(zone, "-")
+	if separatorIndex < 0 {
+		return zone
+	}
+
+	return zone[:separatorIndex]
+}
+
+// getInstancePath returns the path to the resource's Compute Engine instance
+func getInstancePath(resource *types.InfraResource) (string, error) {
+	if resource.Provider != gcpProviderName {
+		return "", fmt.Errorf("Resource's provider is %s instead of %s", resource.Provider, gcpProviderName)
+	}
+	if resource.ResourceType != resourceTypeGce {
+		return "", fmt.Errorf("Resources of type '%s' are not supported by the %s provider", resource.ResourceType, gcpProviderName)
+	}
+	if len(resource.ID) == 0 || len(resource.Properties[propertyProject]) == 0 {
+		return "", fmt.Errorf("The name or the project of the GCE instance is not set")
+	}
+
+	return fmt.Sprintf(
+		"projects/%s/zones/%s/instances/%s",
+		url.PathEscape(resource.Properties[propertyProject]),
+		url.PathEscape(resource.Location),
+		url.PathEscape(resource.ID)), nil
+}
+
+// UpdateResourceTag sets the value of the instance's label, or removes the label if tagValue is nil. GCE
+// labels are replaced as a whole so the instance's current labels and label fingerprint are fetched first
+func (g *GCP) UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	instancePath, pathErr := getInstancePath(resource)
+	if pathErr != nil {
+		return pathErr
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the GCE instance label because the label key is not set")
+	}
+
+	curInstance := new(instance)
+	getErr := g.compute.Get(instancePath, nil, curInstance)
+	if getErr != nil {
+		return getErr
+	}
+
+	labels := make(map[string]string)
+	for curKey, curValue := range curInstance.Labels {
+		labels[curKey] = curValue
+	}
+	if tagValue == nil {
+		delete(labels, *tagKey)
+	} else {
+		labels[*tagKey] = *tagValue
+	}
+
+	return g.compute.Post(instancePath+"/setLabels", nil, &setLabelsRequest{
+		Labels:           labels,
+		LabelFingerprint: curInstance.LabelFingerprint,
+	}, nil)
+}
+
+// UpdateResourceState stops, starts or deletes the Compute Engine instance. In safe mode, only
+// stopped instances are deleted
+func (g *GCP) UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	instancePath, pathErr := getInstancePath(resource)
+	if pathErr != nil {
+		return pathErr
+	}
+
+	// Fetch the instance again since its status might have changed since the instances were listed
+	curInstance := new(instance)
+	getErr := g.compute.Get(instancePath, nil, curInstance)
+	if getErr != nil {
+		return getErr
+	}
+
+	switch state {
+	case types.ResourceStateStopped:
+		if curInstance.Status == gceStatusTerminated || curInstance.Status == gceStatusStopping {
+			return nil
+		}
+
+		return g.compute.Post(instancePath+"/stop", nil, nil, nil)
+	case types.ResourceStateRunning:
+		if curInstance.Status == gceStatusRunning {
+			return nil
+		}
+
+		return g.compute.Post(instancePath+"/start", nil, nil, nil)
+	case types.ResourceStateTerminated:
+		if safe && curInstance.Status != gceStatusTerminated {
+			return fmt.Errorf("Refusing to delete the GCE instance '%s' because it is '%s' instead of stopped", resource.ID, getState(curInstance.Status))
+		}
+
+		return g.compute.Do("DELETE", instancePath, nil, nil, nil)
+	}
+
+	return fmt.Errorf("Cannot update the state of a GCE instance to '%s'", state)
+}
@@ -0,0 +1,262 @@
+package gcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/infra/rest/resttest"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const testProject = "test-project"
+
+// fakeAPI is a local fake of the parts of the Compute Engine and BigQuery REST APIs used by the provider
+type fakeAPI struct {
+	server        *httptest.Server
+	labels        map[string]string
+	setLabelsBody *setLabelsRequest
+	queryBody     *queryRequest
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{labels: map[string]string{"owner": "alice", "env": "dev"}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/compute/projects/"+testProject+"/aggregated/instances", func(w http.ResponseWriter, r *http.Request) {
+		if !resttest.IsAuthorized(w, r) {
+			return
+		}
+
+		// Return the instances across two pages to test pagination
+		if r.URL.Query().Get("pageToken") == "" {
+			resttest.WriteJSON(t, w, map[string]interface{}{
+				"items": map[string]interface{}{
+					"zones/us-central1-a": map[string]interface{}{
+						"instances": []interface{}{api.getInstance("instance-1", "us-central1-a", "RUNNING")},
+					},
+					"zones/europe-west1-b": map[string]interface{}{},
+				},
+				"nextPageToken": "page-2",
+			})
+			return
+		}
+
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"items": map[string]interface{}{
+				"zones/europe-west1-b": map[string]interface{}{
+					"instances": []interface{}{api.getInstance("instance-2", "europe-west1-b", "TERMINATED")},
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/compute/projects/"+testProject+"/zones/us-central1-a/instances/instance-1", func(w http.ResponseWriter, r *http.Request) {
+		resttest.WriteJSON(t, w, api.getInstance("instance-1", "us-central1-a", "RUNNING"))
+	})
+	mux.HandleFunc("/compute/projects/"+testProject+"/zones/us-central1-a/instances/instance-1/setLabels", func(w http.ResponseWriter, r *http.Request) {
+		api.setLabelsBody = new(setLabelsRequest)
+		if !resttest.ReadJSON(t, w, r, api.setLabelsBody) {
+			return
+		}
+		resttest.WriteJSON(t, w, map[string]string{"status": "DONE"})
+	})
+	mux.HandleFunc("/bigquery/projects/billing-project/queries", func(w http.ResponseWriter, r *http.Request) {
+		api.queryBody = new(queryRequest)
+		if !resttest.ReadJSON(t, w, r, api.queryBody) {
+			return
+		}
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"jobComplete":  true,
+			"jobReference": map[string]string{"projectId": "billing-project", "jobId": "job-1"},
+			"rows": []interface{}{
+				map[string]interface{}{"f": []interface{}{map[string]string{"v": "Compute Engine"}, map[string]string{"v": "12.5"}}},
+				map[string]interface{}{"f": []interface{}{map[string]string{"v": "Cloud Storage"}, map[string]string{"v": "3.25"}}},
+			},
+		})
+	})
+	api.server = httptest.NewServer(mux)
+
+	return api
+}
+
+func (api *fakeAPI) getInstance(name string, zone string, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                "1234",
+		"name":              name,
+		"zone":              "https://www.googleapis.com/compute/v1/projects/" + testProject + "/zones/" + zone,
+		"machineType":       "https://www.googleapis.com/compute/v1/projects/" + testProject + "/zones/" + zone + "/machineTypes/e2-small",
+		"status":            status,
+		"creationTimestamp": "2020-05-01T10:00:00.000-07:00",
+		"labels":            api.labels,
+		"labelFingerprint":  "fingerprint-1",
+		"networkInterfaces": []interface{}{
+			map[string]interface{}{
+				"networkIP":     "10.0.0.2",
+				"accessConfigs": []interface{}{map[string]string{"natIP": "35.1.2.3"}},
+			},
+		},
+	}
+}
+
+func (api *fakeAPI) getProvider() *GCP {
+	authorize := resttest.Authorize
+	provider := new(GCP)
+	provider.init(
+		[]string{testProject},
+		"billing-project.billing_dataset.gcp_billing_export_v1",
+		rest.NewClient(api.server.URL+"/compute", authorize),
+		rest.NewClient(api.server.URL+"/bigquery", authorize))
+
+	return provider
+}
+
+// Test whether GetResources follows all the pages of instances and applies the filter
+func TestGetResources(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	t.Run("all", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+		if resourcesErr != nil {
+			t.Fatalf("Expecting error to be nil; got %v", resourcesErr)
+		}
+		if len(resources) != 2 {
+			t.Fatalf("Expecting 2 instances from the 2 pages; got %d", len(resources))
+		}
+		if resources[1].ID != "instance-2" || resources[1].Properties[propertyState] != types.ResourceStateStopped {
+			t.Errorf("Expecting the TERMINATED instance to be stopped; got %v", resources[1].Properties)
+		}
+	})
+
+	t.Run("region", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"us-central1"}})
+		if resourcesErr != nil {
+			t.Fatalf("Expecting error to be nil; got %v", resourcesErr)
+		}
+		if len(resources) != 1 {
+			t.Fatalf("Expecting 1 instance in us-central1; got %d", len(resources))
+		}
+
+		resource := resources[0]
+		if resource.ID != "instance-1" || resource.Location != "us-central1-a" || resource.ResourceType != resourceTypeGce {
+			t.Errorf("Unexpected resource %+v", resource)
+		}
+		if resource.Tags["owner"] != "alice" {
+			t.Errorf("Expecting labels to be mapped to tags; got %v", resource.Tags)
+		}
+		if resource.Properties["machine-type"] != "e2-small" || resource.Properties["public-ip"] != "35.1.2.3" || resource.Properties[propertyState] != "running" {
+			t.Errorf("Unexpected resource properties %v", resource.Properties)
+		}
+		if resource.LaunchTime.IsZero() {
+			t.Errorf("Expecting the launch time to be set")
+		}
+	})
+
+	t.Run("other-type", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{ResourceTypes: []string{"EC2"}})
+		if resourcesErr != nil || len(resources) != 0 {
+			t.Errorf("Expecting no resources and no error; got %d resources and error %v", len(resources), resourcesErr)
+		}
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Tags: map[string]string{"owner": "bob"}})
+		if resourcesErr != nil || len(resources) != 0 {
+			t.Errorf("Expecting no resources and no error; got %d resources and error %v", len(resources), resourcesErr)
+		}
+	})
+}
+
+// Test whether the instances in the other projects are returned with the failure of a project
+func TestGetResourcesWithFailedProject(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+	provider.projects = []string{"missing-project", testProject}
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+	failures, partial := resourcesErr.(types.InfraFailures)
+	if !partial || len(failures) != 1 || failures[0].Region != "missing-project" || failures[0].ResourceType != resourceTypeGce {
+		t.Fatalf("Expecting the failure of the missing project; got %v", resourcesErr)
+	}
+	if len(resources) != 2 {
+		t.Errorf("Expecting the 2 instances in the other project; got %d", len(resources))
+	}
+}
+
+// Test whether UpdateResourceTag keeps the instance's other labels and sends the label fingerprint
+func TestUpdateResourceTag(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"us-central1-a"}})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 resource; got %d resources and error %v", len(resources), resourcesErr)
+	}
+
+	tagKey := "env"
+	tagValue := "prod"
+	updateErr := provider.UpdateResourceTag(resources[0], &tagKey, &tagValue)
+	if updateErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", updateErr)
+	}
+
+	if api.setLabelsBody == nil {
+		t.Fatalf("Expecting setLabels to be called")
+	}
+	if api.setLabelsBody.LabelFingerprint != "fingerprint-1" {
+		t.Errorf("Expecting the label fingerprint to be sent; got '%s'", api.setLabelsBody.LabelFingerprint)
+	}
+	if api.setLabelsBody.Labels["env"] != "prod" || api.setLabelsBody.Labels["owner"] != "alice" {
+		t.Errorf("Expecting the updated label and the other labels to be sent; got %v", api.setLabelsBody.Labels)
+	}
+}
+
+// Test whether GetCostsAndUsages queries the billing export and sums the costs per group
+func TestGetCostsAndUsages(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	costs, costsErr := provider.GetCostsAndUsages(&types.CostAndUsageFilter{
+		StartDate: "2020-05-01",
+		EndDate:   "2020-06-01",
+		Regions:   []string{"us-central1"},
+		Tags:      map[string]string{"env": "dev"},
+		GroupBy:   map[string]string{"DIMENSION": "SERVICE"},
+	})
+	if costsErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", costsErr)
+	}
+
+	if costs.Groups["Compute Engine"] != 12.5 || costs.Groups["Cloud Storage"] != 3.25 {
+		t.Errorf("Unexpected cost groups %v", costs.Groups)
+	}
+
+	if api.queryBody == nil {
+		t.Fatalf("Expecting a query to be sent to BigQuery")
+	}
+	if !strings.Contains(api.queryBody.Query, "service.description") || !strings.Contains(api.queryBody.Query, "UNNEST(@regions)") {
+		t.Errorf("Query doesn't group by service or filter by region: %s", api.queryBody.Query)
+	}
+	if api.queryBody.UseLegacySQL {
+		t.Errorf("Expecting standard SQL to be used")
+	}
+}
+
+// Test whether constructBillingQuery rejects invalid table names and group types
+func TestConstructBillingQueryErrors(t *testing.T) {
+	_, _, tableErr := constructBillingQuery("project.dataset.table` WHERE 1=1 --", &types.CostAndUsageFilter{})
+	if tableErr == nil {
+		t.Errorf("Expecting an error for an invalid table name")
+	}
+
+	_, _, groupErr := constructBillingQuery("project.dataset.table", &types.CostAndUsageFilter{GroupBy: map[string]string{"DIMENSION": "UNKNOWN"}})
+	if groupErr == nil {
+		t.Errorf("Expecting an error for an unsupported dimension")
+	}
+}
@@ -0,0 +1,311 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const hetznerProviderName string = "Hetzner"
+const resourceTypeServer string = "Server"
+const tokenEnvVar string = "SRE_INFRA_HETZNER_TOKEN"
+const apiURL string = "https://api.hetzner.cloud/v1"
+const pageSize = 50
+
+// Server statuses
+const serverStatusRunning string = "running"
+const serverStatusStarting string = "starting"
+const serverStatusOff string = "off"
+const serverStatusStopping string = "stopping"
+
+const propertyState string = "state"
+
+// Hetzner fetches servers from the Hetzner Cloud API
+type Hetzner struct {
+	config *config.Provider
+	api    *rest.Client
+}
+
+type serverList struct {
+	Servers []*server `json:"servers"`
+	Meta    *struct {
+		Pagination *struct {
+			NextPage int `json:"next_page"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+type serverResponse struct {
+	Server *server `json:"server"`
+}
+
+type server struct {
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	Status     string            `json:"status"`
+	Created    string            `json:"created"`
+	Labels     map[string]string `json:"labels"`
+	ServerType *struct {
+		Name   string  `json:"name"`
+		Cores  int     `json:"cores"`
+		Memory float64 `json:"memory"`
+		Disk   int     `json:"disk"`
+	} `json:"server_type"`
+	Datacenter *struct {
+		Name     string `json:"name"`
+		Location *struct {
+			Name string `json:"name"`
+		} `json:"location"`
+	} `json:"datacenter"`
+	PublicNet *struct {
+		IPv4 *struct {
+			IP string `json:"ip"`
+		} `json:"ipv4"`
+	} `json:"public_net"`
+	Image *struct {
+		Name string `json:"name"`
+	} `json:"image"`
+}
+
+type updateServerRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// IsConfigured checks whether the Hetzner Cloud API token has been set
+func IsConfigured() bool {
+	return len(os.Getenv(tokenEnvVar)) > 0
+}
+
+// New returns a Hetzner provider that uses the provided configuration instead of the environment
+func New(providerConfig *config.Provider) *Hetzner {
+	return &Hetzner{config: providerConfig}
+}
+
+func (h *Hetzner) GetName() string {
+	return hetznerProviderName
+}
+
+// Init initializes the provider using the API token in the provider's configuration or, if not
+// configured, the environment
+func (h *Hetzner) Init() error {
+	token := h.config.GetSetting("token", tokenEnvVar)
+	if len(token) == 0 {
+		return fmt.Errorf("The Hetzner API token needs to be set in %s or the providers configuration file to use the Hetzner provider", tokenEnvVar)
+	}
+
+	h.init(rest.NewClient(apiURL, rest.BearerToken(func() (string, error) { return token, nil })))
+
+	return nil
+}
+
+func (h *Hetzner) init(api *rest.Client) {
+	h.api = api
+}
+
+func (h *Hetzner) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	resources := []*types.InfraResource{}
+	if !filter.ConsiderResourceType(resourceTypeServer) {
+		return resources, nil
+	}
+
+	pageCount := 0
+	for page := 1; page > 0; {
+		query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(pageSize)}}
+		servers := new(serverList)
+		serversErr := h.api.GetWithContext(ctx, "servers", query, servers)
+		if serversErr != nil {
+			// Return the servers in the previous pages with the failure
+			return resources, types.ToInfraFailures(serversErr, hetznerProviderName, resourceTypeServer, "")
+		}
+		pageCount++
+
+		for _, curServer := range servers.Servers {
+			resource := getServerResource(curServer)
+			if !filter.ConsiderRegion(resource.Location) && !filter.ConsiderRegion(resource.Properties["datacenter"]) {
+				continue
+			}
+			if !filter.ConsiderTags(resource.Tags) {
+				continue
+			}
+
+			resources = append(resources, resource)
+		}
+
+		// The next page is not set in the last page
+		page = 0
+		if servers.Meta != nil && servers.Meta.Pagination != nil {
+			page = servers.Meta.Pagination.NextPage
+		}
+	}
+
+	notification.SendVerboseMessage(fmt.Sprintf("Fetched %d Hetzner servers from %d pages", len(resources), pageCount))
+
+	return resources, nil
+}
+
+// getServerResource converts the provided server into an InfraResource
+func getServerResource(server *server) *types.InfraResource {
+	properties := map[string]string{
+		"id":          strconv.Itoa(server.ID),
+		"name":        server.Name,
+		propertyState: server.Status,
+		"launch-time": server.Created,
+	}
+	if server.ServerType != nil {
+		properties["server-type"] = server.ServerType.Name
+		properties["cores"] = strconv.Itoa(server.ServerType.Cores)
+		properties["memory"] = strconv.FormatFloat(server.ServerType.Memory, 'f', -1, 64)
+		properties["disk"] = strconv.Itoa(server.ServerType.Disk)
+	}
+	if server.PublicNet != nil && server.PublicNet.IPv4 != nil {
+		properties["public-ip"] = server.PublicNet.IPv4.IP
+	}
+	if server.Image != nil {
+		properties["image"] = server.Image.Name
+	}
+
+	location := ""
+	if server.Datacenter != nil {
+		properties["datacenter"] = server.Datacenter.Name
+		if server.Datacenter.Location != nil {
+			location = server.Datacenter.Location.Name
+		}
+	}
+
+	tags := make(map[string]string)
+	for curKey, curValue := range server.Labels {
+		tags[curKey] = curValue
+	}
+
+	resource := &types.InfraResource{
+		Provider:     hetznerProviderName,
+		ID:           strconv.Itoa(server.ID),
+		Location:     location,
+		ResourceType: resourceTypeServer,
+		Tags:         tags,
+		Properties:   properties,
+	}
+	if launchTime, launchTimeErr := time.Parse(time.RFC3339, server.Created); launchTimeErr == nil {
+		resource.LaunchTime = launchTime
+	}
+
+	return resource
+}
+
+// getServerPath returns the path to the resource's server
+func getServerPath(resource *types.InfraResource) (string, error) {
+	if resource.Provider != hetznerProviderName {
+		return "", fmt.Errorf("Resource's provider is %s instead of %s", resource.Provider, hetznerProviderName)
+	}
+	if resource.ResourceType != resourceTypeServer {
+		return "", fmt.Errorf("Resources of type '%s' are not supported by the %s provider", resource.ResourceType, hetznerProviderName)
+	}
+	if len(resource.ID) == 0 {
+		return "", fmt.Errorf("The ID of the Hetzner server is not set")
+	}
+
+	return "servers/" + url.PathEscape(resource.ID), nil
+}
+
+// getServer fetches the server at the provided path
+func (h *Hetzner) getServer(serverPath string) (*server, error) {
+	response := new(serverResponse)
+	getErr := h.api.Get(serverPath, nil, response)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if response.Server == nil {
+		return nil, fmt.Errorf("Hetzner didn't return the server at '%s'", serverPath)
+	}
+
+	return response.Server, nil
+}
+
+// UpdateResourceTag sets the value of the server's label, or removes the label if tagValue is nil. Hetzner
+// labels are replaced as a whole so the server's current labels are fetched first
+func (h *Hetzner) UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	serverPath, pathErr := getServerPath(resource)
+	if pathErr != nil {
+		return pathErr
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the Hetzner server label because the label key is not set")
+	}
+
+	curServer, serverErr := h.getServer(serverPath)
+	if serverErr != nil {
+		return serverErr
+	}
+
+	labels := make(map[string]string)
+	for curKey, curValue := range curServer.Labels {
+		labels[curKey] = curValue
+	}
+	if tagValue == nil {
+		delete(labels, *tagKey)
+	} else {
+		labels[*tagKey] = *tagValue
+	}
+
+	return h.api.Do("PUT", serverPath, nil, &updateServerRequest{Labels: labels}, nil)
+}
+
+// UpdateResourceState powers off, powers on or deletes the server. In safe mode, only servers that are
+// off are deleted
+func (h *Hetzner) UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	serverPath, pathErr := getServerPath(resource)
+	if pathErr != nil {
+		return pathErr
+	}
+
+	// Fetch the server again to check whether it is already being powered off or on
+	curServer, serverErr := h.getServer(serverPath)
+	if serverErr != nil {
+		return serverErr
+	}
+
+	switch state {
+	case types.ResourceStateStopped:
+		if curServer.Status == serverStatusOff || curServer.Status == serverStatusStopping {
+			return nil
+		}
+
+		return h.api.Post(serverPath+"/actions/poweroff", nil, nil, nil)
+	case types.ResourceStateRunning:
+		if curServer.Status == serverStatusRunning || curServer.Status == serverStatusStarting {
+			return nil
+		}
+
+		return h.api.Post(serverPath+"/actions/poweron", nil, nil, nil)
+	case types.ResourceStateTerminated:
+		if safe && curServer.Status != serverStatusOff {
+			return fmt.Errorf("Refusing to delete the Hetzner server '%s' because it is '%s' instead of '%s'", resource.ID, curServer.Status, serverStatusOff)
+		}
+
+		return h.api.Do("DELETE", serverPath, nil, nil, nil)
+	}
+
+	return fmt.Errorf("Cannot update the state of a Hetzner server to '%s'", state)
+}
+
+// GetCostsAndUsages returns no costs since the Hetzner Cloud API doesn't expose billing data
+func (h *Hetzner) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	notification.SendVerboseMessage("Costs are not available for Hetzner")
+
+	return &types.CostAndUsageOutput{
+		Provider: hetznerProviderName,
+		Groups:   make(map[string]float64),
+		Period: &types.CostAndUsagePeriod{
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+		},
+	}, nil
+}
@@ -0,0 +1,148 @@
+package hetzner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/infra/rest/resttest"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// fakeAPI is a local fake of the parts of the Hetzner Cloud API used by the provider
+type fakeAPI struct {
+	server      *httptest.Server
+	updateBody  *updateServerRequest
+	actionPaths []string
+	deleted     bool
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		if !resttest.IsAuthorized(w, r) {
+			return
+		}
+
+		// Return the servers across two pages to test pagination
+		if r.URL.Query().Get("page") == "1" {
+			resttest.WriteJSON(t, w, map[string]interface{}{
+				"servers": []interface{}{getServer(1, "fsn1", "running")},
+				"meta":    map[string]interface{}{"pagination": map[string]interface{}{"next_page": 2}},
+			})
+			return
+		}
+
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"servers": []interface{}{getServer(2, "hel1", "off")},
+			"meta":    map[string]interface{}{"pagination": map[string]interface{}{"next_page": nil}},
+		})
+	})
+	mux.HandleFunc("/servers/1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			api.updateBody = new(updateServerRequest)
+			if !resttest.ReadJSON(t, w, r, api.updateBody) {
+				return
+			}
+		case "DELETE":
+			api.deleted = true
+		}
+		resttest.WriteJSON(t, w, map[string]interface{}{"server": getServer(1, "fsn1", "running")})
+	})
+	mux.HandleFunc("/servers/1/actions/", func(w http.ResponseWriter, r *http.Request) {
+		api.actionPaths = append(api.actionPaths, r.URL.Path)
+		resttest.WriteJSON(t, w, map[string]interface{}{})
+	})
+	api.server = httptest.NewServer(mux)
+
+	return api
+}
+
+func getServer(id int, location string, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          id,
+		"name":        "server",
+		"status":      status,
+		"created":     "2020-05-01T10:00:00+00:00",
+		"labels":      map[string]string{"owner": "alice", "env": "dev"},
+		"server_type": map[string]interface{}{"name": "cx11", "cores": 1, "memory": 2.0, "disk": 20},
+		"datacenter":  map[string]interface{}{"name": location + "-dc14", "location": map[string]string{"name": location}},
+		"public_net":  map[string]interface{}{"ipv4": map[string]string{"ip": "1.2.3.4"}},
+	}
+}
+
+func (api *fakeAPI) getProvider() *Hetzner {
+	provider := new(Hetzner)
+	provider.init(rest.NewClient(api.server.URL, resttest.Authorize))
+
+	return provider
+}
+
+// Test whether GetResources follows all the pages of servers and applies the filter
+func TestGetResources(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 2 {
+		t.Fatalf("Expecting 2 servers from the 2 pages; got %d and error %v", len(resources), resourcesErr)
+	}
+
+	resources, resourcesErr = provider.GetResources(context.Background(), &types.InfraFilter{Regions: []string{"fsn1"}})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 server in fsn1; got %d and error %v", len(resources), resourcesErr)
+	}
+
+	resource := resources[0]
+	if resource.ID != "1" || resource.ResourceType != resourceTypeServer || resource.Tags["owner"] != "alice" {
+		t.Errorf("Unexpected resource %+v", resource)
+	}
+	if resource.Properties["server-type"] != "cx11" || resource.Properties["public-ip"] != "1.2.3.4" || resource.Properties[propertyState] != "running" {
+		t.Errorf("Unexpected resource properties %v", resource.Properties)
+	}
+}
+
+// Test whether UpdateResourceTag keeps the server's other labels
+func TestUpdateResourceTag(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	tagKey := "env"
+	tagValue := "prod"
+	resource := &types.InfraResource{Provider: hetznerProviderName, ResourceType: resourceTypeServer, ID: "1"}
+	updateErr := provider.UpdateResourceTag(resource, &tagKey, &tagValue)
+	if updateErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", updateErr)
+	}
+
+	if api.updateBody == nil {
+		t.Fatalf("Expecting the server to be updated")
+	}
+	if api.updateBody.Labels["env"] != "prod" || api.updateBody.Labels["owner"] != "alice" {
+		t.Errorf("Expecting the updated label and the other labels to be sent; got %v", api.updateBody.Labels)
+	}
+}
+
+// Test whether UpdateResourceState powers off running servers and refuses to delete them in safe mode
+func TestUpdateResourceState(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	resource := &types.InfraResource{Provider: hetznerProviderName, ResourceType: resourceTypeServer, ID: "1"}
+	stopErr := provider.UpdateResourceState(resource, true, types.ResourceStateStopped)
+	if stopErr != nil || len(api.actionPaths) != 1 || api.actionPaths[0] != "/servers/1/actions/poweroff" {
+		t.Errorf("Expecting the server to be powered off; got actions %v and error %v", api.actionPaths, stopErr)
+	}
+
+	deleteErr := provider.UpdateResourceState(resource, true, types.ResourceStateTerminated)
+	if deleteErr == nil || api.deleted {
+		t.Errorf("Expecting an error, and the server not to be deleted, when deleting a running server in safe mode")
+	}
+}
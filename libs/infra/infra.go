@@ -1,21 +1,38 @@
 package infra
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/onaio/sre-tooling/libs/cli/flags"
 	"github.com/onaio/sre-tooling/libs/infra/aws"
+	"github.com/onaio/sre-tooling/libs/infra/azure"
+	"github.com/onaio/sre-tooling/libs/infra/cache"
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/infra/digitalocean"
+	"github.com/onaio/sre-tooling/libs/infra/file"
+	infraFilter "github.com/onaio/sre-tooling/libs/infra/filter"
+	"github.com/onaio/sre-tooling/libs/infra/gcp"
+	"github.com/onaio/sre-tooling/libs/infra/hetzner"
+	"github.com/onaio/sre-tooling/libs/infra/kubernetes"
+	"github.com/onaio/sre-tooling/libs/infra/plugin"
+	"github.com/onaio/sre-tooling/libs/notification"
 	"github.com/onaio/sre-tooling/libs/types"
 )
 
 type Provider interface {
 	Init() error
 	GetName() string
-	GetResources(filter *types.InfraFilter) ([]*types.InfraResource, error)
+	GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error)
 	GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error)
+	// UpdateResourceTag sets the value of the resource's tag, or removes the tag if tagValue is nil
 	UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error
 	UpdateResourceState(resource *types.InfraResource, safe bool, state string) error
 }
@@ -31,7 +48,15 @@ func GetCostsAndUsages(filter *types.CostAndUsageFilter) (map[string]*types.Cost
 	}
 
 	for _, provider := range providers {
-		costsAndUsages, err := provider.GetCostsAndUsages(filter)
+		if !considerProviderName(provider.GetName(), filter.Providers) {
+			continue
+		}
+
+		initErr := provider.init()
+		if initErr != nil {
+			return nil, initErr
+		}
+		costsAndUsages, err := provider.GetCostsAndUsages(provider.config.ApplyCostDefaults(filter))
 		if err != nil {
 			return nil, err
 		}
@@ -41,7 +66,33 @@ func GetCostsAndUsages(filter *types.CostAndUsageFilter) (map[string]*types.Cost
 	return allCostsAndUsages, nil
 }
 
+// GetResources returns the resources, from all the providers, matching the filter. If fetching some of the
+// resources fails, the resources that were fetched are returned with types.InfraFailures, unless Strict is set
 func GetResources(filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	return GetResourcesWithContext(context.Background(), filter)
+}
+
+// GetResourcesUncached is like GetResources but the resources are always fetched from the providers, even
+// if the resources cache is enabled. Commands that update resources use it so that they don't act on stale
+// resources
+func GetResourcesUncached(filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	return GetResourcesWithContext(WithoutCache(context.Background()), filter)
+}
+
+// contextKey is the type of the keys of the values GetResourcesWithContext gets from its context
+type contextKey string
+
+const withoutCacheContextKey contextKey = "without-cache"
+
+// WithoutCache returns a copy of ctx that makes GetResourcesWithContext fetch the resources from the
+// providers even if the resources cache is enabled
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutCacheContextKey, true)
+}
+
+// GetResourcesWithContext is like GetResources but the calls to the providers' APIs are made using the
+// provided context
+func GetResourcesWithContext(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
 	allResources := []*types.InfraResource{}
 
 	providers, providerErr := getProviders()
@@ -49,32 +100,322 @@ func GetResources(filter *types.InfraFilter) ([]*types.InfraResource, error) {
 		return nil, providerErr
 	}
 
+	var failures types.InfraFailures
 	for _, curProvider := range providers {
 		if considerProvider(curProvider, filter) {
-			pResources, curErr := curProvider.GetResources(filter)
+			pResources, curErr := getProviderResources(ctx, curProvider, curProvider.config.ApplyDefaults(filter))
 			if curErr != nil {
-				return nil, curErr
+				if Strict {
+					return nil, curErr
+				}
+				failures = append(failures, types.ToInfraFailures(curErr, curProvider.GetName(), "", "")...)
+			}
+
+			// Providers don't need to apply the whole expression when fetching the resources
+			for _, curResource := range pResources {
+				if filter.ConsiderExpression(curResource) {
+					allResources = append(allResources, curResource)
+				}
 			}
-			allResources = append(allResources, pResources...)
 		}
 	}
 
+	if len(failures) > 0 {
+		return allResources, failures
+	}
+
 	return allResources, nil
 }
 
-func getProviders() ([]Provider, error) {
-	providers := []Provider{}
+// Strict makes GetResources fail if fetching any of the resources fails, instead of returning the
+// resources that were fetched
+var Strict bool
+
+// getProviderResources returns the provider's resources matching the filter from the resources cache,
+// if enabled and the resources are cached, or from the provider. The provider is only initialized if the
+// resources are fetched from it, and providers that can't be initialized, e.g. because of missing
+// credentials, only fail their own resources. Resources are only cached if all of them were fetched
+func getProviderResources(ctx context.Context, provider *configuredProvider, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	resourcesCache := getResourcesCache()
+	if resourcesCache == nil || ctx.Value(withoutCacheContextKey) != nil {
+		initErr := provider.init()
+		if initErr != nil {
+			return nil, initErr
+		}
+
+		return provider.GetResources(ctx, filter)
+	}
+
+	// Cache all the resources of the types and in the regions and accounts in the filter so that the
+	// cached resources can be used with other tag filters and expressions
+	fetchFilter := *filter
+	fetchFilter.Tags = nil
+	fetchFilter.Expression = nil
+	key := getCacheKey(&fetchFilter)
+	resources, cacheTime, cached := resourcesCache.Get(provider.GetName(), key)
+	if cached {
+		notification.SendVerboseMessage(fmt.Sprintf("Using the %s resources cached at %s", provider.GetName(), cacheTime.Format(time.RFC3339)))
+	} else {
+		initErr := provider.init()
+		if initErr != nil {
+			return nil, initErr
+		}
+
+		var resourcesErr error
+		resources, resourcesErr = provider.GetResources(ctx, &fetchFilter)
+		if resourcesErr != nil {
+			return filterResourcesByTags(resources, filter), resourcesErr
+		}
+
+		putErr := resourcesCache.Put(provider.GetName(), key, resources)
+		if putErr != nil {
+			notification.SendVerboseMessage(fmt.Sprintf("Could not cache the %s resources: %v", provider.GetName(), putErr))
+		}
+	}
+
+	return filterResourcesByTags(resources, filter), nil
+}
+
+// filterResourcesByTags returns the resources with all the filter's tags
+func filterResourcesByTags(resources []*types.InfraResource, filter *types.InfraFilter) []*types.InfraResource {
+	matchingResources := []*types.InfraResource{}
+	for _, curResource := range resources {
+		if filter.ConsiderTags(curResource.Tags) {
+			matchingResources = append(matchingResources, curResource)
+		}
+	}
+
+	return matchingResources
+}
+
+// getCacheKey returns the key of the resources matching the filter in the resources cache. Case is
+// ignored and the order of the values in the filter doesn't matter
+func getCacheKey(filter *types.InfraFilter) string {
+	parts := []string{}
+	for _, curValues := range [][]string{filter.ResourceTypes, filter.Regions, filter.Accounts} {
+		values := make([]string, len(curValues))
+		for i, curValue := range curValues {
+			values[i] = strings.ToLower(curValue)
+		}
+		sort.Strings(values)
+		parts = append(parts, strings.Join(values, ","))
+	}
+
+	return strings.Join(parts, "|")
+}
+
+// CacheTTL is how long the resources fetched from the providers are cached for. If not set, the duration
+// in SRE_INFRA_CACHE_TTL is used. Resources are not cached if neither is set
+var CacheTTL time.Duration
+
+// NoCache disables the resources cache, even if the TTL is set
+var NoCache bool
+
+const cacheTTLEnvVar = "SRE_INFRA_CACHE_TTL"
+
+// getResourcesCache returns the resources cache, or nil if the resources shouldn't be cached
+func getResourcesCache() *cache.Cache {
+	if NoCache {
+		return nil
+	}
+
+	ttl := CacheTTL
+	if ttl <= 0 {
+		ttlValue := os.Getenv(cacheTTLEnvVar)
+		if len(ttlValue) == 0 {
+			return nil
+		}
+
+		envTTL, ttlErr := time.ParseDuration(ttlValue)
+		if ttlErr != nil || envTTL <= 0 {
+			notification.SendVerboseMessage(fmt.Sprintf("Not caching resources since %s '%s' is not a positive duration", cacheTTLEnvVar, ttlValue))
+			return nil
+		}
+		ttl = envTTL
+	}
+
+	dir, dirErr := cache.DefaultDir()
+	if dirErr != nil {
+		notification.SendVerboseMessage(fmt.Sprintf("Not caching resources since the cache directory could not be determined: %v", dirErr))
+		return nil
+	}
+
+	return &cache.Cache{Dir: dir, TTL: ttl}
+}
+
+// ClearResourcesCache removes all the resources in the resources cache
+func ClearResourcesCache() error {
+	dir, dirErr := cache.DefaultDir()
+	if dirErr != nil {
+		return dirErr
+	}
+
+	return (&cache.Cache{Dir: dir}).Clear()
+}
+
+// clearProviderCache removes the provider's cached resources, e.g. after the provider's resources are
+// updated, so that the next commands don't use stale resources. The resources are removed even if the
+// cache is disabled in this command since other commands might use it
+func clearProviderCache(providerName string) {
+	dir, dirErr := cache.DefaultDir()
+	if dirErr != nil {
+		return
+	}
+
+	clearErr := (&cache.Cache{Dir: dir}).ClearProvider(providerName)
+	if clearErr != nil {
+		notification.SendVerboseMessage(fmt.Sprintf("Could not clear the cached %s resources: %v", providerName, clearErr))
+	}
+}
+
+// ProvidersConfigFile is the path to the providers configuration file. If empty, the file at the default
+// path is used if it exists
+var ProvidersConfigFile string
+
+// providersCache holds the providers loaded from ProvidersConfigFile so that each provider is only
+// initialized once per process, however many times the providers are used
+var providersCache struct {
+	sync.Mutex
+	configFile string
+	providers  []*configuredProvider
+}
+
+// configuredProvider is a provider and its configuration, which is nil if the providers configuration file
+// isn't used. Providers are only initialized when they are used so that a provider that can't be
+// initialized doesn't break commands that don't use it
+type configuredProvider struct {
+	Provider
+	config      *config.Provider
+	initLock    sync.Mutex
+	initialized bool
+	initErr     error
+}
+
+// init initializes the provider the first time it is called and returns the initialization error
+func (p *configuredProvider) init() error {
+	p.initLock.Lock()
+	defer p.initLock.Unlock()
 
-	aws := new(aws.AWS)
-	awsErr := aws.Init()
-	if awsErr != nil {
-		return nil, awsErr
+	if !p.initialized {
+		p.initErr = p.Init()
+		p.initialized = true
+	}
+
+	return p.initErr
+}
+
+// builtInProvider creates one of the providers built into sre-tooling
+type builtInProvider struct {
+	name string
+
+	// isConfigured checks whether the provider is configured in the environment. Only used if there is no
+	// providers configuration file
+	isConfigured func() bool
+	new          func(providerConfig *config.Provider) Provider
+}
+
+var builtInProviders = []*builtInProvider{
+	&builtInProvider{"aws", func() bool { return true }, func(c *config.Provider) Provider { return aws.New(c) }},
+	&builtInProvider{"gcp", gcp.IsConfigured, func(c *config.Provider) Provider { return gcp.New(c) }},
+	&builtInProvider{"azure", azure.IsConfigured, func(c *config.Provider) Provider { return azure.New(c) }},
+	&builtInProvider{"digitalocean", digitalocean.IsConfigured, func(c *config.Provider) Provider { return digitalocean.New(c) }},
+	&builtInProvider{"hetzner", hetzner.IsConfigured, func(c *config.Provider) Provider { return hetzner.New(c) }},
+	&builtInProvider{"file", file.IsConfigured, func(c *config.Provider) Provider { return file.New(c) }},
+	&builtInProvider{"kubernetes", kubernetes.IsConfigured, func(c *config.Provider) Provider { return kubernetes.New(c) }},
+}
+
+// getProviders returns the providers loaded by loadProviders. The providers are loaded the first time
+// getProviders is called, or after ProvidersConfigFile changes, then reused
+func getProviders() ([]*configuredProvider, error) {
+	providersCache.Lock()
+	defer providersCache.Unlock()
+
+	if providersCache.providers == nil || providersCache.configFile != ProvidersConfigFile {
+		providers, providersErr := loadProviders()
+		if providersErr != nil {
+			return nil, providersErr
+		}
+
+		providersCache.configFile = ProvidersConfigFile
+		providersCache.providers = providers
+	}
+
+	return providersCache.providers, nil
+}
+
+// loadProviders returns the providers enabled in the providers configuration file. If there is no providers
+// configuration file, AWS, the other built-in providers configured in the environment and all the provider
+// plugins on PATH are returned. The providers are not initialized
+func loadProviders() ([]*configuredProvider, error) {
+	providersConfig, configErr := config.Load(ProvidersConfigFile)
+	if configErr != nil {
+		return nil, configErr
+	}
+
+	providers := []*configuredProvider{}
+	configuredNames := make(map[string]bool)
+	for _, curBuiltIn := range builtInProviders {
+		var providerConfig *config.Provider
+		if providersConfig != nil {
+			var enabled bool
+			providerConfig, enabled = providersConfig.GetProvider(curBuiltIn.name)
+			if providerConfig != nil {
+				configuredNames[curBuiltIn.name] = true
+			}
+			if !enabled {
+				continue
+			}
+		} else if !curBuiltIn.isConfigured() {
+			continue
+		}
+
+		providers = append(providers, &configuredProvider{Provider: curBuiltIn.new(providerConfig), config: providerConfig})
+	}
+
+	// Plugins can't replace the built-in providers
+	for _, curPlugin := range plugin.Discover() {
+		if isBuiltInProvider(curPlugin.GetName()) {
+			notification.SendVerboseMessage(fmt.Sprintf("Ignoring the %s provider plugin since a built-in provider has the same name", curPlugin.GetName()))
+			continue
+		}
+
+		var providerConfig *config.Provider
+		if providersConfig != nil {
+			var enabled bool
+			providerConfig, enabled = providersConfig.GetProvider(curPlugin.GetName())
+			if providerConfig != nil {
+				configuredNames[strings.ToLower(curPlugin.GetName())] = true
+			}
+			if !enabled {
+				continue
+			}
+		}
+
+		providers = append(providers, &configuredProvider{Provider: curPlugin, config: providerConfig})
+	}
+
+	if providersConfig != nil {
+		for curName := range providersConfig.Providers {
+			if !configuredNames[strings.ToLower(curName)] {
+				return nil, fmt.Errorf("'%s' in the providers configuration file is neither a built-in provider nor a provider plugin on PATH", curName)
+			}
+		}
 	}
-	providers = append(providers, aws)
 
 	return providers, nil
 }
 
+// isBuiltInProvider checks whether the name is the name of a built-in provider. Case is ignored
+func isBuiltInProvider(name string) bool {
+	for _, curBuiltIn := range builtInProviders {
+		if strings.EqualFold(curBuiltIn.name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func GetTagKeys(resource *types.InfraResource) []string {
 	keyObjects := reflect.ValueOf(resource.Tags).MapKeys()
 	keys := make([]string, len(keyObjects))
@@ -85,7 +426,12 @@ func GetTagKeys(resource *types.InfraResource) []string {
 	return keys
 }
 
-func AddFilterFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray) {
+// AddCacheFlag adds the -no-cache flag to commands that only read resources and can use the resources cache
+func AddCacheFlag(flagSet *flag.FlagSet) *bool {
+	return flagSet.Bool("no-cache", false, "Whether to fetch the resources from the providers instead of using the cached resources")
+}
+
+func AddFilterFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *flags.StringArray, *string) {
 	providerFlag := new(flags.StringArray)
 	flagSet.Var(providerFlag, "filter-provider", "Name of provider to filter using. Multiple values can be provided by specifying multiple -filter-provider")
 	regionFlag := new(flags.StringArray)
@@ -95,14 +441,24 @@ func AddFilterFlags(flagSet *flag.FlagSet) (*flags.StringArray, *flags.StringArr
 	tagFlag := new(flags.StringArray)
 	flagSet.Var(tagFlag, "filter-tag", "Resource tag to filter using. Use the format \"tagKey"+tagFlagSeparator+"tagValue\". Multiple values can be provided by specifying multiple -filter-tag")
 
-	return providerFlag, regionFlag, typeFlag, tagFlag
+	accountFlag := new(flags.StringArray)
+	flagSet.Var(accountFlag, "filter-account", "ID or name (e.g. the AWS role ARN or profile) of a provider account to filter using. Multiple values can be provided by specifying multiple -filter-account")
+
+	expressionFlag := flagSet.String("filter", "", "Expression resources need to match e.g. \"tag:Environment == production && (property:state == running || age > 30d)\"")
+
+	return providerFlag, regionFlag, typeFlag, tagFlag, accountFlag, expressionFlag
 }
 
-func GetFiltersFromCommandFlags(providerFlag *flags.StringArray, regionFlag *flags.StringArray, typeFlag *flags.StringArray, tagFlag *flags.StringArray) *types.InfraFilter {
+// GetFiltersFromCommandFlags returns the filter set using the flags added by AddFilterFlags. An error is
+// returned if the -filter expression is invalid
+func GetFiltersFromCommandFlags(providerFlag *flags.StringArray, regionFlag *flags.StringArray, typeFlag *flags.StringArray, tagFlag *flags.StringArray, accountFlag *flags.StringArray, expressionFlag *string) (*types.InfraFilter, error) {
 	filter := types.InfraFilter{}
 	if len(*providerFlag) > 0 {
 		filter.Providers = *providerFlag
 	}
+	if len(*accountFlag) > 0 {
+		filter.Accounts = *accountFlag
+	}
 	if len(*typeFlag) > 0 {
 		filter.ResourceTypes = *typeFlag
 	}
@@ -120,8 +476,15 @@ func GetFiltersFromCommandFlags(providerFlag *flags.StringArray, regionFlag *fla
 			}
 		}
 	}
+	if len(*expressionFlag) > 0 {
+		expression, expressionErr := infraFilter.Parse(*expressionFlag)
+		if expressionErr != nil {
+			return nil, expressionErr
+		}
+		filter.Expression = expression
+	}
 
-	return &filter
+	return &filter, nil
 }
 
 func UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
@@ -130,23 +493,58 @@ func UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *
 		return providerErr
 	}
 
+	defer clearProviderCache(resource.Provider)
+	return updateResourceTag(providers, resource, tagKey, tagValue)
+}
+
+// updateResourceTag updates the resource's tag using the resource's provider in providers
+func updateResourceTag(providers []*configuredProvider, resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	provider, providerErr := getResourceProvider(providers, resource)
+	if providerErr != nil {
+		return providerErr
+	}
+
+	return provider.UpdateResourceTag(resource, tagKey, tagValue)
+}
+
+// getResourceProvider returns the resource's provider in providers, initialized
+func getResourceProvider(providers []*configuredProvider, resource *types.InfraResource) (*configuredProvider, error) {
 	for _, curProvider := range providers {
 		if curProvider.GetName() == resource.Provider {
-			return curProvider.UpdateResourceTag(resource, tagKey, tagValue)
+			return curProvider, curProvider.init()
 		}
 	}
 
-	return fmt.Errorf("Provider '%s' isn't implemented yet", resource.Provider)
+	return nil, fmt.Errorf("Provider '%s' isn't implemented yet", resource.Provider)
+}
+
+func UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	providers, providerErr := getProviders()
+	if providerErr != nil {
+		return providerErr
+	}
+
+	provider, providerErr := getResourceProvider(providers, resource)
+	if providerErr != nil {
+		return providerErr
+	}
+
+	defer clearProviderCache(resource.Provider)
+	return provider.UpdateResourceState(resource, safe, state)
 }
 
 func considerProvider(providerIface interface{}, filter *types.InfraFilter) bool {
 	provider := providerIface.(Provider)
-	if len(filter.Providers) == 0 {
+	return considerProviderName(provider.GetName(), filter.Providers)
+}
+
+func considerProviderName(providerName string, filterProviders []string) bool {
+	if len(filterProviders) == 0 {
 		return true
 	}
 
-	for _, curProviderName := range filter.Providers {
-		if strings.ToLower(curProviderName) == strings.ToLower(provider.GetName()) {
+	for _, curProviderName := range filterProviders {
+		if strings.ToLower(curProviderName) == strings.ToLower(providerName) {
 			return true
 		}
 	}
@@ -0,0 +1,266 @@
+package infra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/cli/flags"
+	"github.com/onaio/sre-tooling/libs/infra/plugin"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// Test whether only the providers in the providers configuration file are initialized, and whether
+// their default filters are used
+func TestGetProvidersFromConfig(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "providers")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	inventoryPath := filepath.Join(dir, "inventory.yml")
+	inventoryErr := ioutil.WriteFile(inventoryPath, []byte("resources:\n- id: server-1\n  type: Server\n  location: nairobi\n- id: server-2\n  type: Server\n  location: amsterdam\n"), 0600)
+	if inventoryErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", inventoryErr)
+	}
+
+	configPath := filepath.Join(dir, "providers.yaml")
+	configErr := ioutil.WriteFile(configPath, []byte("providers:\n  file:\n    settings:\n      inventory: "+inventoryPath+"\n    defaults:\n      regions:\n      - nairobi\n"), 0600)
+	if configErr != nil {
+		t.Fatalf("Could not write the providers configuration file: %v", configErr)
+	}
+
+	ProvidersConfigFile = configPath
+	defer func() { ProvidersConfigFile = "" }()
+
+	providers, providersErr := getProviders()
+	if providersErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", providersErr)
+	}
+	if len(providers) != 1 || providers[0].GetName() != "file" {
+		t.Fatalf("Expecting only the file provider to be initialized; got %d providers", len(providers))
+	}
+
+	cachedProviders, cachedErr := getProviders()
+	if cachedErr != nil || len(cachedProviders) != 1 || cachedProviders[0] != providers[0] {
+		t.Errorf("Expecting the providers to be reused; got %v and error %v", cachedProviders, cachedErr)
+	}
+
+	resources, resourcesErr := GetResources(&types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 1 || resources[0].ID != "server-1" {
+		t.Errorf("Expecting only the resource in the default region; got %v and error %v", resources, resourcesErr)
+	}
+
+	unknownErr := ioutil.WriteFile(configPath, []byte("providers:\n  unknown: {}\n"), 0600)
+	if unknownErr != nil {
+		t.Fatalf("Could not write the providers configuration file: %v", unknownErr)
+	}
+	_, providersErr = loadProviders()
+	if providersErr == nil {
+		t.Errorf("Expecting an error for a provider that doesn't exist")
+	}
+}
+
+// Test whether providers that can't be initialized only fail their own resources, and aren't initialized
+// if they are filtered out
+func TestGetResourcesWithUninitializedProvider(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "providers")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	inventoryPath := filepath.Join(dir, "inventory.yml")
+	inventoryErr := ioutil.WriteFile(inventoryPath, []byte("resources:\n- id: server-1\n  type: Server\n"), 0600)
+	if inventoryErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", inventoryErr)
+	}
+
+	// GCP can't be initialized without projects
+	configPath := filepath.Join(dir, "providers.yaml")
+	configErr := ioutil.WriteFile(configPath, []byte("providers:\n  file:\n    settings:\n      inventory: "+inventoryPath+"\n  gcp: {}\n"), 0600)
+	if configErr != nil {
+		t.Fatalf("Could not write the providers configuration file: %v", configErr)
+	}
+
+	projects := os.Getenv("SRE_INFRA_GCP_PROJECTS")
+	os.Unsetenv("SRE_INFRA_GCP_PROJECTS")
+	defer os.Setenv("SRE_INFRA_GCP_PROJECTS", projects)
+	ProvidersConfigFile = configPath
+	defer func() { ProvidersConfigFile = "" }()
+
+	resources, resourcesErr := GetResources(&types.InfraFilter{Providers: []string{"file"}})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Errorf("Expecting the file resources without errors; got %v and error %v", resources, resourcesErr)
+	}
+
+	resources, resourcesErr = GetResources(&types.InfraFilter{})
+	failures, partial := resourcesErr.(types.InfraFailures)
+	if !partial || len(failures) != 1 || failures[0].Provider != "GCP" || len(resources) != 1 {
+		t.Errorf("Expecting the file resources and the GCP failure; got %v and error %v", resources, resourcesErr)
+	}
+}
+
+// Test whether the -filter expression is parsed and evaluated on the resources returned by the providers
+func TestGetResourcesWithExpression(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "providers")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	inventoryPath := filepath.Join(dir, "inventory.yml")
+	inventoryErr := ioutil.WriteFile(inventoryPath, []byte("resources:\n- id: server-1\n  type: Server\n  properties:\n    state: running\n- id: server-2\n  type: Server\n  tags:\n    Owner: sre\n  properties:\n    state: stopped\n"), 0600)
+	if inventoryErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", inventoryErr)
+	}
+
+	configPath := filepath.Join(dir, "providers.yaml")
+	configErr := ioutil.WriteFile(configPath, []byte("providers:\n  file:\n    settings:\n      inventory: "+inventoryPath+"\n"), 0600)
+	if configErr != nil {
+		t.Fatalf("Could not write the providers configuration file: %v", configErr)
+	}
+
+	ProvidersConfigFile = configPath
+	defer func() { ProvidersConfigFile = "" }()
+
+	emptyFlag := new(flags.StringArray)
+	expression := "property:state == stopped && tag:Owner"
+	filter, filterErr := GetFiltersFromCommandFlags(emptyFlag, emptyFlag, emptyFlag, emptyFlag, emptyFlag, &expression)
+	if filterErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", filterErr)
+	}
+
+	resources, resourcesErr := GetResources(filter)
+	if resourcesErr != nil || len(resources) != 1 || resources[0].ID != "server-2" {
+		t.Errorf("Expecting only the resource matching the expression; got %v and error %v", resources, resourcesErr)
+	}
+
+	invalidExpression := "property:state = stopped"
+	_, filterErr = GetFiltersFromCommandFlags(emptyFlag, emptyFlag, emptyFlag, emptyFlag, emptyFlag, &invalidExpression)
+	if filterErr == nil {
+		t.Errorf("Expecting an error for an invalid expression")
+	}
+}
+
+// Test whether the resources are cached per provider, filtered by tag client-side, not used by
+// GetResourcesUncached and fetched again after being updated
+func TestGetResourcesWithCache(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "providers")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	inventoryPath := filepath.Join(dir, "inventory.yml")
+	inventoryErr := ioutil.WriteFile(inventoryPath, []byte("resources:\n- id: server-1\n  type: Server\n  tags:\n    Owner: sre\n- id: server-2\n  type: Server\n"), 0600)
+	if inventoryErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", inventoryErr)
+	}
+
+	configPath := filepath.Join(dir, "providers.yaml")
+	configErr := ioutil.WriteFile(configPath, []byte("providers:\n  file:\n    settings:\n      inventory: "+inventoryPath+"\n"), 0600)
+	if configErr != nil {
+		t.Fatalf("Could not write the providers configuration file: %v", configErr)
+	}
+
+	oldCacheHome := os.Getenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", dir)
+	defer os.Setenv("XDG_CACHE_HOME", oldCacheHome)
+	ProvidersConfigFile = configPath
+	CacheTTL = time.Hour
+	defer func() {
+		ProvidersConfigFile = ""
+		CacheTTL = 0
+	}()
+
+	resources, resourcesErr := GetResources(&types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 2 {
+		t.Fatalf("Expecting all the resources; got %v and error %v", resources, resourcesErr)
+	}
+
+	// The inventory changing shouldn't matter until the cache expires
+	inventoryErr = ioutil.WriteFile(inventoryPath, []byte("resources: []\n"), 0600)
+	if inventoryErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", inventoryErr)
+	}
+
+	resources, resourcesErr = GetResources(&types.InfraFilter{Tags: map[string]string{"Owner": "sre"}})
+	if resourcesErr != nil || len(resources) != 1 || resources[0].ID != "server-1" {
+		t.Errorf("Expecting the cached resource with the tag; got %v and error %v", resources, resourcesErr)
+	}
+
+	resources, resourcesErr = GetResourcesUncached(&types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 0 {
+		t.Errorf("Expecting GetResourcesUncached not to use the cache; got %v and error %v", resources, resourcesErr)
+	}
+
+	NoCache = true
+	resources, resourcesErr = GetResources(&types.InfraFilter{})
+	NoCache = false
+	if resourcesErr != nil || len(resources) != 0 {
+		t.Errorf("Expecting the resources not to be cached with NoCache; got %v and error %v", resources, resourcesErr)
+	}
+
+	clearProviderCache("file")
+	resources, resourcesErr = GetResources(&types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 0 {
+		t.Errorf("Expecting the resources to be fetched again after clearing the cache; got %v and error %v", resources, resourcesErr)
+	}
+}
+
+// Test whether the resources of the providers that didn't fail are returned with the failures, unless
+// Strict is set
+func TestGetResourcesWithFailures(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "providers")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	inventoryPath := filepath.Join(dir, "inventory.yml")
+	inventoryErr := ioutil.WriteFile(inventoryPath, []byte("resources:\n- id: server-1\n  type: Server\n"), 0600)
+	if inventoryErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", inventoryErr)
+	}
+
+	pluginErr := ioutil.WriteFile(filepath.Join(dir, plugin.ExecutablePrefix+"broken"), []byte("#!/bin/sh\necho 'API unavailable' >&2\nexit 1\n"), 0755)
+	if pluginErr != nil {
+		t.Fatalf("Could not write the test plugin: %v", pluginErr)
+	}
+
+	configPath := filepath.Join(dir, "providers.yaml")
+	configErr := ioutil.WriteFile(configPath, []byte("providers:\n  file:\n    settings:\n      inventory: "+inventoryPath+"\n  broken: {}\n"), 0600)
+	if configErr != nil {
+		t.Fatalf("Could not write the providers configuration file: %v", configErr)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir)
+	defer os.Setenv("PATH", path)
+	ProvidersConfigFile = configPath
+	defer func() { ProvidersConfigFile = "" }()
+
+	resources, resourcesErr := GetResources(&types.InfraFilter{})
+	failures, partial := resourcesErr.(types.InfraFailures)
+	if !partial || len(failures) != 1 || failures[0].Provider != "broken" {
+		t.Errorf("Expecting the failure of the broken provider; got %v", resourcesErr)
+	}
+	if len(resources) != 1 || resources[0].ID != "server-1" {
+		t.Errorf("Expecting the resources of the other providers; got %v", resources)
+	}
+	if !strings.Contains(RenderFailures(failures), "API unavailable") {
+		t.Errorf("Expecting the failures section to have the error; got %s", RenderFailures(failures))
+	}
+
+	Strict = true
+	defer func() { Strict = false }()
+	resources, resourcesErr = GetResources(&types.InfraFilter{})
+	if _, partial := resourcesErr.(types.InfraFailures); resourcesErr == nil || partial || resources != nil {
+		t.Errorf("Expecting only the error with Strict; got %v and error %v", resources, resourcesErr)
+	}
+}
@@ -0,0 +1,197 @@
+package kubernetes
+
+// This file contains the logic for creating API clients from the clusters and users in a kubeconfig file
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"gopkg.in/yaml.v2"
+)
+
+const kubeconfigEnvVar string = "KUBECONFIG"
+const requestTimeout = 60 * time.Second
+
+type kubeconfig struct {
+	Clusters []*struct {
+		Name    string             `yaml:"name"`
+		Cluster *kubeconfigCluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []*struct {
+		Name string          `yaml:"name"`
+		User *kubeconfigUser `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []*struct {
+		Name    string             `yaml:"name"`
+		Context *kubeconfigContext `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+type kubeconfigCluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+}
+
+type kubeconfigUser struct {
+	Token                 string `yaml:"token"`
+	TokenFile             string `yaml:"tokenFile"`
+	ClientCertificate     string `yaml:"client-certificate"`
+	ClientCertificateData string `yaml:"client-certificate-data"`
+	ClientKey             string `yaml:"client-key"`
+	ClientKeyData         string `yaml:"client-key-data"`
+}
+
+type kubeconfigContext struct {
+	Cluster string `yaml:"cluster"`
+	User    string `yaml:"user"`
+}
+
+// getKubeconfigPath returns the path to the kubeconfig file, which is configuredPath if set, the first path
+// in KUBECONFIG or ~/.kube/config if KUBECONFIG is not set
+func getKubeconfigPath(configuredPath string) (string, error) {
+	if len(configuredPath) > 0 {
+		return configuredPath, nil
+	}
+	if paths := filepath.SplitList(os.Getenv(kubeconfigEnvVar)); len(paths) > 0 && len(paths[0]) > 0 {
+		return paths[0], nil
+	}
+
+	home, homeErr := os.UserHomeDir()
+	if homeErr != nil {
+		return "", homeErr
+	}
+
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// readKubeconfig reads and parses the kubeconfig file at the path
+func readKubeconfig(path string) (*kubeconfig, error) {
+	content, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	config := new(kubeconfig)
+	parseErr := yaml.Unmarshal(content, config)
+	if parseErr != nil {
+		return nil, fmt.Errorf("Could not parse the kubeconfig file %s: %v", path, parseErr)
+	}
+
+	return config, nil
+}
+
+// newContextClient returns an API client for the cluster in the context, authenticated as the context's user.
+// Only static tokens and client certificates are supported, not exec or auth provider plugins
+func (config *kubeconfig) newContextClient(contextName string, baseDir string) (*rest.Client, string, error) {
+	var context *kubeconfigContext
+	for _, curContext := range config.Contexts {
+		if curContext.Name == contextName {
+			context = curContext.Context
+			break
+		}
+	}
+	if context == nil {
+		return nil, "", fmt.Errorf("The context '%s' is not in the kubeconfig file", contextName)
+	}
+
+	var cluster *kubeconfigCluster
+	for _, curCluster := range config.Clusters {
+		if curCluster.Name == context.Cluster {
+			cluster = curCluster.Cluster
+			break
+		}
+	}
+	if cluster == nil || len(cluster.Server) == 0 {
+		return nil, "", fmt.Errorf("The cluster '%s' of the context '%s' is not in the kubeconfig file", context.Cluster, contextName)
+	}
+
+	user := new(kubeconfigUser)
+	for _, curUser := range config.Users {
+		if curUser.Name == context.User && curUser.User != nil {
+			user = curUser.User
+			break
+		}
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cluster.InsecureSkipTLSVerify}
+	caData, caErr := readDataOrFile(cluster.CertificateAuthorityData, cluster.CertificateAuthority, baseDir)
+	if caErr != nil {
+		return nil, "", caErr
+	}
+	if len(caData) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caData) {
+			return nil, "", fmt.Errorf("Could not load the certificate authority of the cluster '%s'", context.Cluster)
+		}
+	}
+
+	certData, certErr := readDataOrFile(user.ClientCertificateData, user.ClientCertificate, baseDir)
+	if certErr != nil {
+		return nil, "", certErr
+	}
+	keyData, keyErr := readDataOrFile(user.ClientKeyData, user.ClientKey, baseDir)
+	if keyErr != nil {
+		return nil, "", keyErr
+	}
+	if len(certData) > 0 && len(keyData) > 0 {
+		certificate, certificateErr := tls.X509KeyPair(certData, keyData)
+		if certificateErr != nil {
+			return nil, "", fmt.Errorf("Could not load the client certificate of the user '%s': %v", context.User, certificateErr)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	token := user.Token
+	if len(token) == 0 && len(user.TokenFile) > 0 {
+		tokenData, tokenErr := ioutil.ReadFile(resolvePath(user.TokenFile, baseDir))
+		if tokenErr != nil {
+			return nil, "", tokenErr
+		}
+		token = strings.TrimSpace(string(tokenData))
+	}
+
+	var authorize rest.Authorizer
+	if len(token) > 0 {
+		authorize = rest.BearerToken(func() (string, error) { return token, nil })
+	}
+
+	client := rest.NewClient(cluster.Server, authorize)
+	client.HTTPClient = &http.Client{
+		Timeout:   requestTimeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+	}
+
+	return client, context.Cluster, nil
+}
+
+// readDataOrFile returns the base64 decoded data if set, or the content of the file otherwise
+func readDataOrFile(data string, path string, baseDir string) ([]byte, error) {
+	if len(data) > 0 {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if len(path) > 0 {
+		return ioutil.ReadFile(resolvePath(path, baseDir))
+	}
+
+	return nil, nil
+}
+
+// resolvePath resolves paths in the kubeconfig file relative to the kubeconfig file's directory
+func resolvePath(path string, baseDir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(baseDir, path)
+}
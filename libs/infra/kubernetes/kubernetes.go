@@ -0,0 +1,695 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/config"
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const kubernetesProviderName string = "Kubernetes"
+const resourceTypeNode string = "Node"
+const resourceTypeNamespace string = "Namespace"
+const resourceTypeDeployment string = "Deployment"
+const contextsEnvVar string = "SRE_INFRA_KUBERNETES_CONTEXTS"
+const contextsSeparator string = ","
+const pageSize = 500
+
+// deploymentIDSeparator separates the namespace and the name in a deployment's ID
+const deploymentIDSeparator string = "/"
+
+// replicasAnnotation holds the number of replicas a deployment had before it was scaled to zero, so
+// that it can be scaled back up
+const replicasAnnotation string = "sre-tooling.onaio.com/replicas"
+
+// defaultNamespace and the namespaces with systemNamespacePrefix (e.g. kube-system and kube-public) are
+// created with the cluster. Their deployments are never stopped or deleted and they are never deleted
+const defaultNamespace string = "default"
+const systemNamespacePrefix string = "kube-"
+
+// lastAppliedAnnotation is set by kubectl apply and is not mapped to a tag since it has the whole manifest
+const lastAppliedAnnotation string = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Phases of the pods that have finished running
+const podPhaseSucceeded string = "Succeeded"
+const podPhaseFailed string = "Failed"
+
+const propertyContext string = "context"
+const propertyCluster string = "cluster"
+const propertyNamespace string = "namespace"
+const propertyReplicas string = "replicas"
+const propertyState string = "state"
+
+// propertyAccountID is set to the resource's context so that contexts can be filtered using -filter-account
+const propertyAccountID string = "account-id"
+
+// Kubernetes fetches nodes, namespaces and deployments from the clusters in a kubeconfig file
+type Kubernetes struct {
+	config   *config.Provider
+	clusters []*cluster
+}
+
+// cluster is a cluster, and the user to access it as, in a kubeconfig context
+type cluster struct {
+	context string
+	name    string
+	api     *rest.Client
+}
+
+type objectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	CreationTimestamp string            `json:"creationTimestamp,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	DeletionTimestamp string            `json:"deletionTimestamp,omitempty"`
+	OwnerReferences   []*struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	} `json:"ownerReferences,omitempty"`
+}
+
+type listMeta struct {
+	Continue string `json:"continue"`
+}
+
+type nodeList struct {
+	Metadata *listMeta `json:"metadata"`
+	Items    []*node   `json:"items"`
+}
+
+type node struct {
+	Metadata *objectMeta `json:"metadata"`
+	Spec     *struct {
+		ProviderID    string `json:"providerID"`
+		Unschedulable bool   `json:"unschedulable"`
+	} `json:"spec"`
+	Status *struct {
+		Conditions []*struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+		Addresses []*struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+		NodeInfo *struct {
+			KubeletVersion string `json:"kubeletVersion"`
+			OSImage        string `json:"osImage"`
+		} `json:"nodeInfo"`
+	} `json:"status"`
+}
+
+type namespaceList struct {
+	Metadata *listMeta    `json:"metadata"`
+	Items    []*namespace `json:"items"`
+}
+
+type namespace struct {
+	Metadata *objectMeta `json:"metadata"`
+	Status   *struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+type deploymentList struct {
+	Metadata *listMeta     `json:"metadata"`
+	Items    []*deployment `json:"items"`
+}
+
+type deployment struct {
+	Metadata *objectMeta `json:"metadata"`
+	Spec     *struct {
+		Replicas *int `json:"replicas"`
+	} `json:"spec"`
+	Status *struct {
+		ReadyReplicas     int `json:"readyReplicas"`
+		AvailableReplicas int `json:"availableReplicas"`
+	} `json:"status"`
+}
+
+type podList struct {
+	Metadata *listMeta `json:"metadata"`
+	Items    []*pod    `json:"items"`
+}
+
+type pod struct {
+	Metadata *objectMeta `json:"metadata"`
+	Status   *struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+// IsConfigured checks whether the kubeconfig contexts to fetch resources from have been set
+func IsConfigured() bool {
+	return len(parseContexts(os.Getenv(contextsEnvVar))) > 0
+}
+
+// New returns a Kubernetes provider that uses the provided configuration instead of the environment
+func New(providerConfig *config.Provider) *Kubernetes {
+	return &Kubernetes{config: providerConfig}
+}
+
+func (k *Kubernetes) GetName() string {
+	return kubernetesProviderName
+}
+
+// Init initializes the provider using the contexts and kubeconfig file in the provider's configuration or,
+// if not configured, the environment
+func (k *Kubernetes) Init() error {
+	contexts := k.config.GetAccounts(contextsEnvVar)
+	if len(contexts) == 0 {
+		return fmt.Errorf("The kubeconfig contexts need to be set in %s or the providers configuration file to use the Kubernetes provider", contextsEnvVar)
+	}
+
+	kubeconfigPath, pathErr := getKubeconfigPath(k.config.GetSetting("kubeconfig", ""))
+	if pathErr != nil {
+		return pathErr
+	}
+	config, configErr := readKubeconfig(kubeconfigPath)
+	if configErr != nil {
+		return configErr
+	}
+
+	clusters := []*cluster{}
+	for _, curContext := range contexts {
+		api, clusterName, apiErr := config.newContextClient(curContext, filepath.Dir(kubeconfigPath))
+		if apiErr != nil {
+			return apiErr
+		}
+		clusters = append(clusters, &cluster{context: curContext, name: clusterName, api: api})
+	}
+	k.init(clusters)
+
+	return nil
+}
+
+func (k *Kubernetes) init(clusters []*cluster) {
+	k.clusters = clusters
+}
+
+func parseContexts(contextsValue string) []string {
+	contexts := []string{}
+	for _, curContext := range strings.Split(contextsValue, contextsSeparator) {
+		curContext = strings.TrimSpace(curContext)
+		if len(curContext) > 0 {
+			contexts = append(contexts, curContext)
+		}
+	}
+
+	return contexts
+}
+
+// GetResources returns the resources in the clusters of the contexts that match the filter. The resources
+// fetched from the other clusters are still returned if some of the clusters fail
+func (k *Kubernetes) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	allResources := []*types.InfraResource{}
+	var failures types.InfraFailures
+	for _, curCluster := range k.clusters {
+		if !filter.ConsiderAccount(curCluster.context) || !filter.ConsiderRegion(curCluster.name) {
+			continue
+		}
+
+		resources, resourcesErr := curCluster.getResources(ctx, filter)
+		if resourcesErr != nil {
+			failures = append(failures, types.ToInfraFailures(resourcesErr, kubernetesProviderName, "", curCluster.context)...)
+		}
+		for _, curResource := range resources {
+			if filter.ConsiderTags(curResource.Tags) {
+				allResources = append(allResources, curResource)
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return allResources, failures
+	}
+
+	return allResources, nil
+}
+
+// getResources returns the cluster's resources of the types in the filter. If listing a type fails, the
+// resources of the types listed before it are returned with the error
+func (c *cluster) getResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	resources := []*types.InfraResource{}
+
+	if filter.ConsiderResourceType(resourceTypeNode) {
+		pageCount, listErr := c.list(ctx, "api/v1/nodes", func() interface{} { return new(nodeList) }, func(page interface{}) string {
+			nodes := page.(*nodeList)
+			for _, curNode := range nodes.Items {
+				resources = append(resources, c.getNodeResource(curNode))
+			}
+			return getContinue(nodes.Metadata)
+		})
+		if listErr != nil {
+			return resources, listErr
+		}
+		notification.SendVerboseMessage(fmt.Sprintf("Fetched Kubernetes nodes from %d pages in %s", pageCount, c.context))
+	}
+
+	if filter.ConsiderResourceType(resourceTypeNamespace) {
+		pageCount, listErr := c.list(ctx, "api/v1/namespaces", func() interface{} { return new(namespaceList) }, func(page interface{}) string {
+			namespaces := page.(*namespaceList)
+			for _, curNamespace := range namespaces.Items {
+				resources = append(resources, c.getNamespaceResource(curNamespace))
+			}
+			return getContinue(namespaces.Metadata)
+		})
+		if listErr != nil {
+			return resources, listErr
+		}
+		notification.SendVerboseMessage(fmt.Sprintf("Fetched Kubernetes namespaces from %d pages in %s", pageCount, c.context))
+	}
+
+	if filter.ConsiderResourceType(resourceTypeDeployment) {
+		pageCount, listErr := c.list(ctx, "apis/apps/v1/deployments", func() interface{} { return new(deploymentList) }, func(page interface{}) string {
+			deployments := page.(*deploymentList)
+			for _, curDeployment := range deployments.Items {
+				resources = append(resources, c.getDeploymentResource(curDeployment))
+			}
+			return getContinue(deployments.Metadata)
+		})
+		if listErr != nil {
+			return resources, listErr
+		}
+		notification.SendVerboseMessage(fmt.Sprintf("Fetched Kubernetes deployments from %d pages in %s", pageCount, c.context))
+	}
+
+	return resources, nil
+}
+
+// list fetches all the pages of the list at the path. newPage returns the value to decode each page into
+// and handlePage processes a page and returns the token to fetch the next page with
+func (c *cluster) list(ctx context.Context, path string, newPage func() interface{}, handlePage func(page interface{}) string) (int, error) {
+	pageCount := 0
+	continueToken := ""
+	for {
+		query := url.Values{"limit": {strconv.Itoa(pageSize)}}
+		if len(continueToken) > 0 {
+			query.Set("continue", continueToken)
+		}
+
+		page := newPage()
+		pageErr := c.api.GetWithContext(ctx, path, query, page)
+		if pageErr != nil {
+			return pageCount, pageErr
+		}
+		pageCount++
+
+		continueToken = handlePage(page)
+		if len(continueToken) == 0 {
+			return pageCount, nil
+		}
+	}
+}
+
+func getContinue(metadata *listMeta) string {
+	if metadata == nil {
+		return ""
+	}
+
+	return metadata.Continue
+}
+
+// newResource returns an InfraResource with the object's name, labels, annotations and creation time
+func (c *cluster) newResource(resourceType string, id string, metadata *objectMeta) *types.InfraResource {
+	resource := &types.InfraResource{
+		Provider:     kubernetesProviderName,
+		ID:           id,
+		Location:     c.name,
+		ResourceType: resourceType,
+		Tags:         make(map[string]string),
+		Properties: map[string]string{
+			"name":            metadata.Name,
+			"uid":             metadata.UID,
+			propertyContext:   c.context,
+			propertyCluster:   c.name,
+			propertyAccountID: c.context,
+			"launch-time":     metadata.CreationTimestamp,
+		},
+	}
+
+	// Labels take precedence over annotations with the same key
+	for curKey, curValue := range metadata.Annotations {
+		if curKey != lastAppliedAnnotation {
+			resource.Tags[curKey] = curValue
+		}
+	}
+	for curKey, curValue := range metadata.Labels {
+		resource.Tags[curKey] = curValue
+	}
+
+	if launchTime, launchTimeErr := time.Parse(time.RFC3339, metadata.CreationTimestamp); launchTimeErr == nil {
+		resource.LaunchTime = launchTime
+	}
+
+	return resource
+}
+
+// getNodeResource converts the provided node into an InfraResource. A node's state is running if it is ready
+func (c *cluster) getNodeResource(node *node) *types.InfraResource {
+	resource := c.newResource(resourceTypeNode, node.Metadata.Name, node.Metadata)
+	resource.Properties[propertyState] = types.ResourceStateStopped
+	resource.Properties["region"] = node.Metadata.Labels["topology.kubernetes.io/region"]
+	resource.Properties["zone"] = node.Metadata.Labels["topology.kubernetes.io/zone"]
+	resource.Properties["instance-type"] = node.Metadata.Labels["node.kubernetes.io/instance-type"]
+
+	if node.Spec != nil {
+		resource.Properties["provider-id"] = node.Spec.ProviderID
+		resource.Properties["unschedulable"] = strconv.FormatBool(node.Spec.Unschedulable)
+	}
+	if node.Status != nil {
+		for _, curCondition := range node.Status.Conditions {
+			if curCondition.Type == "Ready" && curCondition.Status == "True" {
+				resource.Properties[propertyState] = types.ResourceStateRunning
+			}
+		}
+		for _, curAddress := range node.Status.Addresses {
+			switch curAddress.Type {
+			case "InternalIP":
+				resource.Properties["private-ip"] = curAddress.Address
+			case "ExternalIP":
+				resource.Properties["public-ip"] = curAddress.Address
+			}
+		}
+		if node.Status.NodeInfo != nil {
+			resource.Properties["kubelet-version"] = node.Status.NodeInfo.KubeletVersion
+			resource.Properties["os-image"] = node.Status.NodeInfo.OSImage
+		}
+	}
+
+	return resource
+}
+
+// getNamespaceResource converts the provided namespace into an InfraResource
+func (c *cluster) getNamespaceResource(namespace *namespace) *types.InfraResource {
+	resource := c.newResource(resourceTypeNamespace, namespace.Metadata.Name, namespace.Metadata)
+	if namespace.Status != nil {
+		resource.Properties["phase"] = namespace.Status.Phase
+	}
+
+	return resource
+}
+
+// getDeploymentResource converts the provided deployment into an InfraResource. A deployment's state is
+// stopped if it is scaled to zero
+func (c *cluster) getDeploymentResource(deployment *deployment) *types.InfraResource {
+	id := deployment.Metadata.Namespace + deploymentIDSeparator + deployment.Metadata.Name
+	resource := c.newResource(resourceTypeDeployment, id, deployment.Metadata)
+	resource.Properties[propertyNamespace] = deployment.Metadata.Namespace
+
+	replicas := getReplicas(deployment)
+	resource.Properties[propertyReplicas] = strconv.Itoa(replicas)
+	resource.Properties[propertyState] = types.ResourceStateRunning
+	if replicas == 0 {
+		resource.Properties[propertyState] = types.ResourceStateStopped
+	}
+	if deployment.Status != nil {
+		resource.Properties["ready-replicas"] = strconv.Itoa(deployment.Status.ReadyReplicas)
+		resource.Properties["available-replicas"] = strconv.Itoa(deployment.Status.AvailableReplicas)
+	}
+
+	return resource
+}
+
+// getReplicas returns the number of replicas in the deployment's spec, which defaults to 1 if not set
+func getReplicas(deployment *deployment) int {
+	if deployment.Spec == nil || deployment.Spec.Replicas == nil {
+		return 1
+	}
+
+	return *deployment.Spec.Replicas
+}
+
+// getResourceCluster returns the cluster the resource is in
+func (k *Kubernetes) getResourceCluster(resource *types.InfraResource) (*cluster, error) {
+	if resource.Provider != kubernetesProviderName {
+		return nil, fmt.Errorf("Resource's provider is %s instead of %s", resource.Provider, kubernetesProviderName)
+	}
+
+	for _, curCluster := range k.clusters {
+		if curCluster.context == resource.Properties[propertyContext] {
+			return curCluster, nil
+		}
+	}
+
+	return nil, fmt.Errorf("The context '%s' of the Kubernetes resource '%s' is not in %s", resource.Properties[propertyContext], resource.ID, contextsEnvVar)
+}
+
+// getResourcePath returns the API path to the resource's object
+func getResourcePath(resource *types.InfraResource) (string, error) {
+	switch resource.ResourceType {
+	case resourceTypeNode:
+		return "api/v1/nodes/" + url.PathEscape(resource.ID), nil
+	case resourceTypeNamespace:
+		return "api/v1/namespaces/" + url.PathEscape(resource.ID), nil
+	case resourceTypeDeployment:
+		idParts := strings.SplitN(resource.ID, deploymentIDSeparator, 2)
+		if len(idParts) != 2 {
+			return "", fmt.Errorf("The ID of the Kubernetes deployment '%s' is not in the namespace%sname format", resource.ID, deploymentIDSeparator)
+		}
+
+		return fmt.Sprintf("apis/apps/v1/namespaces/%s/deployments/%s", url.PathEscape(idParts[0]), url.PathEscape(idParts[1])), nil
+	}
+
+	return "", fmt.Errorf("Resources of type '%s' are not supported by the %s provider", resource.ResourceType, kubernetesProviderName)
+}
+
+// patch sends the JSON merge patch to the object at the path
+func (c *cluster) patch(path string, patch interface{}, result interface{}) error {
+	patchAPI := *c.api
+	patchAPI.Headers = map[string]string{"Content-Type": "application/merge-patch+json"}
+	for curKey, curValue := range c.api.Headers {
+		patchAPI.Headers[curKey] = curValue
+	}
+
+	return patchAPI.Do("PATCH", path, nil, patch, result)
+}
+
+// UpdateResourceTag sets the value of the object's label. If the tag is one of the object's annotations
+// instead of one of its labels, the annotation is updated. If tagValue is nil, the label and the
+// annotation are removed
+func (k *Kubernetes) UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	cluster, clusterErr := k.getResourceCluster(resource)
+	if clusterErr != nil {
+		return clusterErr
+	}
+	path, pathErr := getResourcePath(resource)
+	if pathErr != nil {
+		return pathErr
+	}
+	if len(*tagKey) == 0 {
+		return fmt.Errorf("Could not update the Kubernetes label because the label key is not set")
+	}
+
+	object := &struct {
+		Metadata *objectMeta `json:"metadata"`
+	}{}
+	getErr := cluster.api.Get(path, nil, object)
+	if getErr != nil {
+		return getErr
+	}
+
+	// In merge patches, null values remove the keys
+	if tagValue == nil {
+		metadataPatch := map[string]interface{}{
+			"labels":      map[string]interface{}{*tagKey: nil},
+			"annotations": map[string]interface{}{*tagKey: nil},
+		}
+
+		return cluster.patch(path, map[string]interface{}{"metadata": metadataPatch}, nil)
+	}
+
+	metadataPatch := map[string]interface{}{"labels": map[string]string{*tagKey: *tagValue}}
+	if object.Metadata != nil {
+		_, isLabel := object.Metadata.Labels[*tagKey]
+		_, isAnnotation := object.Metadata.Annotations[*tagKey]
+		if isAnnotation && !isLabel {
+			metadataPatch = map[string]interface{}{"annotations": map[string]string{*tagKey: *tagValue}}
+		}
+	}
+
+	return cluster.patch(path, map[string]interface{}{"metadata": metadataPatch}, nil)
+}
+
+// UpdateResourceState scales deployments to zero and back up, and deletes namespaces and deployments.
+// Stopping a namespace scales all its deployments to zero. In safe mode, namespaces and deployments are
+// only deleted if all their deployments are scaled to zero. The namespaces created with the cluster and
+// their deployments are never stopped or deleted, even if safe is false
+func (k *Kubernetes) UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	cluster, clusterErr := k.getResourceCluster(resource)
+	if clusterErr != nil {
+		return clusterErr
+	}
+	path, pathErr := getResourcePath(resource)
+	if pathErr != nil {
+		return pathErr
+	}
+	if state != types.ResourceStateRunning {
+		namespace := resource.ID
+		if resource.ResourceType == resourceTypeDeployment {
+			namespace = strings.SplitN(resource.ID, deploymentIDSeparator, 2)[0]
+		}
+		if isSystemNamespace(namespace) {
+			return fmt.Errorf("Refusing to %s the Kubernetes %s '%s' because the namespace '%s' is created with the cluster", getStateAction(state), strings.ToLower(resource.ResourceType), resource.ID, namespace)
+		}
+	}
+
+	switch resource.ResourceType {
+	case resourceTypeDeployment:
+		curDeployment := new(deployment)
+		getErr := cluster.api.Get(path, nil, curDeployment)
+		if getErr != nil {
+			return getErr
+		}
+
+		switch state {
+		case types.ResourceStateStopped:
+			return cluster.scaleDeployment(path, curDeployment, 0)
+		case types.ResourceStateRunning:
+			if getReplicas(curDeployment) > 0 {
+				return nil
+			}
+
+			replicas := 1
+			if savedReplicas, savedErr := strconv.Atoi(curDeployment.Metadata.Annotations[replicasAnnotation]); savedErr == nil && savedReplicas > 0 {
+				replicas = savedReplicas
+			}
+
+			return cluster.scaleDeployment(path, curDeployment, replicas)
+		case types.ResourceStateTerminated:
+			if safe && getReplicas(curDeployment) > 0 {
+				return fmt.Errorf("Refusing to delete the Kubernetes deployment '%s' because it has %d replicas instead of 0", resource.ID, getReplicas(curDeployment))
+			}
+
+			return cluster.api.Do("DELETE", path, nil, nil, nil)
+		}
+	case resourceTypeNamespace:
+		deployments := []*deployment{}
+		deploymentsPath := "apis/apps/v1/namespaces/" + url.PathEscape(resource.ID) + "/deployments"
+		_, listErr := cluster.list(context.Background(), deploymentsPath, func() interface{} { return new(deploymentList) }, func(page interface{}) string {
+			curPage := page.(*deploymentList)
+			deployments = append(deployments, curPage.Items...)
+			return getContinue(curPage.Metadata)
+		})
+		if listErr != nil {
+			return listErr
+		}
+
+		switch state {
+		case types.ResourceStateStopped:
+			for _, curDeployment := range deployments {
+				scaleErr := cluster.scaleDeployment(deploymentsPath+"/"+url.PathEscape(curDeployment.Metadata.Name), curDeployment, 0)
+				if scaleErr != nil {
+					return scaleErr
+				}
+			}
+
+			return nil
+		case types.ResourceStateTerminated:
+			if safe {
+				for _, curDeployment := range deployments {
+					if getReplicas(curDeployment) > 0 {
+						return fmt.Errorf("Refusing to delete the Kubernetes namespace '%s' because its deployment '%s' has %d replicas instead of 0", resource.ID, curDeployment.Metadata.Name, getReplicas(curDeployment))
+					}
+				}
+
+				// Stopping the namespace only scales its deployments, so it can still have pods, e.g. of
+				// StatefulSets, DaemonSets and Jobs
+				activePod, podErr := cluster.getActivePod(resource.ID)
+				if podErr != nil {
+					return podErr
+				}
+				if activePod != nil {
+					return fmt.Errorf("Refusing to delete the Kubernetes namespace '%s' because its pod '%s'%s is %s", resource.ID, activePod.Metadata.Name, getOwnerDescription(activePod.Metadata), strings.ToLower(activePod.Status.Phase))
+				}
+			}
+
+			return cluster.api.Do("DELETE", path, nil, nil, nil)
+		}
+	}
+
+	return fmt.Errorf("Cannot update the state of a Kubernetes %s to '%s'", strings.ToLower(resource.ResourceType), state)
+}
+
+// getActivePod returns one of the namespace's pods that hasn't finished and isn't being deleted, or nil
+// if there is none
+func (c *cluster) getActivePod(namespace string) (*pod, error) {
+	var activePod *pod
+	podsPath := "api/v1/namespaces/" + url.PathEscape(namespace) + "/pods"
+	_, listErr := c.list(context.Background(), podsPath, func() interface{} { return new(podList) }, func(page interface{}) string {
+		curPage := page.(*podList)
+		for _, curPod := range curPage.Items {
+			if activePod == nil && curPod.Status != nil && curPod.Status.Phase != podPhaseSucceeded && curPod.Status.Phase != podPhaseFailed && len(curPod.Metadata.DeletionTimestamp) == 0 {
+				activePod = curPod
+			}
+		}
+		return getContinue(curPage.Metadata)
+	})
+
+	return activePod, listErr
+}
+
+// getOwnerDescription returns the kind and name of the object's owner, as used in error messages
+func getOwnerDescription(metadata *objectMeta) string {
+	if len(metadata.OwnerReferences) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%s '%s')", metadata.OwnerReferences[0].Kind, metadata.OwnerReferences[0].Name)
+}
+
+// isSystemNamespace checks whether the namespace is one of the namespaces created with the cluster
+func isSystemNamespace(namespace string) bool {
+	return namespace == defaultNamespace || strings.HasPrefix(namespace, systemNamespacePrefix)
+}
+
+// getStateAction returns the action taken to update a resource to the state, as used in error messages
+func getStateAction(state string) string {
+	if state == types.ResourceStateTerminated {
+		return "delete"
+	}
+
+	return "stop"
+}
+
+// scaleDeployment sets the number of replicas of the deployment. The current number of replicas is saved
+// in an annotation when the deployment is scaled to zero
+func (c *cluster) scaleDeployment(path string, deployment *deployment, replicas int) error {
+	curReplicas := getReplicas(deployment)
+	if curReplicas == replicas {
+		return nil
+	}
+
+	metadataPatch := map[string]interface{}{}
+	if replicas == 0 {
+		metadataPatch["annotations"] = map[string]string{replicasAnnotation: strconv.Itoa(curReplicas)}
+	}
+
+	return c.patch(path, map[string]interface{}{
+		"metadata": metadataPatch,
+		"spec":     map[string]int{"replicas": replicas},
+	}, nil)
+}
+
+// GetCostsAndUsages returns no costs since the Kubernetes API doesn't have billing data
+func (k *Kubernetes) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	return &types.CostAndUsageOutput{
+		Provider: kubernetesProviderName,
+		Groups:   make(map[string]float64),
+		Period: &types.CostAndUsagePeriod{
+			StartDate: filter.StartDate,
+			EndDate:   filter.EndDate,
+		},
+	}, nil
+}
@@ -0,0 +1,336 @@
+package kubernetes
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+	"github.com/onaio/sre-tooling/libs/infra/rest/resttest"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// fakeAPI is a local fake of the parts of the Kubernetes API used by the provider
+type fakeAPI struct {
+	server        *httptest.Server
+	patches       map[string]map[string]interface{}
+	patchTypes    []string
+	deletedPaths  []string
+	previewScaled bool
+	statefulSet   bool
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{patches: make(map[string]map[string]interface{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/nodes", func(w http.ResponseWriter, r *http.Request) {
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{
+				"metadata": getMetadata("node-1", "", map[string]string{"topology.kubernetes.io/region": "eu-west-1"}),
+				"status": map[string]interface{}{
+					"conditions": []interface{}{map[string]string{"type": "Ready", "status": "True"}},
+					"addresses":  []interface{}{map[string]string{"type": "ExternalIP", "address": "1.2.3.4"}},
+				},
+			}},
+		})
+	})
+	mux.HandleFunc("/api/v1/namespaces", func(w http.ResponseWriter, r *http.Request) {
+		// Return the namespaces across two pages to test pagination
+		if r.URL.Query().Get("continue") == "" {
+			resttest.WriteJSON(t, w, map[string]interface{}{
+				"metadata": map[string]string{"continue": "page-2"},
+				"items":    []interface{}{map[string]interface{}{"metadata": getMetadata("default", "", nil)}},
+			})
+			return
+		}
+
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"metadata": getMetadata("preview-1", "", map[string]string{"owner": "alice"})}},
+		})
+	})
+	mux.HandleFunc("/apis/apps/v1/deployments", func(w http.ResponseWriter, r *http.Request) {
+		resttest.WriteJSON(t, w, map[string]interface{}{
+			"items": []interface{}{getDeployment("web", 2)},
+		})
+	})
+	mux.HandleFunc("/apis/apps/v1/namespaces/preview-1/deployments", func(w http.ResponseWriter, r *http.Request) {
+		replicas := 2
+		if api.previewScaled {
+			replicas = 0
+		}
+		resttest.WriteJSON(t, w, map[string]interface{}{"items": []interface{}{getDeployment("web", replicas)}})
+	})
+	mux.HandleFunc("/api/v1/namespaces/preview-1/pods", func(w http.ResponseWriter, r *http.Request) {
+		// The deployment's pod is being deleted once the deployment is scaled to zero
+		webPod := getPod("web-1", "Running", "ReplicaSet", "web-1234")
+		if api.previewScaled {
+			webPod["metadata"].(map[string]interface{})["deletionTimestamp"] = "2020-05-02T10:00:00Z"
+		}
+		pods := []interface{}{webPod, getPod("migrate-1", "Succeeded", "Job", "migrate")}
+		if api.statefulSet {
+			pods = append(pods, getPod("db-0", "Running", "StatefulSet", "db"))
+		}
+		resttest.WriteJSON(t, w, map[string]interface{}{"items": pods})
+	})
+	mux.HandleFunc("/apis/apps/v1/namespaces/preview-1/deployments/web", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			if !api.recordPatch(t, w, r) {
+				return
+			}
+			api.previewScaled = true
+		}
+		resttest.WriteJSON(t, w, getDeployment("web", 2))
+	})
+	mux.HandleFunc("/api/v1/namespaces/preview-1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PATCH":
+			if !api.recordPatch(t, w, r) {
+				return
+			}
+		case "DELETE":
+			api.deletedPaths = append(api.deletedPaths, r.URL.Path)
+		}
+		resttest.WriteJSON(t, w, map[string]interface{}{"metadata": getMetadata("preview-1", "", map[string]string{"owner": "alice"})})
+	})
+	api.server = httptest.NewServer(mux)
+
+	return api
+}
+
+// recordPatch records the patch in the request. false is returned if the patch can't be parsed
+func (api *fakeAPI) recordPatch(t *testing.T, w http.ResponseWriter, r *http.Request) bool {
+	patch := make(map[string]interface{})
+	if !resttest.ReadJSON(t, w, r, &patch) {
+		return false
+	}
+	api.patches[r.URL.Path] = patch
+	api.patchTypes = append(api.patchTypes, r.Header.Get("Content-Type"))
+
+	return true
+}
+
+func getMetadata(name string, namespace string, labels map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":              name,
+		"namespace":         namespace,
+		"creationTimestamp": "2020-05-01T10:00:00Z",
+		"labels":            labels,
+		"annotations":       map[string]string{"description": "test", lastAppliedAnnotation: "{}"},
+	}
+}
+
+func getDeployment(name string, replicas int) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": getMetadata(name, "preview-1", map[string]string{"app": name}),
+		"spec":     map[string]int{"replicas": replicas},
+		"status":   map[string]int{"readyReplicas": replicas},
+	}
+}
+
+func getPod(name string, phase string, ownerKind string, ownerName string) map[string]interface{} {
+	metadata := getMetadata(name, "preview-1", nil)
+	metadata["ownerReferences"] = []interface{}{map[string]string{"kind": ownerKind, "name": ownerName}}
+
+	return map[string]interface{}{
+		"metadata": metadata,
+		"status":   map[string]string{"phase": phase},
+	}
+}
+
+func (api *fakeAPI) getProvider() *Kubernetes {
+	provider := new(Kubernetes)
+	provider.init([]*cluster{&cluster{context: "test-context", name: "test-cluster", api: rest.NewClient(api.server.URL, nil)}})
+
+	return provider
+}
+
+// Test whether GetResources maps nodes, namespaces and deployments to resources
+func TestGetResources(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 4 {
+		t.Fatalf("Expecting 4 resources; got %d and error %v", len(resources), resourcesErr)
+	}
+
+	node := resources[0]
+	if node.ResourceType != resourceTypeNode || node.Properties[propertyState] != types.ResourceStateRunning || node.Properties["public-ip"] != "1.2.3.4" {
+		t.Errorf("Unexpected node %+v", node)
+	}
+	if node.Tags["description"] != "test" || len(node.Tags[lastAppliedAnnotation]) > 0 {
+		t.Errorf("Expecting annotations other than the last applied configuration to be tags; got %v", node.Tags)
+	}
+	if node.LaunchTime.IsZero() || node.Location != "test-cluster" {
+		t.Errorf("Expecting the launch time and location to be set; got %+v", node)
+	}
+
+	deployment := resources[3]
+	if deployment.ID != "preview-1/web" || deployment.Properties[propertyReplicas] != "2" || deployment.Tags["app"] != "web" {
+		t.Errorf("Unexpected deployment %+v", deployment)
+	}
+
+	namespaces, namespacesErr := provider.GetResources(context.Background(), &types.InfraFilter{ResourceTypes: []string{"Namespace"}, Tags: map[string]string{"owner": "alice"}})
+	if namespacesErr != nil || len(namespaces) != 1 || namespaces[0].ID != "preview-1" {
+		t.Errorf("Expecting only the preview-1 namespace; got %v and error %v", namespaces, namespacesErr)
+	}
+}
+
+// Test whether the resources of the healthy clusters are returned with the failure of a cluster that
+// can't be reached
+func TestGetResourcesWithFailedCluster(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	provider := new(Kubernetes)
+	provider.init([]*cluster{
+		&cluster{context: "failing-context", name: "failing-cluster", api: rest.NewClient(failing.URL, nil)},
+		&cluster{context: "test-context", name: "test-cluster", api: rest.NewClient(api.server.URL, nil)},
+	})
+
+	resources, resourcesErr := provider.GetResources(context.Background(), &types.InfraFilter{})
+	failures, isFailures := resourcesErr.(types.InfraFailures)
+	if !isFailures || len(failures) != 1 || failures[0].Region != "failing-context" || failures[0].Provider != kubernetesProviderName {
+		t.Fatalf("Expecting the failure of the failing context; got %v", resourcesErr)
+	}
+	if len(resources) != 4 {
+		t.Errorf("Expecting the 4 resources of the healthy cluster; got %d", len(resources))
+	}
+}
+
+// Test whether UpdateResourceTag patches the object's labels
+func TestUpdateResourceTag(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	tagKey := "EndDate"
+	tagValue := "2020-06-01"
+	resource := &types.InfraResource{Provider: kubernetesProviderName, ResourceType: resourceTypeNamespace, ID: "preview-1", Properties: map[string]string{propertyContext: "test-context"}}
+	updateErr := provider.UpdateResourceTag(resource, &tagKey, &tagValue)
+	if updateErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", updateErr)
+	}
+
+	patch := api.patches["/api/v1/namespaces/preview-1"]
+	labels, _ := patch["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+	if labels["EndDate"] != "2020-06-01" {
+		t.Errorf("Expecting the label to be patched; got %v", patch)
+	}
+	if len(api.patchTypes) != 1 || api.patchTypes[0] != "application/merge-patch+json" {
+		t.Errorf("Expecting a JSON merge patch; got %v", api.patchTypes)
+	}
+}
+
+// Test whether stopping a namespace scales its deployments to zero, that it's then deleted in safe mode and
+// that the namespaces created with the cluster are never deleted
+func TestUpdateResourceState(t *testing.T) {
+	api := newFakeAPI(t)
+	defer api.server.Close()
+	provider := api.getProvider()
+
+	resource := &types.InfraResource{Provider: kubernetesProviderName, ResourceType: resourceTypeNamespace, ID: "preview-1", Properties: map[string]string{propertyContext: "test-context"}}
+	deleteErr := provider.UpdateResourceState(resource, true, types.ResourceStateTerminated)
+	if deleteErr == nil || len(api.deletedPaths) > 0 {
+		t.Fatalf("Expecting an error, and the namespace not to be deleted, when its deployments have replicas")
+	}
+
+	stopErr := provider.UpdateResourceState(resource, true, types.ResourceStateStopped)
+	if stopErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", stopErr)
+	}
+	patch := api.patches["/apis/apps/v1/namespaces/preview-1/deployments/web"]
+	annotations, _ := patch["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	if patch["spec"].(map[string]interface{})["replicas"] != 0.0 || annotations[replicasAnnotation] != "2" {
+		t.Errorf("Expecting the deployment to be scaled to zero and its replicas to be saved; got %v", patch)
+	}
+
+	api.statefulSet = true
+	deleteErr = provider.UpdateResourceState(resource, true, types.ResourceStateTerminated)
+	if deleteErr == nil || !strings.Contains(deleteErr.Error(), "db-0") || len(api.deletedPaths) > 0 {
+		t.Errorf("Expecting an error, and the namespace not to be deleted, when a StatefulSet's pod is running; got error %v", deleteErr)
+	}
+
+	api.statefulSet = false
+	deleteErr = provider.UpdateResourceState(resource, true, types.ResourceStateTerminated)
+	if deleteErr != nil || len(api.deletedPaths) != 1 {
+		t.Errorf("Expecting the namespace to be deleted; got error %v", deleteErr)
+	}
+
+	for _, curID := range []string{"kube-public", "default"} {
+		systemResource := &types.InfraResource{Provider: kubernetesProviderName, ResourceType: resourceTypeNamespace, ID: curID, Properties: map[string]string{propertyContext: "test-context"}}
+		systemErr := provider.UpdateResourceState(systemResource, false, types.ResourceStateTerminated)
+		if systemErr == nil || len(api.deletedPaths) != 1 {
+			t.Errorf("Expecting the namespace '%s' not to be deleted, even in unsafe mode; got error %v", curID, systemErr)
+		}
+	}
+}
+
+// Test whether clients are created from the context's cluster and user in the kubeconfig file
+func TestNewContextClient(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "kubeconfig")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenErr := ioutil.WriteFile(filepath.Join(dir, "token"), []byte("test-token\n"), 0600)
+	if tokenErr != nil {
+		t.Fatalf("Could not write the token file: %v", tokenErr)
+	}
+
+	path := filepath.Join(dir, "config")
+	writeErr := ioutil.WriteFile(path, []byte(`
+clusters:
+- name: test-cluster
+  cluster:
+    server: https://127.0.0.1:6443
+    insecure-skip-tls-verify: true
+users:
+- name: test-user
+  user:
+    tokenFile: token
+contexts:
+- name: test-context
+  context:
+    cluster: test-cluster
+    user: test-user
+`), 0600)
+	if writeErr != nil {
+		t.Fatalf("Could not write the kubeconfig file: %v", writeErr)
+	}
+
+	config, configErr := readKubeconfig(path)
+	if configErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", configErr)
+	}
+
+	client, clusterName, clientErr := config.newContextClient("test-context", dir)
+	if clientErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", clientErr)
+	}
+	if clusterName != "test-cluster" || client.BaseURL != "https://127.0.0.1:6443" {
+		t.Errorf("Unexpected cluster '%s' with server '%s'", clusterName, client.BaseURL)
+	}
+
+	req, _ := http.NewRequest("GET", client.BaseURL, nil)
+	authErr := client.Authorize(req)
+	if authErr != nil || req.Header.Get("Authorization") != "Bearer test-token" {
+		t.Errorf("Expecting the token in the token file to be used; got '%s' and error %v", req.Header.Get("Authorization"), authErr)
+	}
+
+	_, _, missingErr := config.newContextClient("other-context", dir)
+	if missingErr == nil {
+		t.Errorf("Expecting an error for a context that is not in the kubeconfig file")
+	}
+}
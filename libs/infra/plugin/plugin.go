@@ -0,0 +1,200 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/onaio/sre-tooling/libs/notification"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// ExecutablePrefix is the prefix of the names of the executables, on PATH, that are provider plugins.
+// The rest of the executable's name is the provider's name
+const ExecutablePrefix string = "sre-tooling-provider-"
+
+// ProtocolVersion is the version of the requests sent to, and responses expected from, plugins
+const ProtocolVersion = 1
+
+// Methods that can be set in a plugin request
+const (
+	MethodGetResources        string = "GetResources"
+	MethodGetCostsAndUsages   string = "GetCostsAndUsages"
+	MethodUpdateResourceTag   string = "UpdateResourceTag"
+	MethodUpdateResourceState string = "UpdateResourceState"
+)
+
+// Request is written, JSON encoded, to the plugin's stdin. Only the fields used by the method are set
+type Request struct {
+	Version    int
+	Method     string
+	Filter     *types.InfraFilter        `json:",omitempty"`
+	CostFilter *types.CostAndUsageFilter `json:",omitempty"`
+	Resource   *types.InfraResource      `json:",omitempty"`
+	TagKey     *string                   `json:",omitempty"`
+	TagValue   *string                   `json:",omitempty"`
+	Safe       bool                      `json:",omitempty"`
+	State      string                    `json:",omitempty"`
+}
+
+// Response is read, JSON encoded, from the plugin's stdout. A plugin that fails sets Error
+type Response struct {
+	Resources []*types.InfraResource
+	Costs     *types.CostAndUsageOutput
+	Error     string
+}
+
+// Plugin is a provider implemented by an external executable. The executable is run for each call, with
+// the Request on its stdin, and writes the Response to its stdout
+type Plugin struct {
+	name string
+	path string
+}
+
+// Discover returns the provider plugins on PATH. If executables with the same name are in several
+// directories, the first one on PATH is used
+func Discover() []*Plugin {
+	plugins := []*Plugin{}
+	names := make(map[string]bool)
+	for _, curDir := range filepath.SplitList(os.Getenv("PATH")) {
+		if len(curDir) == 0 {
+			continue
+		}
+
+		files, filesErr := ioutil.ReadDir(curDir)
+		if filesErr != nil {
+			continue
+		}
+
+		for _, curFile := range files {
+			name := getPluginName(curFile)
+			if len(name) == 0 || names[name] {
+				continue
+			}
+
+			names[name] = true
+			plugins = append(plugins, &Plugin{name: name, path: filepath.Join(curDir, curFile.Name())})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].name < plugins[j].name })
+
+	return plugins
+}
+
+// getPluginName returns the name of the provider if the file is a provider plugin, or an empty string otherwise
+func getPluginName(file os.FileInfo) string {
+	if !file.Mode().IsRegular() || file.Mode().Perm()&0111 == 0 || !strings.HasPrefix(file.Name(), ExecutablePrefix) {
+		return ""
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(file.Name(), ExecutablePrefix), ".exe")
+}
+
+func (p *Plugin) GetName() string {
+	return p.name
+}
+
+// Init checks that the plugin's executable still exists
+func (p *Plugin) Init() error {
+	_, statErr := os.Stat(p.path)
+	return statErr
+}
+
+// call runs the plugin's executable with the request and returns its response. The plugin is killed if
+// ctx is done before it exits
+func (p *Plugin) call(ctx context.Context, request *Request) (*Response, error) {
+	request.Version = ProtocolVersion
+	requestJSON, requestErr := json.Marshal(request)
+	if requestErr != nil {
+		return nil, requestErr
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Stdin = bytes.NewReader(requestJSON)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if stderr.Len() > 0 {
+		notification.SendVerboseMessage(fmt.Sprintf("%s provider plugin: %s", p.name, strings.TrimSpace(stderr.String())))
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("The %s provider plugin failed to run %s: %v: %s", p.name, request.Method, runErr, strings.TrimSpace(stderr.String()))
+	}
+
+	response := new(Response)
+	unmarshalErr := json.Unmarshal(stdout.Bytes(), response)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("Could not parse the response of the %s provider plugin to %s: %v", p.name, request.Method, unmarshalErr)
+	}
+	if len(response.Error) > 0 {
+		return nil, fmt.Errorf("The %s provider plugin failed to run %s: %s", p.name, request.Method, response.Error)
+	}
+
+	return response, nil
+}
+
+func (p *Plugin) GetResources(ctx context.Context, filter *types.InfraFilter) ([]*types.InfraResource, error) {
+	response, responseErr := p.call(ctx, &Request{Method: MethodGetResources, Filter: filter})
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	// Updates to the resources are routed to the provider with the resource's provider name
+	resources := []*types.InfraResource{}
+	for _, curResource := range response.Resources {
+		if curResource == nil {
+			continue
+		}
+
+		curResource.Provider = p.name
+		if curResource.Tags == nil {
+			curResource.Tags = make(map[string]string)
+		}
+		if curResource.Properties == nil {
+			curResource.Properties = make(map[string]string)
+		}
+		resources = append(resources, curResource)
+	}
+
+	return resources, nil
+}
+
+func (p *Plugin) GetCostsAndUsages(filter *types.CostAndUsageFilter) (*types.CostAndUsageOutput, error) {
+	response, responseErr := p.call(context.Background(), &Request{Method: MethodGetCostsAndUsages, CostFilter: filter})
+	if responseErr != nil {
+		return nil, responseErr
+	}
+
+	costs := response.Costs
+	if costs == nil {
+		costs = new(types.CostAndUsageOutput)
+	}
+	costs.Provider = p.name
+	if costs.Groups == nil {
+		costs.Groups = make(map[string]float64)
+	}
+	if costs.Period == nil {
+		costs.Period = &types.CostAndUsagePeriod{StartDate: filter.StartDate, EndDate: filter.EndDate}
+	}
+
+	return costs, nil
+}
+
+func (p *Plugin) UpdateResourceTag(resource *types.InfraResource, tagKey *string, tagValue *string) error {
+	_, responseErr := p.call(context.Background(), &Request{Method: MethodUpdateResourceTag, Resource: resource, TagKey: tagKey, TagValue: tagValue})
+	return responseErr
+}
+
+func (p *Plugin) UpdateResourceState(resource *types.InfraResource, safe bool, state string) error {
+	_, responseErr := p.call(context.Background(), &Request{Method: MethodUpdateResourceState, Resource: resource, Safe: safe, State: state})
+	return responseErr
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const testPluginScript = `#!/bin/sh
+request=$(cat)
+case "$request" in
+  *'"Method":"GetResources"'*)
+    echo '{"Resources": [{"Provider": "other", "ID": "vm-1", "ResourceType": "VM", "Location": "lab", "Tags": {"owner": "alice"}}]}';;
+  *'"Method":"GetCostsAndUsages"'*)
+    echo '{"Costs": {"Groups": {"Total": 12.5}}}';;
+  *'"Method":"UpdateResourceTag"'*)
+    echo "$request" > "$(dirname "$0")/last-request"
+    echo '{}';;
+  *'"Method":"UpdateResourceState"'*)
+    echo '{"Error": "VMs in the lab cannot be stopped"}';;
+  *)
+    echo 'unknown method' >&2
+    exit 1;;
+esac
+`
+
+// setUpPlugins writes a test plugin, and a file that isn't executable, to a directory that is set as PATH
+func setUpPlugins(t *testing.T) (string, func()) {
+	dir, dirErr := ioutil.TempDir("", "plugins")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+
+	pluginErr := ioutil.WriteFile(filepath.Join(dir, ExecutablePrefix+"lab"), []byte(testPluginScript), 0755)
+	if pluginErr != nil {
+		t.Fatalf("Could not write the test plugin: %v", pluginErr)
+	}
+	notExecutableErr := ioutil.WriteFile(filepath.Join(dir, ExecutablePrefix+"disabled"), []byte(testPluginScript), 0644)
+	if notExecutableErr != nil {
+		t.Fatalf("Could not write the test plugin: %v", notExecutableErr)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	return dir, func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func discoverTestPlugin(t *testing.T) *Plugin {
+	plugins := Discover()
+	if len(plugins) != 1 || plugins[0].GetName() != "lab" {
+		t.Fatalf("Expecting only the lab plugin to be discovered; got %v", plugins)
+	}
+
+	return plugins[0]
+}
+
+// Test whether resources and costs returned by plugins are set as being from the plugin's provider
+func TestGetResourcesAndCosts(t *testing.T) {
+	_, cleanup := setUpPlugins(t)
+	defer cleanup()
+	plugin := discoverTestPlugin(t)
+
+	resources, resourcesErr := plugin.GetResources(context.Background(), &types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 resource; got %d and error %v", len(resources), resourcesErr)
+	}
+	if resources[0].Provider != "lab" || resources[0].ID != "vm-1" || resources[0].Tags["owner"] != "alice" || resources[0].Properties == nil {
+		t.Errorf("Unexpected resource %+v", resources[0])
+	}
+
+	costs, costsErr := plugin.GetCostsAndUsages(&types.CostAndUsageFilter{StartDate: "2020-05-01", EndDate: "2020-06-01"})
+	if costsErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", costsErr)
+	}
+	if costs.Provider != "lab" || costs.Groups["Total"] != 12.5 || costs.Period.StartDate != "2020-05-01" {
+		t.Errorf("Unexpected costs %+v", costs)
+	}
+}
+
+// Test whether updates are sent to the plugin and errors returned by the plugin are returned
+func TestUpdateResource(t *testing.T) {
+	dir, cleanup := setUpPlugins(t)
+	defer cleanup()
+	plugin := discoverTestPlugin(t)
+
+	tagKey := "EndDate"
+	tagValue := "2020-06-01"
+	resource := &types.InfraResource{Provider: "lab", ID: "vm-1", ResourceType: "VM"}
+	tagErr := plugin.UpdateResourceTag(resource, &tagKey, &tagValue)
+	if tagErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", tagErr)
+	}
+
+	requestJSON, readErr := ioutil.ReadFile(filepath.Join(dir, "last-request"))
+	if readErr != nil {
+		t.Fatalf("Expecting the plugin to have received the request; got %v", readErr)
+	}
+	request := new(Request)
+	unmarshalErr := json.Unmarshal(requestJSON, request)
+	if unmarshalErr != nil {
+		t.Fatalf("Could not parse the request: %v", unmarshalErr)
+	}
+	if request.Version != ProtocolVersion || request.Resource.ID != "vm-1" || *request.TagKey != tagKey || *request.TagValue != tagValue {
+		t.Errorf("Unexpected request %s", requestJSON)
+	}
+
+	stateErr := plugin.UpdateResourceState(resource, true, types.ResourceStateStopped)
+	if stateErr == nil || !strings.Contains(stateErr.Error(), "cannot be stopped") {
+		t.Errorf("Expecting the plugin's error to be returned; got %v", stateErr)
+	}
+}
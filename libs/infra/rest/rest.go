@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/infra/retry"
+)
+
+const defaultTimeout = 60 * time.Second
+
+// Authorizer adds the credentials to a request before it is sent
+type Authorizer func(req *http.Request) error
+
+// Client is a minimal JSON REST client used by the providers that talk to their cloud's REST API
+// directly instead of through an SDK
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Authorize  Authorizer
+	Headers    map[string]string
+}
+
+// Error is returned when the API responds with a non-2xx status code
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s returned status %d: %s", e.Method, e.URL, e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// NewClient returns a client that sends requests to paths relative to baseURL
+func NewClient(baseURL string, authorize Authorizer) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+		Authorize:  authorize,
+		Headers:    map[string]string{},
+	}
+}
+
+// Get sends a GET request to the path and decodes the JSON response into result
+func (c *Client) Get(path string, query url.Values, result interface{}) error {
+	return c.GetWithContext(context.Background(), path, query, result)
+}
+
+// GetWithContext is like Get but the request is sent using the provided context
+func (c *Client) GetWithContext(ctx context.Context, path string, query url.Values, result interface{}) error {
+	return c.DoWithContext(ctx, http.MethodGet, path, query, nil, result)
+}
+
+// Post sends the JSON encoded body in a POST request to the path and decodes the JSON response into result
+func (c *Client) Post(path string, query url.Values, body interface{}, result interface{}) error {
+	return c.DoWithContext(context.Background(), http.MethodPost, path, query, body, result)
+}
+
+// Do sends a request to the path, which can also be an absolute URL. body, if not nil, is encoded as JSON.
+// The JSON response is decoded into result if result isn't nil
+func (c *Client) Do(method string, path string, query url.Values, body interface{}, result interface{}) error {
+	return c.DoWithContext(context.Background(), method, path, query, body, result)
+}
+
+// DoWithContext is like Do but the request is sent using the provided context. Requests that are throttled
+// are retried, and so are requests with idempotent methods that fail with a 5xx status code
+func (c *Client) DoWithContext(ctx context.Context, method string, path string, query url.Values, body interface{}, result interface{}) error {
+	requestURL := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		requestURL = c.BaseURL + "/" + strings.TrimLeft(path, "/")
+	}
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(requestURL, "?") {
+			separator = "&"
+		}
+		requestURL = requestURL + separator + query.Encode()
+	}
+
+	var bodyJSON []byte
+	if body != nil {
+		var bodyErr error
+		bodyJSON, bodyErr = json.Marshal(body)
+		if bodyErr != nil {
+			return bodyErr
+		}
+	}
+
+	isRetryable := func(err error) bool {
+		restErr, ok := err.(*Error)
+		if !ok || !retry.IsRetryableStatus(restErr.StatusCode) {
+			return false
+		}
+
+		// The API might have handled a non-idempotent request before failing
+		return restErr.StatusCode == http.StatusTooManyRequests || isIdempotent(method)
+	}
+
+	return retry.Do(ctx, isRetryable, func(ctx context.Context) error {
+		return c.send(ctx, method, requestURL, bodyJSON, result)
+	})
+}
+
+// send sends a single request to the URL
+func (c *Client) send(ctx context.Context, method string, requestURL string, bodyJSON []byte, result interface{}) error {
+	var bodyReader io.Reader
+	if bodyJSON != nil {
+		bodyReader = bytes.NewReader(bodyJSON)
+	}
+
+	req, reqErr := http.NewRequest(method, requestURL, bodyReader)
+	if reqErr != nil {
+		return reqErr
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if bodyJSON != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for curKey, curValue := range c.Headers {
+		req.Header.Set(curKey, curValue)
+	}
+
+	if c.Authorize != nil {
+		authErr := c.Authorize(req)
+		if authErr != nil {
+			return authErr
+		}
+	}
+
+	resp, respErr := c.HTTPClient.Do(req)
+	if respErr != nil {
+		return respErr
+	}
+	defer resp.Body.Close()
+
+	respBody, respBodyErr := ioutil.ReadAll(resp.Body)
+	if respBodyErr != nil {
+		return respBodyErr
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &Error{Method: method, URL: requestURL, StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+
+	unmarshalErr := json.Unmarshal(respBody, result)
+	if unmarshalErr != nil {
+		return fmt.Errorf("Could not parse the response from %s %s: %v", method, requestURL, unmarshalErr)
+	}
+
+	return nil
+}
+
+// isIdempotent checks whether sending a request with the method more than once has the same effect as
+// sending it once
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
+
+// BearerToken returns an Authorizer that adds the token returned by getToken in the Authorization header
+func BearerToken(getToken func() (string, error)) Authorizer {
+	return func(req *http.Request) error {
+		token, tokenErr := getToken()
+		if tokenErr != nil {
+			return tokenErr
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
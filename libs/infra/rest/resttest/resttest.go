@@ -0,0 +1,58 @@
+// Package resttest has helpers for testing the providers that use rest.Client against local fakes of
+// their cloud's REST API
+package resttest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/infra/rest"
+)
+
+// Token is the bearer token the fake APIs expect
+const Token = "test-token"
+
+// Authorize adds Token to the requests sent by the providers under test
+var Authorize = rest.BearerToken(func() (string, error) { return Token, nil })
+
+// IsAuthorized checks whether the request has Token. If not, a 401 response is written
+func IsAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "Bearer "+Token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// WriteJSON writes the value as the JSON response of a fake API handler
+func WriteJSON(t *testing.T, w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encodeErr := json.NewEncoder(w).Encode(value)
+	if encodeErr != nil {
+		t.Errorf("Could not encode the fake response: %v", encodeErr)
+	}
+}
+
+// ReadJSON parses the JSON request body into value. Since handlers don't run in the test's goroutine,
+// the test is marked as failed without being stopped, and a 400 response is written, if the body can't
+// be parsed. The handler should return if false is returned
+func ReadJSON(t *testing.T, w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	body, bodyErr := ioutil.ReadAll(r.Body)
+	if bodyErr != nil {
+		t.Errorf("Could not read the request body: %v", bodyErr)
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+
+	unmarshalErr := json.Unmarshal(body, value)
+	if unmarshalErr != nil {
+		t.Errorf("Could not parse the request body: %v", unmarshalErr)
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
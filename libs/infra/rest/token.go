@@ -0,0 +1,94 @@
+package rest
+
+// This file contains the caching of the OAuth 2.0 access tokens used by the providers that authenticate
+// using their cloud's token endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before a token expires it is refreshed
+const tokenExpiryMargin = time.Minute
+
+// TokenSource returns access tokens, either a static one or ones requested from a token endpoint. Requested
+// tokens are reused until they are about to expire
+type TokenSource struct {
+	mutex       sync.Mutex
+	staticToken string
+	tokenURL    string
+	getForm     func() (url.Values, error)
+	token       string
+	expiry      time.Time
+	httpClient  *http.Client
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// NewStaticTokenSource returns a token source that always returns the provided token
+func NewStaticTokenSource(token string) *TokenSource {
+	return &TokenSource{staticToken: token}
+}
+
+// NewTokenSource returns a token source that requests tokens by posting the form returned by getForm
+// to tokenURL
+func NewTokenSource(tokenURL string, getForm func() (url.Values, error)) *TokenSource {
+	return &TokenSource{
+		tokenURL:   tokenURL,
+		getForm:    getForm,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// GetToken returns a valid access token, requesting a new one if the current one is about to expire
+func (ts *TokenSource) GetToken() (string, error) {
+	if len(ts.staticToken) > 0 {
+		return ts.staticToken, nil
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if len(ts.token) > 0 && time.Now().Add(tokenExpiryMargin).Before(ts.expiry) {
+		return ts.token, nil
+	}
+
+	form, formErr := ts.getForm()
+	if formErr != nil {
+		return "", formErr
+	}
+
+	resp, respErr := ts.httpClient.PostForm(ts.tokenURL, form)
+	if respErr != nil {
+		return "", respErr
+	}
+	defer resp.Body.Close()
+
+	respBody, respBodyErr := ioutil.ReadAll(resp.Body)
+	if respBodyErr != nil {
+		return "", respBodyErr
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Could not get an access token from %s. Status is %d: %s", ts.tokenURL, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	token := new(tokenResponse)
+	unmarshalErr := json.Unmarshal(respBody, token)
+	if unmarshalErr != nil {
+		return "", unmarshalErr
+	}
+
+	ts.token = token.AccessToken
+	ts.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return ts.token, nil
+}
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// Test whether tokens are reused until they are about to expire
+func TestGetToken(t *testing.T) {
+	requests := 0
+	expiresIn := 3600
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.PostFormValue("grant_type") != "client_credentials" {
+			t.Errorf("Expecting the form to be posted; got %v", r.PostForm)
+		}
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": %d}`, requests, expiresIn)
+	}))
+	defer server.Close()
+
+	ts := NewTokenSource(server.URL, func() (url.Values, error) {
+		return url.Values{"grant_type": {"client_credentials"}}, nil
+	})
+	for i := 0; i < 2; i++ {
+		token, tokenErr := ts.GetToken()
+		if tokenErr != nil || token != "token-1" || requests != 1 {
+			t.Fatalf("Expecting the first token to be reused; got '%s' after %d requests and error %v", token, requests, tokenErr)
+		}
+	}
+
+	// Request a token that expires within the expiry margin
+	ts = NewTokenSource(server.URL, func() (url.Values, error) {
+		return url.Values{"grant_type": {"client_credentials"}}, nil
+	})
+	expiresIn = 30
+	ts.GetToken()
+	token, tokenErr := ts.GetToken()
+	if tokenErr != nil || token != "token-3" {
+		t.Errorf("Expecting a token that is about to expire to be replaced; got '%s' and error %v", token, tokenErr)
+	}
+
+	static, staticErr := NewStaticTokenSource("static-token").GetToken()
+	if staticErr != nil || static != "static-token" {
+		t.Errorf("Expecting the static token; got '%s' and error %v", static, staticErr)
+	}
+}
@@ -0,0 +1,95 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/notification"
+)
+
+// Policy is how calls to the providers' APIs are timed out and retried
+type Policy struct {
+	// MaxAttempts is the maximum number of times a call is made, including the first attempt
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It doubles after every retry, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Timeout is how long each attempt has to complete. Attempts aren't timed out if it is not set
+	Timeout time.Duration
+}
+
+// DefaultPolicy is the policy used by Do
+var DefaultPolicy = &Policy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    20 * time.Second,
+	Timeout:     time.Minute,
+}
+
+// Do calls call using DefaultPolicy
+func Do(ctx context.Context, isRetryable func(error) bool, call func(ctx context.Context) error) error {
+	return DefaultPolicy.Do(ctx, isRetryable, call)
+}
+
+// Do calls call until it succeeds, it returns an error isRetryable doesn't consider retryable (e.g. not a
+// throttling or 5xx error), or MaxAttempts is reached. The last error is returned. Retries are delayed
+// using exponential backoff with full jitter, and stop if ctx is done
+func (p *Policy) Do(ctx context.Context, isRetryable func(error) bool, call func(ctx context.Context) error) error {
+	var callErr error
+	for attempt := 0; attempt < p.MaxAttempts || attempt == 0; attempt++ {
+		if attempt > 0 {
+			delay := p.getDelay(attempt)
+			notification.SendVerboseMessage(fmt.Sprintf("Retrying in %s after attempt %d failed: %v", delay.Round(time.Millisecond), attempt, callErr))
+
+			select {
+			case <-ctx.Done():
+				return callErr
+			case <-time.After(delay):
+			}
+		}
+
+		callErr = p.attempt(ctx, call)
+		if callErr == nil || !isRetryable(callErr) || ctx.Err() != nil {
+			return callErr
+		}
+	}
+
+	return callErr
+}
+
+func (p *Policy) attempt(ctx context.Context, call func(ctx context.Context) error) error {
+	if p.Timeout <= 0 {
+		return call(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	return call(attemptCtx)
+}
+
+// getDelay returns a random delay between 0 and the exponential backoff for the retry so that the calls
+// throttled at the same time are not all retried at the same time
+func (p *Policy) getDelay(retry int) time.Duration {
+	backoff := p.BaseDelay
+	for i := 1; i < retry && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// IsRetryableStatus checks whether a response with the HTTP status code means that the API is throttling
+// requests or failed to handle the request and that the request can be retried
+func IsRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errThrottled = errors.New("throttled")
+var errInvalid = errors.New("invalid")
+
+func isThrottled(err error) bool {
+	return err == errThrottled
+}
+
+func TestDoRetriesRetryableErrors(t *testing.T) {
+	policy := &Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	attempts := 0
+	err := policy.Do(context.Background(), isThrottled, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errThrottled
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("Expecting the call to succeed after 3 attempts; got %d attempts and error %v", attempts, err)
+	}
+
+	attempts = 0
+	err = policy.Do(context.Background(), isThrottled, func(ctx context.Context) error {
+		attempts++
+		return errThrottled
+	})
+	if err != errThrottled || attempts != 3 {
+		t.Errorf("Expecting the last error after 3 attempts; got %d attempts and error %v", attempts, err)
+	}
+
+	attempts = 0
+	err = policy.Do(context.Background(), isThrottled, func(ctx context.Context) error {
+		attempts++
+		return errInvalid
+	})
+	if err != errInvalid || attempts != 1 {
+		t.Errorf("Expecting errors that are not retryable not to be retried; got %d attempts and error %v", attempts, err)
+	}
+}
+
+func TestDoTimesOutAttempts(t *testing.T) {
+	policy := &Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Timeout: 10 * time.Millisecond}
+
+	attempts := 0
+	err := policy.Do(context.Background(), func(err error) bool { return err == context.DeadlineExceeded }, func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != context.DeadlineExceeded || attempts != 2 {
+		t.Errorf("Expecting each attempt to time out; got %d attempts and error %v", attempts, err)
+	}
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	policy := &Policy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := policy.Do(ctx, isThrottled, func(ctx context.Context) error {
+		attempts++
+		return errThrottled
+	})
+	if err != errThrottled || attempts != 1 {
+		t.Errorf("Expecting no retries after the context is done; got %d attempts and error %v", attempts, err)
+	}
+}
+
+func TestGetDelay(t *testing.T) {
+	policy := &Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	limits := map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second}
+	for retry, limit := range limits {
+		for i := 0; i < 20; i++ {
+			if delay := policy.getDelay(retry); delay < 0 || delay > limit {
+				t.Errorf("Expecting the delay of retry %d to be between 0 and %s; got %s", retry, limit, delay)
+			}
+		}
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	for statusCode, expected := range map[int]bool{200: false, 404: false, 429: true, 500: true, 503: true} {
+		if IsRetryableStatus(statusCode) != expected {
+			t.Errorf("Expecting IsRetryableStatus(%d) to be %v", statusCode, expected)
+		}
+	}
+}
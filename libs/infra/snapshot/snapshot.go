@@ -0,0 +1,224 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+const dataDirEnvVar string = "XDG_DATA_HOME"
+const fileNamePrefix string = "snapshot-"
+const fileNameSuffix string = ".json"
+
+// fileNameTimeFormat is sortable so that the snapshots in a directory are sorted by time when sorted by name
+const fileNameTimeFormat string = "20060102T150405Z"
+
+// Names that can be used instead of the path to a snapshot in the snapshots directory
+const (
+	NameLatest   string = "latest"
+	NamePrevious string = "previous"
+)
+
+// Kinds of changes between two snapshots
+const (
+	ChangeAdded    string = "added"
+	ChangeRemoved  string = "removed"
+	ChangeModified string = "modified"
+)
+
+// Snapshot is the list of resources fetched from the providers at a point in time
+type Snapshot struct {
+	Time      time.Time
+	Resources []*types.InfraResource
+}
+
+// Change is a difference between two snapshots. Field is the changed tag or property (e.g. "tag:Owner"
+// or "property:state") and is only set if Kind is ChangeModified
+type Change struct {
+	Kind     string
+	Resource *types.InfraResource
+	Field    string
+	OldValue string
+	NewValue string
+}
+
+// DefaultDir returns the directory snapshots are saved in if no other directory is provided, which is
+// ~/.local/share/sre-tooling/snapshots unless XDG_DATA_HOME is set
+func DefaultDir() (string, error) {
+	dataDir := os.Getenv(dataDirEnvVar)
+	if len(dataDir) == 0 {
+		home, homeErr := os.UserHomeDir()
+		if homeErr != nil {
+			return "", homeErr
+		}
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dataDir, "sre-tooling", "snapshots"), nil
+}
+
+// Save writes the snapshot to a file, named after the snapshot's time, in dir and returns the path to the file
+func Save(dir string, snapshot *Snapshot) (string, error) {
+	mkdirErr := os.MkdirAll(dir, 0700)
+	if mkdirErr != nil {
+		return "", mkdirErr
+	}
+
+	path := filepath.Join(dir, fileNamePrefix+snapshot.Time.UTC().Format(fileNameTimeFormat)+fileNameSuffix)
+	return path, Write(path, snapshot)
+}
+
+// Write writes the snapshot to the file at path
+func Write(path string, snapshot *Snapshot) error {
+	content, marshalErr := json.MarshalIndent(snapshot, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// Load reads the snapshot in the file at path
+func Load(path string) (*Snapshot, error) {
+	content, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	snapshot := new(Snapshot)
+	unmarshalErr := json.Unmarshal(content, snapshot)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("Could not parse the snapshot %s: %v", path, unmarshalErr)
+	}
+
+	return snapshot, nil
+}
+
+// Resolve returns the path to the snapshot with the provided name, which is either the path to a file,
+// the name of a file in dir, NameLatest for the most recent snapshot in dir or NamePrevious for the one
+// before it
+func Resolve(dir string, name string) (string, error) {
+	if name == NameLatest || name == NamePrevious {
+		paths, listErr := List(dir)
+		if listErr != nil {
+			return "", listErr
+		}
+
+		index := len(paths) - 1
+		if name == NamePrevious {
+			index--
+		}
+		if index < 0 {
+			return "", fmt.Errorf("There are not enough snapshots in %s to get the %s snapshot", dir, name)
+		}
+
+		return paths[index], nil
+	}
+
+	if _, statErr := os.Stat(name); statErr == nil {
+		return name, nil
+	}
+
+	path := filepath.Join(dir, name)
+	if _, statErr := os.Stat(path); statErr != nil {
+		return "", fmt.Errorf("Could not find the snapshot '%s' in the working directory or in %s", name, dir)
+	}
+
+	return path, nil
+}
+
+// List returns the paths to the snapshots saved in dir, from the oldest to the most recent
+func List(dir string) ([]string, error) {
+	files, readErr := ioutil.ReadDir(dir)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	paths := []string{}
+	for _, curFile := range files {
+		if !curFile.IsDir() && strings.HasPrefix(curFile.Name(), fileNamePrefix) && strings.HasSuffix(curFile.Name(), fileNameSuffix) {
+			paths = append(paths, filepath.Join(dir, curFile.Name()))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// Diff returns the resources added to or removed from the old snapshot and the changes to the tags and
+// properties of the other resources. Fields in ignoredFields (e.g. "property:public-ip") are not compared
+func Diff(oldSnapshot *Snapshot, newSnapshot *Snapshot, ignoredFields []string) []*Change {
+	ignored := make(map[string]bool)
+	for _, curField := range ignoredFields {
+		ignored[curField] = true
+	}
+
+	oldResources := make(map[string]*types.InfraResource)
+	for _, curResource := range oldSnapshot.Resources {
+		oldResources[getResourceKey(curResource)] = curResource
+	}
+
+	changes := []*Change{}
+	newKeys := make(map[string]bool)
+	for _, curResource := range newSnapshot.Resources {
+		key := getResourceKey(curResource)
+		newKeys[key] = true
+		oldResource, existed := oldResources[key]
+		if !existed {
+			changes = append(changes, &Change{Kind: ChangeAdded, Resource: curResource})
+			continue
+		}
+
+		changes = append(changes, diffFields(curResource, "tag", oldResource.Tags, curResource.Tags, ignored)...)
+		changes = append(changes, diffFields(curResource, "property", oldResource.Properties, curResource.Properties, ignored)...)
+	}
+
+	for _, curResource := range oldSnapshot.Resources {
+		if !newKeys[getResourceKey(curResource)] {
+			changes = append(changes, &Change{Kind: ChangeRemoved, Resource: curResource})
+		}
+	}
+
+	return changes
+}
+
+// getResourceKey returns the key identifying the resource across snapshots
+func getResourceKey(resource *types.InfraResource) string {
+	return strings.Join([]string{resource.Provider, resource.ResourceType, resource.Location, resource.ID}, "/")
+}
+
+// diffFields returns a change for each field whose value is different in the old and new fields, sorted
+// by field. Fields that were added or removed have an empty old or new value
+func diffFields(resource *types.InfraResource, fieldType string, oldFields map[string]string, newFields map[string]string, ignored map[string]bool) []*Change {
+	keys := []string{}
+	for curKey := range oldFields {
+		keys = append(keys, curKey)
+	}
+	for curKey := range newFields {
+		if _, inOld := oldFields[curKey]; !inOld {
+			keys = append(keys, curKey)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []*Change{}
+	for _, curKey := range keys {
+		field := fieldType + ":" + curKey
+		oldValue, inOld := oldFields[curKey]
+		newValue, inNew := newFields[curKey]
+		if ignored[field] || (inOld == inNew && oldValue == newValue) {
+			continue
+		}
+
+		changes = append(changes, &Change{Kind: ChangeModified, Resource: resource, Field: field, OldValue: oldValue, NewValue: newValue})
+	}
+
+	return changes
+}
@@ -0,0 +1,102 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+func newResource(id string, tags map[string]string, properties map[string]string) *types.InfraResource {
+	return &types.InfraResource{Provider: "AWS", ID: id, Location: "eu-west-1", ResourceType: "EC2", Tags: tags, Properties: properties}
+}
+
+// Test whether Diff reports added and removed resources and tag and property changes
+func TestDiff(t *testing.T) {
+	oldSnapshot := &Snapshot{Resources: []*types.InfraResource{
+		newResource("i-1", map[string]string{"Owner": "sre", "Name": "web"}, map[string]string{"state": "running", "public-ip": "192.0.2.1"}),
+		newResource("i-2", nil, nil),
+	}}
+	newSnapshot := &Snapshot{Resources: []*types.InfraResource{
+		newResource("i-1", map[string]string{"Owner": "dev", "Env": "prod"}, map[string]string{"state": "stopped", "public-ip": "192.0.2.2"}),
+		newResource("i-3", nil, nil),
+	}}
+
+	changes := Diff(oldSnapshot, newSnapshot, []string{"property:public-ip"})
+	expected := []Change{
+		{Kind: ChangeModified, Field: "tag:Env", NewValue: "prod"},
+		{Kind: ChangeModified, Field: "tag:Name", OldValue: "web"},
+		{Kind: ChangeModified, Field: "tag:Owner", OldValue: "sre", NewValue: "dev"},
+		{Kind: ChangeModified, Field: "property:state", OldValue: "running", NewValue: "stopped"},
+		{Kind: ChangeAdded},
+		{Kind: ChangeRemoved},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expecting %d changes; got %d", len(expected), len(changes))
+	}
+
+	for i, curChange := range changes {
+		if curChange.Kind != expected[i].Kind || curChange.Field != expected[i].Field || curChange.OldValue != expected[i].OldValue || curChange.NewValue != expected[i].NewValue {
+			t.Errorf("Expecting change %d to be %+v; got %+v", i, expected[i], *curChange)
+		}
+	}
+	if changes[4].Resource.ID != "i-3" || changes[5].Resource.ID != "i-2" {
+		t.Errorf("Expecting i-3 to be added and i-2 to be removed; got %s and %s", changes[4].Resource.ID, changes[5].Resource.ID)
+	}
+}
+
+// Test whether saved snapshots can be loaded back and resolved using their names
+func TestSaveAndResolve(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "snapshots")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	_, latestErr := Resolve(dir, NameLatest)
+	if latestErr == nil {
+		t.Errorf("Expecting an error when there are no snapshots")
+	}
+
+	firstTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	firstPath, firstErr := Save(dir, &Snapshot{Time: firstTime, Resources: []*types.InfraResource{newResource("i-1", map[string]string{"Owner": "sre"}, nil)}})
+	if firstErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", firstErr)
+	}
+	secondPath, secondErr := Save(dir, &Snapshot{Time: firstTime.Add(time.Hour)})
+	if secondErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", secondErr)
+	}
+
+	testCases := map[string]string{
+		NameLatest:                  secondPath,
+		NamePrevious:                firstPath,
+		filepath.Base(firstPath):    firstPath,
+		firstPath:                   firstPath,
+		"snapshot-missing.json":     "",
+		filepath.Join(dir, "other"): "",
+	}
+	for curName, curExpected := range testCases {
+		path, pathErr := Resolve(dir, curName)
+		if len(curExpected) == 0 {
+			if pathErr == nil {
+				t.Errorf("Expecting an error for '%s'; got %s", curName, path)
+			}
+			continue
+		}
+		if pathErr != nil || path != curExpected {
+			t.Errorf("Expecting '%s' to be resolved to %s; got %s and error %v", curName, curExpected, path, pathErr)
+		}
+	}
+
+	loaded, loadErr := Load(firstPath)
+	if loadErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", loadErr)
+	}
+	if !loaded.Time.Equal(firstTime) || len(loaded.Resources) != 1 || loaded.Resources[0].Tags["Owner"] != "sre" {
+		t.Errorf("Unexpected loaded snapshot %+v", loaded)
+	}
+}
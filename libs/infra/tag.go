@@ -0,0 +1,188 @@
+package infra
+
+// This file contains the logic for planning and applying changes to the tags of many resources at once
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+// TagUpdate is a change to one of a resource's tags. OldValue is nil if the resource doesn't have the
+// tag and NewValue is nil if the tag is removed
+type TagUpdate struct {
+	Resource *types.InfraResource
+	Key      string
+	OldValue *string
+	NewValue *string
+}
+
+// TagValueFunc returns the value to set the resource's tag to, or false if the resource's tag shouldn't
+// be set
+type TagValueFunc func(resource *types.InfraResource) (string, bool, error)
+
+// StaticTagValue returns a TagValueFunc that sets the tag of all the resources to value
+func StaticTagValue(value string) TagValueFunc {
+	return func(resource *types.InfraResource) (string, bool, error) {
+		return value, true, nil
+	}
+}
+
+// TemplateTagValue returns a TagValueFunc that sets the tag of each resource to the Go template rendered
+// with the resource e.g. `{{.Tags.Owner}}`. The template has the same functions as -template. Resources
+// the template renders to an empty string for are skipped
+func TemplateTagValue(text string) (TagValueFunc, error) {
+	tmpl, tmplErr := template.New("tag").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if tmplErr != nil {
+		return nil, tmplErr
+	}
+
+	return func(resource *types.InfraResource) (string, bool, error) {
+		buf := new(bytes.Buffer)
+		executeErr := tmpl.Execute(buf, resource)
+		if executeErr != nil {
+			return "", false, executeErr
+		}
+
+		return buf.String(), buf.Len() > 0, nil
+	}, nil
+}
+
+// PlanSetTag returns the updates setting the tag of each resource to the value returned by value.
+// Resources whose tag already has the value are skipped
+func PlanSetTag(resources []*types.InfraResource, key string, value TagValueFunc) ([]*TagUpdate, error) {
+	updates := []*TagUpdate{}
+	for _, curResource := range resources {
+		newValue, setTag, valueErr := value(curResource)
+		if valueErr != nil {
+			return nil, fmt.Errorf("Could not get the value of the tag '%s' of the %s resource '%s': %v", key, curResource.ResourceType, curResource.ID, valueErr)
+		}
+		if !setTag {
+			continue
+		}
+
+		oldValue := getTagValue(curResource, key)
+		if oldValue != nil && *oldValue == newValue {
+			continue
+		}
+
+		updates = append(updates, &TagUpdate{Resource: curResource, Key: key, OldValue: oldValue, NewValue: &newValue})
+	}
+
+	return updates, nil
+}
+
+// PlanDeleteTag returns the updates removing the tag from the resources that have it
+func PlanDeleteTag(resources []*types.InfraResource, key string) []*TagUpdate {
+	updates := []*TagUpdate{}
+	for _, curResource := range resources {
+		oldValue := getTagValue(curResource, key)
+		if oldValue == nil {
+			continue
+		}
+
+		updates = append(updates, &TagUpdate{Resource: curResource, Key: key, OldValue: oldValue})
+	}
+
+	return updates
+}
+
+// PlanCopyTag returns the updates setting the newKey tag to the value of the key tag on the resources
+// that have the key tag. Resources that already have a different newKey tag are skipped unless overwrite
+// is true
+func PlanCopyTag(resources []*types.InfraResource, key string, newKey string, overwrite bool) []*TagUpdate {
+	updates := []*TagUpdate{}
+	for _, curResource := range resources {
+		update, copyTag := planCopyTag(curResource, key, newKey, overwrite)
+		if copyTag && update != nil {
+			updates = append(updates, update)
+		}
+	}
+
+	return updates
+}
+
+// PlanRenameTag returns the updates copying the key tag to the newKey tag, then removing the key tag, on
+// the resources that have the key tag. Resources that already have a different newKey tag are skipped
+// unless overwrite is true
+func PlanRenameTag(resources []*types.InfraResource, key string, newKey string, overwrite bool) []*TagUpdate {
+	updates := []*TagUpdate{}
+	for _, curResource := range resources {
+		update, copyTag := planCopyTag(curResource, key, newKey, overwrite)
+		if !copyTag {
+			continue
+		}
+		if update != nil {
+			updates = append(updates, update)
+		}
+
+		updates = append(updates, &TagUpdate{Resource: curResource, Key: key, OldValue: getTagValue(curResource, key)})
+	}
+
+	return updates
+}
+
+// planCopyTag returns the update copying the resource's key tag to its newKey tag, which is nil if the
+// newKey tag already has the value, and whether the tag should be copied
+func planCopyTag(resource *types.InfraResource, key string, newKey string, overwrite bool) (*TagUpdate, bool) {
+	value := getTagValue(resource, key)
+	if value == nil {
+		return nil, false
+	}
+
+	oldValue := getTagValue(resource, newKey)
+	if oldValue != nil && *oldValue == *value {
+		return nil, true
+	}
+	if oldValue != nil && !overwrite {
+		return nil, false
+	}
+
+	return &TagUpdate{Resource: resource, Key: newKey, OldValue: oldValue, NewValue: value}, true
+}
+
+// getTagValue returns the value of the resource's tag, or nil if the resource doesn't have the tag
+func getTagValue(resource *types.InfraResource, key string) *string {
+	value, hasTag := resource.Tags[key]
+	if !hasTag {
+		return nil
+	}
+
+	return &value
+}
+
+// ApplyTagUpdates applies the updates in order and returns the error of each update, which is nil if the
+// update succeeded. Once an update to a resource fails, the following updates to the resource are skipped
+// so that e.g. a tag isn't removed if copying it to the new tag failed
+func ApplyTagUpdates(updates []*TagUpdate) ([]error, error) {
+	providers, providerErr := getProviders()
+	if providerErr != nil {
+		return nil, providerErr
+	}
+
+	updateErrs := make([]error, len(updates))
+	failedKeys := make(map[*types.InfraResource]string)
+	updatedProviders := make(map[string]bool)
+	for i, curUpdate := range updates {
+		if failedKey, hasFailed := failedKeys[curUpdate.Resource]; hasFailed {
+			updateErrs[i] = fmt.Errorf("Skipped since updating the tag '%s' failed", failedKey)
+			continue
+		}
+
+		key := curUpdate.Key
+		updateErrs[i] = updateResourceTag(providers, curUpdate.Resource, &key, curUpdate.NewValue)
+		if updateErrs[i] != nil {
+			failedKeys[curUpdate.Resource] = curUpdate.Key
+		}
+		updatedProviders[curUpdate.Resource.Provider] = true
+	}
+
+	// Failed updates might still have changed some of the resources
+	for curProvider := range updatedProviders {
+		clearProviderCache(curProvider)
+	}
+
+	return updateErrs, nil
+}
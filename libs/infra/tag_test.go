@@ -0,0 +1,125 @@
+package infra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+func newTagTestResources() []*types.InfraResource {
+	return []*types.InfraResource{
+		&types.InfraResource{Provider: "file", ResourceType: "Server", ID: "server-1", Tags: map[string]string{"Owner": "alice"}},
+		&types.InfraResource{Provider: "file", ResourceType: "Server", ID: "server-2", Tags: map[string]string{"Owner": "bob", "OwnerList": "bob,carol"}},
+		&types.InfraResource{Provider: "file", ResourceType: "Server", ID: "server-3", Tags: map[string]string{"OwnerList": "dave"}},
+	}
+}
+
+// Test whether the updates only include the resources whose tags change
+func TestPlanTagUpdates(t *testing.T) {
+	t.Run("set from template", func(t *testing.T) {
+		value, templateErr := TemplateTagValue("{{.Tags.Owner | upper}}")
+		if templateErr != nil {
+			t.Fatalf("Expecting error to be nil; got %v", templateErr)
+		}
+
+		updates, planErr := PlanSetTag(newTagTestResources(), "OwnerList", value)
+		if planErr != nil || len(updates) != 2 {
+			t.Fatalf("Expecting 2 updates; got %d and error %v", len(updates), planErr)
+		}
+		if updates[0].Resource.ID != "server-1" || updates[0].OldValue != nil || *updates[0].NewValue != "ALICE" {
+			t.Errorf("Expecting the tag of server-1 to be added; got %+v", updates[0])
+		}
+		if updates[1].Resource.ID != "server-2" || *updates[1].OldValue != "bob,carol" || *updates[1].NewValue != "BOB" {
+			t.Errorf("Expecting the tag of server-2 to be replaced; got %+v", updates[1])
+		}
+	})
+
+	t.Run("set static value", func(t *testing.T) {
+		updates, planErr := PlanSetTag(newTagTestResources(), "OwnerList", StaticTagValue("dave"))
+		if planErr != nil || len(updates) != 2 || updates[0].Resource.ID != "server-1" || updates[1].Resource.ID != "server-2" {
+			t.Errorf("Expecting the resources that don't have the value to be updated; got %v and error %v", updates, planErr)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		updates := PlanDeleteTag(newTagTestResources(), "Owner")
+		if len(updates) != 2 || updates[0].NewValue != nil || *updates[1].OldValue != "bob" {
+			t.Errorf("Expecting the tag to be removed from the resources that have it; got %v", updates)
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		updates := PlanCopyTag(newTagTestResources(), "Owner", "OwnerList", false)
+		if len(updates) != 1 || updates[0].Resource.ID != "server-1" || *updates[0].NewValue != "alice" {
+			t.Errorf("Expecting resources that have the new tag to be skipped; got %v", updates)
+		}
+
+		updates = PlanCopyTag(newTagTestResources(), "Owner", "OwnerList", true)
+		if len(updates) != 2 || *updates[1].NewValue != "bob" {
+			t.Errorf("Expecting the new tag to be overwritten; got %v", updates)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		updates := PlanRenameTag(newTagTestResources(), "Owner", "OwnerList", false)
+		if len(updates) != 2 || updates[0].Key != "OwnerList" || updates[1].Key != "Owner" || updates[1].NewValue != nil {
+			t.Errorf("Expecting the tag to be copied then removed; got %v", updates)
+		}
+	})
+}
+
+// Test whether the updates are written using the resources' providers and whether the updates to a
+// resource are skipped after one of them fails
+func TestApplyTagUpdates(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "providers")
+	if dirErr != nil {
+		t.Fatalf("Could not create a temporary directory: %v", dirErr)
+	}
+	defer os.RemoveAll(dir)
+
+	inventoryPath := filepath.Join(dir, "inventory.yml")
+	inventoryErr := ioutil.WriteFile(inventoryPath, []byte("resources:\n- id: server-1\n  type: Server\n  tags:\n    Owner: alice\n"), 0600)
+	if inventoryErr != nil {
+		t.Fatalf("Could not write the inventory file: %v", inventoryErr)
+	}
+
+	configPath := filepath.Join(dir, "providers.yaml")
+	configErr := ioutil.WriteFile(configPath, []byte("providers:\n  file:\n    settings:\n      inventory: "+inventoryPath+"\n"), 0600)
+	if configErr != nil {
+		t.Fatalf("Could not write the providers configuration file: %v", configErr)
+	}
+
+	ProvidersConfigFile = configPath
+	defer func() { ProvidersConfigFile = "" }()
+
+	resources, resourcesErr := GetResources(&types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 resource; got %v and error %v", resources, resourcesErr)
+	}
+
+	missing := &types.InfraResource{Provider: "file", ResourceType: "Server", ID: "server-2", Tags: map[string]string{"Owner": "bob"}}
+	updates := PlanRenameTag(append(resources, missing), "Owner", "OwnerList", false)
+
+	updateErrs, applyErr := ApplyTagUpdates(updates)
+	if applyErr != nil {
+		t.Fatalf("Expecting error to be nil; got %v", applyErr)
+	}
+	if len(updateErrs) != 4 || updateErrs[0] != nil || updateErrs[1] != nil {
+		t.Fatalf("Expecting the updates to the resource in the inventory to succeed; got %v", updateErrs)
+	}
+	if updateErrs[2] == nil || updateErrs[3] == nil || !strings.Contains(updateErrs[3].Error(), "Skipped") {
+		t.Errorf("Expecting the tag of the resource that isn't in the inventory not to be removed after copying it failed; got %v", updateErrs)
+	}
+
+	resources, resourcesErr = GetResources(&types.InfraFilter{})
+	if resourcesErr != nil || len(resources) != 1 {
+		t.Fatalf("Expecting 1 resource; got %v and error %v", resources, resourcesErr)
+	}
+	if _, hasOwner := resources[0].Tags["Owner"]; hasOwner || resources[0].Tags["OwnerList"] != "alice" {
+		t.Errorf("Expecting the tag to be renamed; got %v", resources[0].Tags)
+	}
+}
@@ -0,0 +1,120 @@
+package infra
+
+// This file contains the logic for rendering resources using Go templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the helpers available to -template, in addition to the text/template built-in
+// functions. They take their arguments in the same order as the Sprig functions with the same names so
+// that the last argument can be piped e.g. `{{.Tags.Name | default "unnamed" | upper}}`
+var templateFuncs = template.FuncMap{
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      strings.Title,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old string, newValue string, s string) string { return strings.Replace(s, old, newValue, -1) },
+	"contains":   func(substr string, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix string, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix string, s string) bool { return strings.HasSuffix(s, suffix) },
+	"splitList":  func(sep string, s string) []string { return strings.Split(s, sep) },
+	"join":       func(sep string, values []string) string { return strings.Join(values, sep) },
+	"quote":      strconv.Quote,
+	"squote":     func(s string) string { return "'" + s + "'" },
+	"default":    defaultValue,
+	"empty":      isEmpty,
+	"toJson":     toJSON,
+	"now":        time.Now,
+	"date":       func(layout string, t time.Time) string { return t.Format(layout) },
+	"ago":        func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+}
+
+// defaultValue returns value, or defaultVal if value is empty
+func defaultValue(defaultVal interface{}, value interface{}) interface{} {
+	if isEmpty(value) {
+		return defaultVal
+	}
+
+	return value
+}
+
+// isEmpty checks whether the value is nil or its type's zero value, or an empty map or slice
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String:
+		return reflected.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return reflected.IsNil()
+	}
+
+	return reflect.DeepEqual(value, reflect.Zero(reflected.Type()).Interface())
+}
+
+func toJSON(value interface{}) (string, error) {
+	encoded, encodeErr := json.Marshal(value)
+	if encodeErr != nil {
+		return "", encodeErr
+	}
+
+	return string(encoded), nil
+}
+
+// getTemplate returns the template set in -template or -template-file, or nil if neither is set
+func (rt *ResourceTable) getTemplate() (*template.Template, error) {
+	text := ""
+	if rt.templateFlag != nil {
+		text = *rt.templateFlag
+	}
+	if rt.templateFileFlag != nil && len(*rt.templateFileFlag) > 0 {
+		if len(text) > 0 {
+			return nil, fmt.Errorf("Only one of -template and -template-file can be set")
+		}
+
+		content, contentErr := ioutil.ReadFile(*rt.templateFileFlag)
+		if contentErr != nil {
+			return nil, contentErr
+		}
+
+		// Editors add a newline at the end of files. The resources are already separated by -resource-separator
+		text = strings.TrimSuffix(string(content), "\n")
+	}
+	if len(text) == 0 {
+		return nil, nil
+	}
+
+	if *rt.outputFlag != OutputTable {
+		return nil, fmt.Errorf("-output can't be used with -template or -template-file")
+	}
+
+	return template.New("resource").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// renderTemplate renders each of the items using the template, followed by -resource-separator
+func (rt *ResourceTable) renderTemplate(tmpl *template.Template, items []interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	for _, curItem := range items {
+		executeErr := tmpl.Execute(buf, curItem)
+		if executeErr != nil {
+			return "", executeErr
+		}
+		buf.WriteString(*rt.resourceSeparatorFlag)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,108 @@
+package infra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onaio/sre-tooling/libs/cli/flags"
+	"github.com/onaio/sre-tooling/libs/types"
+)
+
+func newTemplateResourceTable(templateFlag string, templateFileFlag string) *ResourceTable {
+	hideHeadersFlag := false
+	csvFlag := false
+	fieldSeparatorFlag := "\t"
+	resourceSeparatorFlag := "\n"
+	listFieldsFlag := false
+	defaultFieldValueFlag := ""
+	outputFlag := OutputTable
+
+	rt := new(ResourceTable)
+	rt.Init(
+		new(flags.StringArray),
+		&hideHeadersFlag,
+		&csvFlag,
+		&fieldSeparatorFlag,
+		&resourceSeparatorFlag,
+		&listFieldsFlag,
+		&defaultFieldValueFlag,
+		&outputFlag,
+		&templateFlag,
+		&templateFileFlag)
+
+	return rt
+}
+
+// Test whether resources and cost spikes are rendered using the template and its helpers
+func TestResourceTableTemplate(t *testing.T) {
+	resources := []*types.InfraResource{
+		&types.InfraResource{
+			ID:         "i-1",
+			Tags:       map[string]string{"Name": "web-1"},
+			Properties: map[string]string{"public-ip": "192.0.2.1"}},
+		&types.InfraResource{
+			ID:         "i-2",
+			Properties: map[string]string{"public-ip": "192.0.2.2"}},
+	}
+
+	t.Run("resources", func(t *testing.T) {
+		rt := newTemplateResourceTable(`ssh {{index .Properties "public-ip"}} # {{.Tags.Name | default "unnamed" | upper}}`, "")
+		output, outputErr := rt.RenderResources(resources)
+		if outputErr != nil {
+			t.Fatalf("Not expecting an error to be returned: %v", outputErr)
+		}
+
+		expected := "ssh 192.0.2.1 # WEB-1\nssh 192.0.2.2 # UNNAMED\n"
+		if output != expected {
+			t.Errorf("Expecting %q; got %q", expected, output)
+		}
+	})
+
+	t.Run("template-file", func(t *testing.T) {
+		dir, dirErr := ioutil.TempDir("", "template")
+		if dirErr != nil {
+			t.Fatalf("Could not create a temporary directory: %v", dirErr)
+		}
+		defer os.RemoveAll(dir)
+
+		templatePath := filepath.Join(dir, "resource.tmpl")
+		writeErr := ioutil.WriteFile(templatePath, []byte("{{.ID}}: {{replace \".\" \"-\" (index .Properties \"public-ip\")}}\n"), 0600)
+		if writeErr != nil {
+			t.Fatalf("Could not write the template file: %v", writeErr)
+		}
+
+		rt := newTemplateResourceTable("", templatePath)
+		output, outputErr := rt.RenderResources(resources)
+		if outputErr != nil {
+			t.Fatalf("Not expecting an error to be returned: %v", outputErr)
+		}
+
+		expected := "i-1: 192-0-2-1\ni-2: 192-0-2-2\n"
+		if output != expected {
+			t.Errorf("Expecting %q; got %q", expected, output)
+		}
+	})
+
+	t.Run("cost-spikes", func(t *testing.T) {
+		rt := newTemplateResourceTable(`{{.Provider}} {{.GroupKey | quote}} {{printf "%.1f" .IncreaseRate}}`, "")
+		output, outputErr := rt.RenderCostSpikes([]*types.CostSpikeOutput{&types.CostSpikeOutput{Provider: "AWS", GroupKey: "EC2", IncreaseRate: 12.34}})
+		if outputErr != nil {
+			t.Fatalf("Not expecting an error to be returned: %v", outputErr)
+		}
+
+		expected := "AWS \"EC2\" 12.3\n"
+		if output != expected {
+			t.Errorf("Expecting %q; got %q", expected, output)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rt := newTemplateResourceTable("{{.ID", "")
+		_, outputErr := rt.RenderResources(resources)
+		if outputErr == nil {
+			t.Errorf("Expecting an error for an invalid template")
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package workers
+
+import (
+	"context"
+	"sync"
+)
+
+// Limit is the maximum number of workers running at the same time across all the Run calls in the
+// process, and therefore the maximum number of concurrent calls made to the providers' APIs when
+// fetching e.g. the resources in all the regions
+var Limit = 10
+
+type contextKey string
+
+// slotContextKey is set in the context passed to work so that Run calls nested in work know that
+// their caller already holds a slot
+const slotContextKey contextKey = "workers-slot"
+
+// slots counts the workers running across all the Run calls
+var slots = struct {
+	sync.Mutex
+	cond *sync.Cond
+	used int
+}{}
+
+func init() {
+	slots.cond = sync.NewCond(&slots.Mutex)
+}
+
+// Run calls work with each index from 0 to count - 1 and waits for all the calls to return. Each call
+// runs in one of the Limit slots shared by all the Run calls, so nesting Run calls, or running them
+// from different goroutines, doesn't increase the number of concurrent calls. Run calls nested in work
+// need to use the context passed to work so that they don't wait for a slot their caller holds
+func Run(ctx context.Context, count int, work func(ctx context.Context, index int)) {
+	if count < 1 {
+		return
+	}
+
+	indexes := make(chan int, count)
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	workerCtx := context.WithValue(ctx, slotContextKey, true)
+	process := func() {
+		for curIndex := range indexes {
+			work(workerCtx, curIndex)
+		}
+	}
+
+	wg := new(sync.WaitGroup)
+	if ctx.Value(slotContextKey) != nil {
+		// The caller holds a slot, so it processes the indexes itself, helped by workers started in
+		// the slots that are free. Waiting for a slot here could deadlock if all the slots are held by
+		// callers of nested Run calls
+		for i := 1; i < count && acquireSlot(false); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer releaseSlot()
+				process()
+			}()
+		}
+		process()
+	} else {
+		for i := 0; i < count && i < getLimit(); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				acquireSlot(true)
+				defer releaseSlot()
+				process()
+			}()
+		}
+	}
+
+	wg.Wait()
+}
+
+// getLimit returns Limit, or 1 if Limit is lower
+func getLimit() int {
+	if Limit < 1 {
+		return 1
+	}
+
+	return Limit
+}
+
+// acquireSlot takes one of the free slots. If there is none, acquireSlot waits for one if wait is
+// true or returns false otherwise
+func acquireSlot(wait bool) bool {
+	slots.Lock()
+	defer slots.Unlock()
+
+	for slots.used >= getLimit() {
+		if !wait {
+			return false
+		}
+		slots.cond.Wait()
+	}
+	slots.used++
+
+	return true
+}
+
+// releaseSlot frees a slot taken using acquireSlot
+func releaseSlot() {
+	slots.Lock()
+	slots.used--
+	slots.Unlock()
+
+	slots.cond.Signal()
+}
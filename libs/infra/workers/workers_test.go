@@ -0,0 +1,96 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunLimitsConcurrency(t *testing.T) {
+	oldLimit := Limit
+	Limit = 3
+	defer func() { Limit = oldLimit }()
+
+	mutex := new(sync.Mutex)
+	running := 0
+	maxRunning := 0
+	done := make([]bool, 20)
+	Run(context.Background(), len(done), func(ctx context.Context, index int) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mutex.Lock()
+		running--
+		done[index] = true
+		mutex.Unlock()
+	})
+
+	if maxRunning > Limit {
+		t.Errorf("Expecting at most %d concurrent calls; got %d", Limit, maxRunning)
+	}
+	for index, curDone := range done {
+		if !curDone {
+			t.Errorf("Expecting work to be called with index %d", index)
+		}
+	}
+}
+
+// Test whether Run calls running in parallel, and nested in each other, share the Limit slots, like
+// the AWS provider fetching the resources of each type in the regions of each account
+func TestRunLimitsNestedConcurrency(t *testing.T) {
+	oldLimit := Limit
+	Limit = 4
+	defer func() { Limit = oldLimit }()
+
+	mutex := new(sync.Mutex)
+	running := 0
+	maxRunning := 0
+	calls := 0
+	leafWork := func(ctx context.Context, index int) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mutex.Lock()
+		running--
+		calls++
+		mutex.Unlock()
+	}
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Run(context.Background(), 6, func(ctx context.Context, index int) {
+				Run(ctx, 3, leafWork)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning > Limit {
+		t.Errorf("Expecting at most %d concurrent calls; got %d", Limit, maxRunning)
+	}
+	if calls != 5*6*3 {
+		t.Errorf("Expecting work to be called %d times; got %d", 5*6*3, calls)
+	}
+}
+
+func TestRunWithoutWork(t *testing.T) {
+	Run(context.Background(), 0, func(ctx context.Context, index int) {
+		t.Errorf("Not expecting work to be called")
+	})
+}
@@ -1,5 +1,14 @@
 package notification
 
+import (
+	"fmt"
+	"os"
+)
+
+// Verbose is set to true if the user requested for verbose output. Verbose messages are only
+// printed if it is set
+var Verbose bool
+
 type Channel interface {
 	SendMessage(message string) error
 }
@@ -23,3 +32,13 @@ func SendMessage(message string) error {
 
 	return nil
 }
+
+// SendVerboseMessage prints the message to stderr if Verbose is set. Verbose messages are
+// diagnostic and are therefore not sent to the other channels
+func SendVerboseMessage(message string) {
+	if !Verbose {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, message)
+}
@@ -16,6 +16,7 @@ type CostAndUsageOutput struct {
 // CostAndUsageFilter defines parameters used to filter costs
 type CostAndUsageFilter struct {
 	Providers     []string
+	Accounts      []string
 	ResourceTypes []string
 	Regions       []string
 	Tags          map[string]string
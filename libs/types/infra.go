@@ -1,9 +1,18 @@
 package types
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
+// Resource states that can be requested through a provider's UpdateResourceState
+const (
+	ResourceStateRunning    string = "running"
+	ResourceStateStopped    string = "stopped"
+	ResourceStateTerminated string = "terminated"
+)
+
 type InfraResource struct {
 	Provider     string
 	ID           string
@@ -17,7 +26,134 @@ type InfraResource struct {
 
 type InfraFilter struct {
 	Providers     []string
+	Accounts      []string
 	ResourceTypes []string
 	Regions       []string
 	Tags          map[string]string
+
+	// Expression is the -filter expression resources need to match. Providers can use it to narrow down
+	// the resources they fetch but don't need to since it's also evaluated on the fetched resources
+	Expression InfraExpression
+}
+
+// InfraExpression is a parsed filter expression
+type InfraExpression interface {
+	Matches(resource *InfraResource) bool
+	String() string
+}
+
+// ConsiderExpression checks whether the resource matches the filter's expression. All resources are
+// considered if the filter doesn't have an expression
+func (filter *InfraFilter) ConsiderExpression(resource *InfraResource) bool {
+	return filter.Expression == nil || filter.Expression.Matches(resource)
+}
+
+// ConsiderRegion checks whether the region is one of the filter's regions. Case is ignored. All regions
+// are considered if the filter doesn't have regions
+func (filter *InfraFilter) ConsiderRegion(region string) bool {
+	return containsIgnoringCase(filter.Regions, region)
+}
+
+// ConsiderAccount checks whether the account is one of the filter's accounts. Case is ignored. All accounts
+// are considered if the filter doesn't have accounts
+func (filter *InfraFilter) ConsiderAccount(account string) bool {
+	return containsIgnoringCase(filter.Accounts, account)
+}
+
+// ConsiderResourceType checks whether the resource type is one of the filter's resource types. Case is
+// ignored. All resource types are considered if the filter doesn't have resource types
+func (filter *InfraFilter) ConsiderResourceType(resourceType string) bool {
+	return containsIgnoringCase(filter.ResourceTypes, resourceType)
+}
+
+// ConsiderTags checks whether the tags have all the filter's tags. Tag values are compared ignoring case
+func (filter *InfraFilter) ConsiderTags(tags map[string]string) bool {
+	for tagName, tagValue := range filter.Tags {
+		tagValueToCheck, ok := tags[tagName]
+		if !ok || !strings.EqualFold(tagValue, tagValueToCheck) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// InfraFailure is a failure to fetch a provider's resources, or only the resources of a type or in a
+// region if ResourceType or Region is set
+type InfraFailure struct {
+	Provider     string
+	Region       string
+	ResourceType string
+	Err          error
+}
+
+func (failure *InfraFailure) Error() string {
+	scope := failure.Provider
+	if len(failure.ResourceType) > 0 {
+		scope = strings.TrimSpace(scope + " " + failure.ResourceType)
+	}
+	if len(failure.Region) > 0 {
+		scope = scope + " resources in " + failure.Region
+	} else {
+		scope = scope + " resources"
+	}
+
+	return fmt.Sprintf("Could not fetch the %s: %v", scope, failure.Err)
+}
+
+// InfraFailures is returned, together with the resources that could be fetched, if fetching some of the
+// resources failed
+type InfraFailures []*InfraFailure
+
+func (failures InfraFailures) Error() string {
+	messages := make([]string, len(failures))
+	for i, curFailure := range failures {
+		messages[i] = curFailure.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// ToInfraFailures returns the failures in err, which is either InfraFailures, an *InfraFailure or any other
+// error fetching the resources. The provider, resource type and region are set in the failures that don't
+// have them
+func ToInfraFailures(err error, provider string, resourceType string, region string) InfraFailures {
+	var failures InfraFailures
+	switch typedErr := err.(type) {
+	case InfraFailures:
+		failures = typedErr
+	case *InfraFailure:
+		failures = InfraFailures{typedErr}
+	default:
+		failures = InfraFailures{{Err: err}}
+	}
+
+	for _, curFailure := range failures {
+		if len(curFailure.Provider) == 0 {
+			curFailure.Provider = provider
+		}
+		if len(curFailure.ResourceType) == 0 {
+			curFailure.ResourceType = resourceType
+		}
+		if len(curFailure.Region) == 0 {
+			curFailure.Region = region
+		}
+	}
+
+	return failures
+}
+
+// containsIgnoringCase checks whether value is in values. true is returned if values is empty
+func containsIgnoringCase(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, curValue := range values {
+		if strings.EqualFold(curValue, value) {
+			return true
+		}
+	}
+
+	return false
 }
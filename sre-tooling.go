@@ -3,22 +3,43 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	"github.com/onaio/sre-tooling/audit"
 
 	"github.com/onaio/sre-tooling/infra"
 	"github.com/onaio/sre-tooling/libs/cli"
+	infraLib "github.com/onaio/sre-tooling/libs/infra"
+	"github.com/onaio/sre-tooling/libs/infra/retry"
+	"github.com/onaio/sre-tooling/libs/infra/workers"
+	"github.com/onaio/sre-tooling/libs/notification"
 	"github.com/onaio/sre-tooling/monitoring"
 	versionSubCommand "github.com/onaio/sre-tooling/version"
 )
 
 type SRETooling struct {
-	helpFlag    *bool
-	subCommands []cli.Command
+	helpFlag            *bool
+	verboseFlag         *bool
+	providersConfigFlag *string
+	cacheTTLFlag        *time.Duration
+	noCacheFlag         *bool
+	strictFlag          *bool
+	concurrencyFlag     *int
+	requestTimeoutFlag  *time.Duration
+	maxAttemptsFlag     *int
+	subCommands         []cli.Command
 }
 
 func (sreTooling *SRETooling) Init(helpFlagName string, helpFlagDescription string) {
 	sreTooling.helpFlag = flag.Bool(helpFlagName, false, helpFlagDescription)
+	sreTooling.verboseFlag = flag.Bool("verbose", false, "Whether to print diagnostic messages, e.g. the number of pages fetched from cloud providers, to stderr")
+	sreTooling.providersConfigFlag = flag.String("providers-config", "", "Path to the providers configuration file. Defaults to ~/.config/sre-tooling/providers.yaml if it exists")
+	sreTooling.cacheTTLFlag = flag.Duration("cache-ttl", 0, "How long to cache the resources fetched from the providers for e.g. '10m'. Defaults to the value of SRE_INFRA_CACHE_TTL. Resources are not cached if neither is set")
+	sreTooling.noCacheFlag = flag.Bool("no-cache", false, "Whether to fetch the resources from the providers instead of using the cached resources")
+	sreTooling.strictFlag = flag.Bool("strict", false, "Whether to fail if fetching any of the resources fails, instead of showing the resources that were fetched and the failures")
+	sreTooling.concurrencyFlag = flag.Int("concurrency", workers.Limit, "Maximum number of concurrent calls to the providers' APIs, e.g. to fetch the resources in each region")
+	sreTooling.requestTimeoutFlag = flag.Duration("request-timeout", retry.DefaultPolicy.Timeout, "How long each call to a provider's API has to complete before timing out")
+	sreTooling.maxAttemptsFlag = flag.Int("max-attempts", retry.DefaultPolicy.MaxAttempts, "Maximum number of times to make calls to a provider's API that are throttled or fail with a 5xx error. Calls are retried with an exponential backoff")
 
 	infra := new(infra.Infra)
 	infra.Init(helpFlagName, helpFlagDescription)
@@ -34,6 +55,14 @@ func (sreTooling *SRETooling) Init(helpFlagName string, helpFlagDescription stri
 
 	sreTooling.subCommands = []cli.Command{infra, monitoring, audit, version}
 	flag.Parse()
+	notification.Verbose = *sreTooling.verboseFlag
+	infraLib.ProvidersConfigFile = *sreTooling.providersConfigFlag
+	infraLib.CacheTTL = *sreTooling.cacheTTLFlag
+	infraLib.NoCache = *sreTooling.noCacheFlag
+	infraLib.Strict = *sreTooling.strictFlag
+	workers.Limit = *sreTooling.concurrencyFlag
+	retry.DefaultPolicy.Timeout = *sreTooling.requestTimeoutFlag
+	retry.DefaultPolicy.MaxAttempts = *sreTooling.maxAttemptsFlag
 }
 
 func (sreTooling *SRETooling) GetName() string {